@@ -1,12 +1,23 @@
 package main
 
 import (
+	"context"
+	"crypto/tls"
+	"expvar"
+	"flag"
 	"fmt"
 	"log"
 	"log/slog"
+	"net"
 	"net/http"
+	"net/url"
 	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
 
+	"github.com/bpietroniro/requestjar-go/internal/blob"
 	"github.com/bpietroniro/requestjar-go/internal/logging"
 	"github.com/bpietroniro/requestjar-go/internal/router"
 	"github.com/bpietroniro/requestjar-go/internal/service"
@@ -14,7 +25,38 @@ import (
 	"github.com/rs/cors"
 )
 
+// shutdownTimeout is how long requests in flight get to finish on shutdown
+const shutdownTimeout = 10 * time.Second
+
 func main() {
+	storeKind := flag.String("store", "memory", "storage backend to use: memory, sqlite or file")
+	dbPath := flag.String("db", "requestjar.db", "path to the SQLite database file (with -store=sqlite)")
+	dataDir := flag.String("data-dir", "data", "directory holding the jar logs (with -store=file)")
+	reapInterval := flag.Duration("reap-interval", time.Minute, "how often to enforce jar retention policies")
+	sweepInterval := flag.Duration("sweep-interval", time.Minute, "how often to delete expired jars")
+	compactInterval := flag.Duration("compact-interval", 10*time.Minute, "how often to compact the jar logs (with -store=file)")
+	tlsCert := flag.String("tls-cert", "", "certificate file to serve HTTPS with (requires -tls-key)")
+	tlsKey := flag.String("tls-key", "", "private key file to serve HTTPS with (requires -tls-cert)")
+	maxBodySize := flag.Int64("max-body-size", 10<<20, "largest request body, in bytes, any jar captures (0 for no limit)")
+	blobDir := flag.String("blob-dir", "blobs", "directory holding offloaded request bodies")
+	blobThreshold := flag.Int64("blob-threshold", 1<<20, "bodies larger than this many bytes are kept in -blob-dir (0 to keep every body in the store; 0 by default with -store=memory)")
+	maxForwards := flag.Int("max-forwards", 100, "most forwards that can be in flight in the background; captures beyond that aren't forwarded")
+	replayTimeout := flag.Duration("replay-timeout", 30*time.Second, "how long a replayed request waits for the target to answer")
+	eventBuffer := flag.Int("event-buffer", 64, "how many events each live connection can fall behind by")
+	heartbeat := flag.Duration("heartbeat", 15*time.Second, "how often idle event streams are sent a heartbeat (0 to turn off)")
+	maxStreamLifetime := flag.Duration("max-stream-lifetime", 30*time.Minute, "how long an event stream stays open before the client is asked to reconnect (0 for no limit)")
+	maxConnsPerJar := flag.Int("max-connections-per-jar", 100, "most live connections one jar can have (0 for no limit)")
+	maxConns := flag.Int("max-connections", 1000, "most live connections across all jars (0 for no limit)")
+	allowedOrigins := flag.String("allowed-origins", "http://localhost:5173", "comma-separated origins, such as https://app.example.com, that browsers may call the API and open WebSockets from (* for any)")
+	slowConsumers := flag.String("slow-consumers", service.SlowConsumerDrop, "what to do when a live connection falls further behind: drop events or disconnect")
+	flag.Parse()
+
+	// Offloaded bodies would outlive an in-memory store and never be
+	// cleaned up, so only offload when asked to
+	if *storeKind == "memory" && !flagSet("blob-threshold") {
+		*blobThreshold = 0
+	}
+
 	// Logger setup
 	logger := slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{
 		Level: logging.LevelTrace,
@@ -35,22 +77,114 @@ func main() {
 	slog.SetDefault(logger)
 
 	// Dependencies
-	jarStore := store.NewInMemoryJarStore()
-	requestStore := store.NewInMemoryRequestStore()
+	var jarStore store.JarStore
+	var requestStore store.RequestStore
+	// closeStore flushes and closes the store on shutdown
+	closeStore := func() error { return nil }
+
+	switch *storeKind {
+	case "memory":
+		jarStore = store.NewInMemoryJarStore()
+		requestStore = store.NewInMemoryRequestStore()
+	case "sqlite":
+		db, err := store.OpenSQLite(*dbPath)
+		if err != nil {
+			log.Fatalf("failed to open sqlite database: %v", err)
+		}
+		closeStore = db.Close
+
+		jarStore = store.NewSQLiteJarStore(db)
+		requestStore = store.NewSQLiteRequestStore(db)
+	case "file":
+		fileLog, err := store.OpenFileLog(*dataDir)
+		if err != nil {
+			log.Fatalf("failed to open file log: %v", err)
+		}
+		closeStore = fileLog.Close
+		fileLog.StartCompaction(*compactInterval)
+
+		jarStore = store.NewFileJarStore(fileLog)
+		requestStore = store.NewFileRequestStore(fileLog)
+	default:
+		log.Fatalf("unknown store %q", *storeKind)
+	}
+
 	svc := service.NewJarService(jarStore, requestStore)
+	svc.SetMaxBodySize(*maxBodySize)
+	svc.SetReplayTimeout(*replayTimeout)
+	svc.SetConnectionLimits(*maxConnsPerJar, *maxConns)
+
+	err := svc.SetEventBuffer(*eventBuffer)
+	if err != nil {
+		log.Fatalf("invalid -event-buffer: %v", err)
+	}
+
+	err = svc.SetSlowConsumerPolicy(*slowConsumers)
+	if err != nil {
+		log.Fatalf("invalid -slow-consumers: %v", err)
+	}
+	expvar.Publish("events", expvar.Func(func() any { return svc.EventStats() }))
+
+	err = svc.SetMaxForwards(*maxForwards)
+	if err != nil {
+		log.Fatalf("invalid -max-forwards: %v", err)
+	}
+	expvar.Publish("forwards", expvar.Func(func() any { return svc.ForwardStats() }))
+
+	if *blobThreshold > 0 {
+		blobs, err := blob.NewLocalStore(*blobDir)
+		if err != nil {
+			log.Fatalf("failed to open blob store: %v", err)
+		}
+		svc.SetBlobStore(blobs, *blobThreshold)
+	}
+
+	svc.StartRetentionReaper(*reapInterval)
+	svc.StartExpirySweeper(*sweepInterval)
 	r := router.CreateRouter(svc)
+	r.SetStreamTiming(*heartbeat, *maxStreamLifetime)
+
+	origins := splitList(*allowedOrigins)
+	originHosts, err := hosts(origins)
+	if err != nil {
+		log.Fatalf("invalid -allowed-origins: %v", err)
+	}
+	r.SetWebSocketOrigins(originHosts)
 
 	// Routing
 	mux := http.NewServeMux()
 
+	mux.Handle("GET /debug/vars", expvar.Handler())
 	mux.HandleFunc("GET /jars", r.GetAllJarMetadata)
 	mux.HandleFunc("POST /jars", r.CreateJar)
+	mux.HandleFunc("POST /jars/import", r.ImportJar)
 	mux.HandleFunc("DELETE /jars/{jarID}", r.DeleteJar)
 	mux.HandleFunc("GET /jars/{jarID}", r.GetJarWithRequests)
+	mux.HandleFunc("POST /jars/{jarID}/extend", r.ExtendJar)
+	mux.HandleFunc("GET /jars/{jarID}/export", r.ExportJar)
+	mux.HandleFunc("GET /jars/{jarID}/response", r.GetMockResponse)
+	mux.HandleFunc("PUT /jars/{jarID}/response", r.SetMockResponse)
+	mux.HandleFunc("DELETE /jars/{jarID}/response", r.DeleteMockResponse)
+	mux.HandleFunc("GET /jars/{jarID}/rules", r.GetRules)
+	mux.HandleFunc("PUT /jars/{jarID}/rules", r.SetRules)
+	mux.HandleFunc("DELETE /jars/{jarID}/rules", r.DeleteRules)
+	mux.HandleFunc("GET /jars/{jarID}/faults", r.GetFaults)
+	mux.HandleFunc("PUT /jars/{jarID}/faults", r.SetFaults)
+	mux.HandleFunc("DELETE /jars/{jarID}/faults", r.DeleteFaults)
+	mux.HandleFunc("GET /jars/{jarID}/forward", r.GetForward)
+	mux.HandleFunc("PUT /jars/{jarID}/forward", r.SetForward)
+	mux.HandleFunc("DELETE /jars/{jarID}/forward", r.DeleteForward)
+	mux.HandleFunc("GET /jars/{jarID}/requests", r.ListRequests)
+	mux.HandleFunc("DELETE /jars/{jarID}/requests", r.ClearJar)
+	mux.HandleFunc("GET /jars/{jarID}/requests/wait", r.WaitForRequest)
 	mux.HandleFunc("DELETE /jars/{jarID}/requests/{reqID}", r.DeleteRequest)
+	mux.HandleFunc("GET /jars/{jarID}/requests/{reqID}/body", r.DownloadRequestBody)
+	mux.HandleFunc("POST /jars/{jarID}/requests/{reqID}/replay", r.ReplayRequest)
+	mux.HandleFunc("GET /jars/{jarID}/requests/{reqID}/replays", r.ListReplays)
+	mux.HandleFunc("GET /jars/{jarID}/requests/{reqID}/snippet", r.GetRequestSnippet)
 	mux.HandleFunc("GET /jars/{jarID}/events", r.HandleSSEConnection)
-	mux.HandleFunc("/r/{jarID}/", r.CaptureRequest)
-	mux.HandleFunc("/r/{jarID}/{path}", r.CaptureRequest)
+	mux.HandleFunc("GET /jars/{jarID}/ws", r.HandleWebSocket)
+	mux.HandleFunc("/r/{jarID}/{path...}", r.CaptureRequest)
 	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		_, err := fmt.Fprint(w, "hi from Request Jar") // TODO
 		if err != nil {
@@ -60,7 +194,7 @@ func main() {
 
 	// CORS configuration
 	c := cors.New(cors.Options{
-		AllowedOrigins:   []string{"http://localhost:5173"}, // TODO
+		AllowedOrigins:   origins,
 		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "PATCH", "OPTIONS"},
 		AllowedHeaders:   []string{"*"},
 		AllowCredentials: true,
@@ -68,7 +202,101 @@ func main() {
 
 	handler := c.Handler(mux)
 
-	// Server
-	slog.Info("Server starting on :8080")
-	log.Fatal(http.ListenAndServe(":8080", handler))
+	// Streams and waits are tied to this context, so that shutting down
+	// ends them instead of waiting for them
+	baseCtx, cancelRequests := context.WithCancel(context.Background())
+
+	server := &http.Server{
+		Addr:        ":8080",
+		Handler:     handler,
+		BaseContext: func(net.Listener) context.Context { return baseCtx },
+	}
+	server.RegisterOnShutdown(cancelRequests)
+
+	serveErr := make(chan error, 1)
+	go func() {
+		if *tlsCert != "" || *tlsKey != "" {
+			// Ask for, but don't require, client certificates so captured
+			// requests can record who presented one
+			server.TLSConfig = &tls.Config{ClientAuth: tls.RequestClientCert}
+
+			slog.Info("Server starting on :8080 with TLS")
+			serveErr <- server.ListenAndServeTLS(*tlsCert, *tlsKey)
+			return
+		}
+
+		slog.Info("Server starting on :8080")
+		serveErr <- server.ListenAndServe()
+	}()
+
+	signals, stopSignals := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stopSignals()
+
+	exitCode := 0
+	select {
+	case err = <-serveErr:
+		slog.Error("server failed", slog.Any("error", err))
+		exitCode = 1
+	case <-signals.Done():
+		slog.Info("shutting down")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	err = server.Shutdown(ctx)
+	if err != nil {
+		slog.Error("error shutting down server", slog.Any("error", err))
+	}
+
+	svc.Stop()
+
+	err = closeStore()
+	if err != nil {
+		log.Fatalf("failed to close store: %v", err)
+	}
+
+	os.Exit(exitCode)
+}
+
+// splitList splits a comma-separated flag value, leaving out empty items.
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// hosts returns the host of each origin, as WebSocket origin checks compare
+// them. "*" stands for any origin.
+func hosts(origins []string) ([]string, error) {
+	hosts := make([]string, 0, len(origins))
+	for _, origin := range origins {
+		if origin == "*" {
+			hosts = append(hosts, "*")
+			continue
+		}
+
+		u, err := url.Parse(origin)
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			return nil, fmt.Errorf("%q isn't an origin such as http://localhost:5173", origin)
+		}
+		hosts = append(hosts, u.Host)
+	}
+	return hosts, nil
+}
+
+// flagSet reports whether the named flag was given on the command line.
+func flagSet(name string) bool {
+	set := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
 }
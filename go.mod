@@ -2,4 +2,22 @@ module github.com/bpietroniro/requestjar-go
 
 go 1.23.6
 
-require github.com/rs/cors v1.11.1
+require (
+	github.com/andybalholm/brotli v1.1.1
+	github.com/coder/websocket v1.8.14
+	github.com/rs/cors v1.11.1
+	golang.org/x/text v0.21.0
+	modernc.org/sqlite v1.34.5
+)
+
+require (
+	github.com/dustin/go-humanize v1.0.1 // indirect
+	github.com/google/uuid v1.6.0 // indirect
+	github.com/mattn/go-isatty v0.0.20 // indirect
+	github.com/ncruces/go-strftime v0.1.9 // indirect
+	github.com/remyoudompheng/bigfft v0.0.0-20230129092748-24d4a6f8daec // indirect
+	golang.org/x/sys v0.22.0 // indirect
+	modernc.org/libc v1.55.3 // indirect
+	modernc.org/mathutil v1.6.0 // indirect
+	modernc.org/memory v1.8.0 // indirect
+)
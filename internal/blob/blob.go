@@ -0,0 +1,126 @@
+// Package blob stores request bodies that are too large to keep alongside the
+// rest of a captured request.
+package blob
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/bpietroniro/requestjar-go/internal/errors"
+)
+
+// Store holds opaque blobs under slash-separated keys such as
+// "<jarID>/<reqID>".
+type Store interface {
+	// Put stores everything read from r under key, replacing any existing blob
+	Put(key string, r io.Reader) error
+	// Open returns a reader for the blob under key, or a not-found error
+	Open(key string) (io.ReadCloser, error)
+	// Delete removes the blob under key. Deleting a missing blob isn't an error.
+	Delete(key string) error
+	// DeleteAll removes every blob whose key starts with prefix + "/"
+	DeleteAll(prefix string) error
+}
+
+type localStore struct {
+	dir string
+}
+
+// NewLocalStore keeps blobs as files under dir, creating it if needed.
+func NewLocalStore(dir string) (Store, error) {
+	slog.Info("creating local blob storage dependency", slog.String("dir", dir))
+
+	err := os.MkdirAll(dir, 0o755)
+	if err != nil {
+		return nil, err
+	}
+
+	return &localStore{dir: dir}, nil
+}
+
+func (s *localStore) Put(key string, r io.Reader) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(path), 0o755)
+	if err != nil {
+		return err
+	}
+
+	// Write to a temporary file first so readers never see a partial blob
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+
+	_, err = io.Copy(tmp, r)
+	if err != nil {
+		_ = tmp.Close()
+		return err
+	}
+
+	err = tmp.Close()
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+func (s *localStore) Open(key string) (io.ReadCloser, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, errors.NotFound("blob not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return f, nil
+}
+
+func (s *localStore) Delete(key string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+
+	err = os.Remove(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return nil
+}
+
+func (s *localStore) DeleteAll(prefix string) error {
+	path, err := s.path(prefix)
+	if err != nil {
+		return err
+	}
+
+	return os.RemoveAll(path)
+}
+
+// path maps key to a file under s.dir, refusing keys that would escape it.
+func (s *localStore) path(key string) (string, error) {
+	for _, part := range strings.Split(key, "/") {
+		if part == "" || part == "." || part == ".." || strings.ContainsRune(part, '\\') {
+			return "", fmt.Errorf("invalid blob key %q", key)
+		}
+	}
+
+	return filepath.Join(s.dir, filepath.FromSlash(key)), nil
+}
@@ -0,0 +1,90 @@
+package blob
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/bpietroniro/requestjar-go/internal/errors"
+)
+
+func TestLocalStore(t *testing.T) {
+	s, err := NewLocalStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewLocalStore: %v", err)
+	}
+
+	err = s.Put("jar/r1", strings.NewReader("first"))
+	if err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	err = s.Put("jar/r2", strings.NewReader("second"))
+	if err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	requireBlob(t, s, "jar/r1", "first")
+
+	err = s.Put("jar/r1", strings.NewReader("replaced"))
+	if err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	requireBlob(t, s, "jar/r1", "replaced")
+
+	err = s.Delete("jar/r1")
+	if err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	requireMissing(t, s, "jar/r1")
+
+	err = s.Delete("jar/r1")
+	if err != nil {
+		t.Fatalf("expected deleting a missing blob to succeed, got %v", err)
+	}
+
+	err = s.DeleteAll("jar")
+	if err != nil {
+		t.Fatalf("DeleteAll: %v", err)
+	}
+	requireMissing(t, s, "jar/r2")
+}
+
+func TestLocalStoreRejectsEscapingKeys(t *testing.T) {
+	s, err := NewLocalStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewLocalStore: %v", err)
+	}
+
+	for _, key := range []string{"", "../x", "jar/../../x", "/abs", "jar//r1", `jar\r1`} {
+		err = s.Put(key, strings.NewReader("x"))
+		if err == nil {
+			t.Errorf("expected key %q to be rejected", key)
+		}
+	}
+}
+
+func requireBlob(t *testing.T, s Store, key string, want string) {
+	t.Helper()
+
+	r, err := s.Open(key)
+	if err != nil {
+		t.Fatalf("Open(%q): %v", key, err)
+	}
+	defer r.Close()
+
+	got, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("reading %q: %v", key, err)
+	}
+	if string(got) != want {
+		t.Fatalf("expected %q under %q, got %q", want, key, got)
+	}
+}
+
+func requireMissing(t *testing.T, s Store, key string) {
+	t.Helper()
+
+	_, err := s.Open(key)
+	if !errors.Is(err, errors.ErrNotFound) {
+		t.Fatalf("expected %q to be missing, got %v", key, err)
+	}
+}
@@ -0,0 +1,269 @@
+// Package decoding turns captured request bodies into something readable: it
+// undoes content encodings, converts text to UTF-8 and parses JSON, form and
+// multipart bodies.
+package decoding
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/andybalholm/brotli"
+	"golang.org/x/text/encoding/htmlindex"
+
+	"github.com/bpietroniro/requestjar-go/internal/models"
+)
+
+// Body returns a readable view of a body sent with header, or nil when there
+// is nothing to decode (an empty or opaque binary body). Decompressing past
+// limit bytes is an error; a limit <= 0 means no limit. Problems are reported
+// in the result's Error rather than returned, since the raw body is always
+// kept anyway.
+func Body(header http.Header, body []byte, limit int64) *models.DecodedBody {
+	if len(body) == 0 {
+		return nil
+	}
+
+	decoded := &models.DecodedBody{}
+	data := body
+
+	encodings := contentEncodings(header)
+	if len(encodings) > 0 {
+		decoded.ContentEncoding = encodings
+
+		var err error
+		data, err = decompress(body, encodings, limit)
+		if err != nil {
+			decoded.Error = err.Error()
+			return decoded
+		}
+	}
+
+	decoded.Size = int64(len(data))
+
+	// An unparseable Content-Type is treated like a missing one
+	mediaType, params, _ := mime.ParseMediaType(header.Get("Content-Type"))
+
+	if strings.HasPrefix(mediaType, "multipart/") {
+		parts, err := parseMultipart(data, params["boundary"])
+		decoded.Parts = parts
+		if err != nil {
+			decoded.Error = err.Error()
+		}
+		return decoded
+	}
+
+	if !isText(mediaType, data) {
+		if len(encodings) == 0 {
+			return nil
+		}
+		return decoded
+	}
+
+	text, err := toUTF8(data, params["charset"])
+	if err != nil {
+		decoded.Error = err.Error()
+		return decoded
+	}
+	if !isUTF8(params["charset"]) {
+		decoded.Charset = strings.ToLower(params["charset"])
+	}
+
+	switch {
+	case isJSON(mediaType, text):
+		if json.Valid([]byte(text)) {
+			decoded.JSON = json.RawMessage(text)
+			return decoded
+		}
+		decoded.Error = "body is not valid JSON"
+	case mediaType == "application/x-www-form-urlencoded":
+		form, err := url.ParseQuery(text)
+		if err == nil {
+			decoded.Form = form
+			return decoded
+		}
+		decoded.Error = err.Error()
+	}
+
+	decoded.Text = text
+	return decoded
+}
+
+// contentEncodings returns the encodings listed in Content-Encoding, in the
+// order they were applied, leaving out identity.
+func contentEncodings(header http.Header) []string {
+	var encodings []string
+
+	for _, value := range header.Values("Content-Encoding") {
+		for _, encoding := range strings.Split(value, ",") {
+			encoding = strings.ToLower(strings.TrimSpace(encoding))
+			if encoding != "" && encoding != "identity" {
+				encodings = append(encodings, encoding)
+			}
+		}
+	}
+
+	return encodings
+}
+
+// decompress undoes encodings, last applied first.
+func decompress(body []byte, encodings []string, limit int64) ([]byte, error) {
+	data := body
+
+	for i := len(encodings) - 1; i >= 0; i-- {
+		var r io.Reader
+		var err error
+
+		switch encodings[i] {
+		case "gzip", "x-gzip":
+			r, err = gzip.NewReader(bytes.NewReader(data))
+		case "deflate":
+			r, err = newDeflateReader(data)
+		case "br":
+			r = brotli.NewReader(bytes.NewReader(data))
+		default:
+			return nil, fmt.Errorf("unsupported content encoding %q", encodings[i])
+		}
+		if err != nil {
+			return nil, fmt.Errorf("decoding %s: %w", encodings[i], err)
+		}
+
+		if limit > 0 {
+			r = io.LimitReader(r, limit+1)
+		}
+
+		data, err = io.ReadAll(r)
+		if err != nil {
+			return nil, fmt.Errorf("decoding %s: %w", encodings[i], err)
+		}
+
+		if limit > 0 && int64(len(data)) > limit {
+			return nil, fmt.Errorf("decoded body is larger than %d bytes", limit)
+		}
+	}
+
+	return data, nil
+}
+
+// newDeflateReader reads HTTP's "deflate", which is meant to be zlib-wrapped
+// but is sent as a raw deflate stream often enough to be worth accepting.
+func newDeflateReader(data []byte) (io.Reader, error) {
+	r, err := zlib.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return flate.NewReader(bytes.NewReader(data)), nil
+	}
+	return r, nil
+}
+
+// isText reports whether a body of mediaType should be shown as text. Bodies
+// without a media type are text when they are valid UTF-8.
+func isText(mediaType string, data []byte) bool {
+	if mediaType == "" {
+		return utf8.Valid(data)
+	}
+
+	if strings.HasPrefix(mediaType, "text/") || strings.HasSuffix(mediaType, "+json") || strings.HasSuffix(mediaType, "+xml") {
+		return true
+	}
+
+	switch mediaType {
+	case "application/json", "application/x-ndjson", "application/xml", "application/javascript",
+		"application/x-www-form-urlencoded", "application/graphql", "application/yaml":
+		return true
+	}
+
+	return false
+}
+
+// isJSON reports whether a text body should be parsed as JSON. Bodies without
+// a media type are when they look like a JSON object or array.
+func isJSON(mediaType string, text string) bool {
+	if mediaType == "" {
+		trimmed := strings.TrimSpace(text)
+		return strings.HasPrefix(trimmed, "{") || strings.HasPrefix(trimmed, "[")
+	}
+
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+func isUTF8(charset string) bool {
+	switch strings.ToLower(charset) {
+	case "", "utf-8", "utf8", "us-ascii":
+		return true
+	}
+	return false
+}
+
+// toUTF8 converts data from charset to UTF-8.
+func toUTF8(data []byte, charset string) (string, error) {
+	if isUTF8(charset) {
+		return string(data), nil
+	}
+
+	enc, err := htmlindex.Get(charset)
+	if err != nil {
+		return "", fmt.Errorf("unsupported charset %q", charset)
+	}
+
+	converted, err := enc.NewDecoder().Bytes(data)
+	if err != nil {
+		return "", fmt.Errorf("converting from %s: %w", charset, err)
+	}
+
+	return string(converted), nil
+}
+
+// parseMultipart describes each part of a multipart body. The parts read
+// before any error are still returned.
+func parseMultipart(data []byte, boundary string) ([]models.BodyPart, error) {
+	if boundary == "" {
+		return nil, fmt.Errorf("multipart body has no boundary")
+	}
+
+	parts := []models.BodyPart{}
+	reader := multipart.NewReader(bytes.NewReader(data), boundary)
+
+	for {
+		p, err := reader.NextPart()
+		if err == io.EOF {
+			return parts, nil
+		}
+		if err != nil {
+			return parts, err
+		}
+
+		content, err := io.ReadAll(p)
+		if err != nil {
+			return parts, err
+		}
+
+		part := models.BodyPart{
+			Name:        p.FormName(),
+			Filename:    p.FileName(),
+			ContentType: p.Header.Get("Content-Type"),
+			Size:        int64(len(content)),
+		}
+
+		if part.Filename == "" {
+			mediaType, params, _ := mime.ParseMediaType(part.ContentType)
+			if isText(mediaType, content) {
+				value, err := toUTF8(content, params["charset"])
+				if err == nil {
+					part.Value = value
+				}
+			}
+		}
+
+		parts = append(parts, part)
+	}
+}
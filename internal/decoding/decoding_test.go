@@ -0,0 +1,159 @@
+package decoding
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+)
+
+func TestBodyUndoesContentEncodings(t *testing.T) {
+	payload := `{"event":"ping"}`
+
+	tests := []struct {
+		name     string
+		encoding string
+		body     []byte
+	}{
+		{"gzip", "gzip", compress(t, "gzip", []byte(payload))},
+		{"zlib deflate", "deflate", compress(t, "deflate", []byte(payload))},
+		{"raw deflate", "deflate", compress(t, "raw-deflate", []byte(payload))},
+		{"brotli", "br", compress(t, "br", []byte(payload))},
+		{"stacked", "deflate, gzip", compress(t, "gzip", compress(t, "deflate", []byte(payload)))},
+		{"identity", "identity", []byte(payload)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := http.Header{"Content-Type": {"application/json"}, "Content-Encoding": {tt.encoding}}
+
+			decoded := Body(header, tt.body, 0)
+			if decoded == nil || decoded.Error != "" {
+				t.Fatalf("expected the body to decode, got %+v", decoded)
+			}
+			if string(decoded.JSON) != payload || decoded.Size != int64(len(payload)) {
+				t.Fatalf("expected JSON %s, got %s (size %d)", payload, decoded.JSON, decoded.Size)
+			}
+		})
+	}
+}
+
+func TestBodyReportsDecodingProblems(t *testing.T) {
+	large := compress(t, "gzip", bytes.Repeat([]byte("a"), 1000))
+
+	tests := []struct {
+		name     string
+		header   http.Header
+		body     []byte
+		limit    int64
+		contains string
+	}{
+		{"unknown encoding", http.Header{"Content-Encoding": {"zstd"}}, []byte("x"), 0, "unsupported content encoding"},
+		{"corrupt gzip", http.Header{"Content-Encoding": {"gzip"}}, []byte("not gzip"), 0, "decoding gzip"},
+		{"over limit", http.Header{"Content-Encoding": {"gzip"}}, large, 100, "larger than 100 bytes"},
+		{"invalid JSON", http.Header{"Content-Type": {"application/json"}}, []byte("{nope"), 0, "not valid JSON"},
+		{"unknown charset", http.Header{"Content-Type": {"text/plain; charset=klingon"}}, []byte("x"), 0, "unsupported charset"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decoded := Body(tt.header, tt.body, tt.limit)
+			if decoded == nil || !strings.Contains(decoded.Error, tt.contains) {
+				t.Fatalf("expected an error containing %q, got %+v", tt.contains, decoded)
+			}
+		})
+	}
+}
+
+func TestBodyViews(t *testing.T) {
+	if Body(http.Header{}, nil, 0) != nil {
+		t.Fatal("expected no view of an empty body")
+	}
+
+	if Body(http.Header{"Content-Type": {"image/png"}}, []byte{0x89, 'P', 'N', 'G'}, 0) != nil {
+		t.Fatal("expected no view of a binary body")
+	}
+
+	latin1 := Body(http.Header{"Content-Type": {"text/plain; charset=ISO-8859-1"}}, []byte("caf\xe9"), 0)
+	if latin1 == nil || latin1.Text != "café" || latin1.Charset != "iso-8859-1" {
+		t.Fatalf("expected latin-1 text converted to UTF-8, got %+v", latin1)
+	}
+
+	unlabelled := Body(http.Header{}, []byte(` {"a":1}`), 0)
+	if unlabelled == nil || string(unlabelled.JSON) != ` {"a":1}` {
+		t.Fatalf("expected an unlabelled JSON body to be parsed, got %+v", unlabelled)
+	}
+
+	form := Body(http.Header{"Content-Type": {"application/x-www-form-urlencoded"}}, []byte("a=1&a=2&b=x+y"), 0)
+	if form == nil || len(form.Form["a"]) != 2 || form.Form.Get("b") != "x y" || form.Text != "" {
+		t.Fatalf("expected form fields, got %+v", form)
+	}
+
+	text := Body(http.Header{"Content-Type": {"text/csv"}}, []byte("a,b"), 0)
+	if text == nil || text.Text != "a,b" {
+		t.Fatalf("expected text, got %+v", text)
+	}
+}
+
+func TestBodyDescribesMultipartParts(t *testing.T) {
+	var buf bytes.Buffer
+	w := multipart.NewWriter(&buf)
+	_ = w.WriteField("title", "report")
+	file, _ := w.CreateFormFile("upload", "data.bin")
+	_, _ = file.Write([]byte{0, 1, 2, 3})
+	_ = w.Close()
+
+	decoded := Body(http.Header{"Content-Type": {w.FormDataContentType()}}, buf.Bytes(), 0)
+	if decoded == nil || decoded.Error != "" || len(decoded.Parts) != 2 {
+		t.Fatalf("expected two parts, got %+v", decoded)
+	}
+
+	field, upload := decoded.Parts[0], decoded.Parts[1]
+	if field.Name != "title" || field.Value != "report" || field.Filename != "" {
+		t.Fatalf("unexpected field part %+v", field)
+	}
+	if upload.Name != "upload" || upload.Filename != "data.bin" || upload.Size != 4 || upload.Value != "" {
+		t.Fatalf("unexpected file part %+v", upload)
+	}
+
+	missing := Body(http.Header{"Content-Type": {"multipart/form-data"}}, buf.Bytes(), 0)
+	if missing == nil || !strings.Contains(missing.Error, "boundary") {
+		t.Fatalf("expected a missing boundary to be reported, got %+v", missing)
+	}
+}
+
+func compress(t *testing.T, encoding string, data []byte) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	var w io.WriteCloser
+
+	switch encoding {
+	case "gzip":
+		w = gzip.NewWriter(&buf)
+	case "deflate":
+		w = zlib.NewWriter(&buf)
+	case "raw-deflate":
+		w, _ = flate.NewWriter(&buf, flate.DefaultCompression)
+	case "br":
+		w = brotli.NewWriter(&buf)
+	}
+
+	_, err := w.Write(data)
+	if err != nil {
+		t.Fatalf("compressing: %v", err)
+	}
+	err = w.Close()
+	if err != nil {
+		t.Fatalf("compressing: %v", err)
+	}
+
+	return buf.Bytes()
+}
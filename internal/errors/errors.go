@@ -13,9 +13,11 @@ var (
 )
 
 var (
-	ErrNotFound     = HTTPError{statusCode: http.StatusNotFound, message: "not found"}
-	ErrBadRequest   = HTTPError{statusCode: http.StatusBadRequest, message: "bad request"}
-	ErrUnauthorized = HTTPError{statusCode: http.StatusUnauthorized, message: "unauthorized"}
-	ErrForbidden    = HTTPError{statusCode: http.StatusForbidden, message: "unauthorized"}
-	ErrInternal     = HTTPError{statusCode: http.StatusInternalServerError, message: "unauthorized"}
+	ErrNotFound        = HTTPError{statusCode: http.StatusNotFound, message: "not found"}
+	ErrBadRequest      = HTTPError{statusCode: http.StatusBadRequest, message: "bad request"}
+	ErrUnauthorized    = HTTPError{statusCode: http.StatusUnauthorized, message: "unauthorized"}
+	ErrForbidden       = HTTPError{statusCode: http.StatusForbidden, message: "unauthorized"}
+	ErrInternal        = HTTPError{statusCode: http.StatusInternalServerError, message: "unauthorized"}
+	ErrTooManyRequests = HTTPError{statusCode: http.StatusTooManyRequests, message: "too many requests"}
+	ErrRequestTimeout  = HTTPError{statusCode: http.StatusRequestTimeout, message: "request timeout"}
 )
@@ -52,6 +52,14 @@ func Forbidden(msg string) HTTPError {
 	return HTTPError{statusCode: http.StatusForbidden, message: msg}
 }
 
+func TooManyRequests(msg string) HTTPError {
+	return HTTPError{statusCode: http.StatusTooManyRequests, message: msg}
+}
+
+func RequestTimeout(msg string) HTTPError {
+	return HTTPError{statusCode: http.StatusRequestTimeout, message: msg}
+}
+
 func Internal(msg string) HTTPError {
 	return HTTPError{statusCode: http.StatusInternalServerError, message: msg}
 }
@@ -0,0 +1,420 @@
+// Package har converts captured requests to and from HTTP Archive (HAR) 1.2
+// files, the format browser devtools export network traffic in.
+package har
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/bpietroniro/requestjar-go/internal/models"
+)
+
+type HAR struct {
+	Log Log `json:"log"`
+}
+
+type Log struct {
+	Version string  `json:"version"`
+	Creator Creator `json:"creator"`
+	Entries []Entry `json:"entries"`
+}
+
+type Creator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type Entry struct {
+	StartedDateTime time.Time `json:"startedDateTime"`
+	// Time is the total elapsed time of the request in milliseconds
+	Time     float64  `json:"time"`
+	Request  Request  `json:"request"`
+	Response Response `json:"response"`
+	Cache    struct{} `json:"cache"`
+	Timings  Timings  `json:"timings"`
+	Comment  string   `json:"comment,omitempty"`
+}
+
+type Request struct {
+	Method      string    `json:"method"`
+	URL         string    `json:"url"`
+	HTTPVersion string    `json:"httpVersion"`
+	Cookies     []Cookie  `json:"cookies"`
+	Headers     []NVP     `json:"headers"`
+	QueryString []NVP     `json:"queryString"`
+	PostData    *PostData `json:"postData,omitempty"`
+	HeadersSize int64     `json:"headersSize"`
+	BodySize    int64     `json:"bodySize"`
+}
+
+// PostData is a request body. Encoding is "base64" when Text holds a binary
+// body in base64; the field isn't in the HAR spec but is widely understood.
+type PostData struct {
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+	Encoding string `json:"encoding,omitempty"`
+}
+
+type Response struct {
+	Status      int      `json:"status"`
+	StatusText  string   `json:"statusText"`
+	HTTPVersion string   `json:"httpVersion"`
+	Cookies     []Cookie `json:"cookies"`
+	Headers     []NVP    `json:"headers"`
+	Content     Content  `json:"content"`
+	RedirectURL string   `json:"redirectURL"`
+	HeadersSize int64    `json:"headersSize"`
+	BodySize    int64    `json:"bodySize"`
+	Comment     string   `json:"comment,omitempty"`
+}
+
+type Content struct {
+	Size     int64  `json:"size"`
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text,omitempty"`
+	Encoding string `json:"encoding,omitempty"`
+}
+
+type Cookie struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// NVP is a name/value pair, as headers and query parameters are written
+type NVP struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type Timings struct {
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+}
+
+const (
+	version     = "1.2"
+	creatorName = "requestjar-go"
+	httpVersion = "HTTP/1.1"
+)
+
+// Writer streams an archive one entry at a time, so that the bodies of a whole
+// jar never have to be held in memory at once. Its output is the JSON encoding
+// of a HAR holding an entry for each request. Nothing is written until the
+// first entry, or Close.
+type Writer struct {
+	w            io.Writer
+	jarID        string
+	fallbackHost string
+	started      bool
+}
+
+// NewWriter returns a Writer of jarID's archive to w. Requests captured
+// without a Host are given fallbackHost.
+func NewWriter(w io.Writer, jarID string, fallbackHost string) *Writer {
+	return &Writer{w: w, jarID: jarID, fallbackHost: fallbackHost}
+}
+
+// WriteEntry adds a request to the archive. Its Body must hold the whole body,
+// including a body that was offloaded, and its Response the answer it was
+// sent, if known.
+func (a *Writer) WriteEntry(req *models.Request) error {
+	entry, err := json.Marshal(exportEntry(a.jarID, a.fallbackHost, req))
+	if err != nil {
+		return err
+	}
+
+	prefix := ","
+	if !a.started {
+		prefix = opening
+		a.started = true
+	}
+
+	_, err = io.WriteString(a.w, prefix+string(entry))
+	return err
+}
+
+// Close finishes the archive. It doesn't close the underlying writer.
+func (a *Writer) Close() error {
+	closing := "]}}\n"
+	if !a.started {
+		closing = opening + closing
+		a.started = true
+	}
+
+	_, err := io.WriteString(a.w, closing)
+	return err
+}
+
+// opening is everything in an archive before its first entry.
+var opening = fmt.Sprintf(`{"log":{"version":%q,"creator":{"name":%q,"version":"1"},"entries":[`, version, creatorName)
+
+func exportEntry(jarID string, fallbackHost string, req *models.Request) Entry {
+	proto := req.Proto
+	if proto == "" {
+		proto = httpVersion
+	}
+
+	entry := Entry{
+		StartedDateTime: req.CreatedAt,
+		Request: Request{
+			Method:      req.Method,
+			URL:         req.URL(jarID, fallbackHost),
+			HTTPVersion: proto,
+			Cookies:     requestCookies(req.Headers),
+			Headers:     pairs(req.Headers),
+			QueryString: pairs(req.Query),
+			HeadersSize: -1,
+			BodySize:    req.BodySize(),
+		},
+		Response: exportResponse(req, proto),
+	}
+
+	if len(req.Body) > 0 {
+		text, encoding := encodeBody(req.Body)
+		entry.Request.PostData = &PostData{
+			MimeType: req.Headers.Get("Content-Type"),
+			Text:     text,
+			Encoding: encoding,
+		}
+	}
+
+	// Injected delays are the only time a jar spends on a request worth
+	// mentioning
+	if req.Fault != nil && req.Fault.Delay > 0 {
+		entry.Timings.Wait = float64(time.Duration(req.Fault.Delay)) / float64(time.Millisecond)
+		entry.Time = entry.Timings.Wait
+	}
+
+	return entry
+}
+
+func exportResponse(req *models.Request, proto string) Response {
+	response := Response{
+		HTTPVersion: proto,
+		Cookies:     []Cookie{},
+		Headers:     []NVP{},
+		HeadersSize: -1,
+		BodySize:    -1,
+	}
+
+	// A status of 0 is how HAR records requests that got no response
+	switch {
+	case req.Fault != nil && req.Fault.Dropped:
+		response.Comment = "connection dropped by fault injection"
+		return response
+	case req.Response == nil:
+		response.Comment = "response not recorded"
+		return response
+	}
+
+	mock := req.Response
+	status := mock.StatusCode
+	if status == 0 {
+		status = http.StatusOK
+	}
+
+	headers := http.Header{}
+	for name, value := range mock.Headers {
+		headers.Set(name, value)
+	}
+	if mock.ContentType != "" {
+		headers.Set("Content-Type", mock.ContentType)
+	}
+
+	response.Status = status
+	response.StatusText = http.StatusText(status)
+	response.Cookies = responseCookies(headers)
+	response.Headers = pairs(headers)
+	response.BodySize = int64(len(mock.Body))
+	response.Content = Content{
+		Size:     int64(len(mock.Body)),
+		MimeType: headers.Get("Content-Type"),
+		Text:     mock.Body,
+	}
+
+	return response
+}
+
+// pairs flattens headers or query parameters into name/value pairs, sorted by
+// name with each name's values kept in order.
+func pairs(values map[string][]string) []NVP {
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	nvps := []NVP{}
+	for _, name := range names {
+		for _, value := range values[name] {
+			nvps = append(nvps, NVP{Name: name, Value: value})
+		}
+	}
+
+	return nvps
+}
+
+func requestCookies(headers http.Header) []Cookie {
+	cookies := []Cookie{}
+	for _, c := range (&http.Request{Header: headers}).Cookies() {
+		cookies = append(cookies, Cookie{Name: c.Name, Value: c.Value})
+	}
+	return cookies
+}
+
+func responseCookies(headers http.Header) []Cookie {
+	cookies := []Cookie{}
+	for _, c := range (&http.Response{Header: headers}).Cookies() {
+		cookies = append(cookies, Cookie{Name: c.Name, Value: c.Value})
+	}
+	return cookies
+}
+
+func encodeBody(body []byte) (text string, encoding string) {
+	if utf8.Valid(body) {
+		return string(body), ""
+	}
+	return base64.StdEncoding.EncodeToString(body), "base64"
+}
+
+// Import turns an archive's entries back into requests, in the order they
+// were made, with their recorded responses. IDs are left for the caller to
+// assign.
+func Import(archive *HAR) ([]*models.Request, error) {
+	entries := slices.Clone(archive.Log.Entries)
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].StartedDateTime.Before(entries[j].StartedDateTime)
+	})
+
+	requests := make([]*models.Request, 0, len(entries))
+	for i, entry := range entries {
+		req, err := importEntry(&entry)
+		if err != nil {
+			return nil, fmt.Errorf("entry %d: %w", i, err)
+		}
+		requests = append(requests, req)
+	}
+
+	return requests, nil
+}
+
+func importEntry(entry *Entry) (*models.Request, error) {
+	if entry.Request.Method == "" {
+		return nil, fmt.Errorf("request has no method")
+	}
+
+	u, err := url.Parse(entry.Request.URL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid URL: %w", err)
+	}
+
+	req := &models.Request{
+		CreatedAt:     entry.StartedDateTime,
+		Method:        entry.Request.Method,
+		Path:          capturedPath(u.Path),
+		Headers:       http.Header{},
+		Query:         u.Query(),
+		RawQuery:      u.RawQuery,
+		Proto:         entry.Request.HTTPVersion,
+		Host:          u.Host,
+		RequestURI:    u.RequestURI(),
+		ContentLength: -1,
+	}
+
+	for _, header := range entry.Request.Headers {
+		// HTTP/2 pseudo-headers such as :authority aren't real headers
+		if strings.HasPrefix(header.Name, ":") {
+			continue
+		}
+		req.Headers.Add(header.Name, header.Value)
+	}
+
+	if postData := entry.Request.PostData; postData != nil {
+		req.Body, err = decodeBody(postData.Text, postData.Encoding)
+		if err != nil {
+			return nil, err
+		}
+		req.ContentLength = int64(len(req.Body))
+
+		if req.Headers.Get("Content-Type") == "" && postData.MimeType != "" {
+			req.Headers.Set("Content-Type", postData.MimeType)
+		}
+	}
+
+	if entry.Response.Status != 0 {
+		req.Response, err = importResponse(&entry.Response)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return req, nil
+}
+
+func importResponse(response *Response) (*models.MockResponse, error) {
+	body, err := decodeBody(response.Content.Text, response.Content.Encoding)
+	if err != nil {
+		return nil, err
+	}
+
+	mock := &models.MockResponse{
+		StatusCode: response.Status,
+		Body:       string(body),
+	}
+
+	for _, header := range response.Headers {
+		if strings.HasPrefix(header.Name, ":") {
+			continue
+		}
+		if mock.Headers == nil {
+			mock.Headers = map[string]string{}
+		}
+
+		name := http.CanonicalHeaderKey(header.Name)
+		if existing, ok := mock.Headers[name]; ok {
+			mock.Headers[name] = existing + ", " + header.Value
+		} else {
+			mock.Headers[name] = header.Value
+		}
+	}
+
+	return mock, nil
+}
+
+func decodeBody(text string, encoding string) ([]byte, error) {
+	switch encoding {
+	case "":
+		return []byte(text), nil
+	case "base64":
+		body, err := base64.StdEncoding.DecodeString(text)
+		if err != nil {
+			return nil, fmt.Errorf("invalid base64 body: %w", err)
+		}
+		return body, nil
+	default:
+		return nil, fmt.Errorf("unsupported body encoding %q", encoding)
+	}
+}
+
+// capturedPath is the part of a URL path a jar would have captured: what
+// follows /r/{jarID}/ for requests that were made to a jar, or the whole path
+// otherwise.
+func capturedPath(p string) string {
+	if rest, ok := strings.CutPrefix(p, "/r/"); ok {
+		if _, path, found := strings.Cut(rest, "/"); found {
+			return path
+		}
+	}
+
+	return strings.TrimPrefix(p, "/")
+}
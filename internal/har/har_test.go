@@ -0,0 +1,235 @@
+package har
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/bpietroniro/requestjar-go/internal/models"
+)
+
+// writeArchive writes requests to an archive with a Writer.
+func writeArchive(t *testing.T, jarID string, fallbackHost string, requests []*models.Request) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	w := NewWriter(&buf, jarID, fallbackHost)
+	for _, req := range requests {
+		if err := w.WriteEntry(req); err != nil {
+			t.Fatalf("WriteEntry: %v", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	return buf.Bytes()
+}
+
+// requireArchive checks that got is the compact form of want, on one line.
+func requireArchive(t *testing.T, got []byte, want string) {
+	t.Helper()
+
+	var compact bytes.Buffer
+	if err := json.Compact(&compact, []byte(want)); err != nil {
+		t.Fatalf("invalid expected archive: %v", err)
+	}
+	compact.WriteString("\n")
+
+	if !bytes.Equal(got, compact.Bytes()) {
+		t.Fatalf("expected\n%s\ngot\n%s", compact.Bytes(), got)
+	}
+}
+
+func TestWriter(t *testing.T) {
+	createdAt := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	requests := []*models.Request{
+		{
+			ID:         "1",
+			CreatedAt:  createdAt,
+			Method:     "POST",
+			Path:       "hooks",
+			Headers:    http.Header{"Content-Type": {"application/json"}, "Cookie": {"session=abc"}},
+			Query:      url.Values{"a": {"1", "2"}},
+			RawQuery:   "a=1&a=2",
+			Proto:      "HTTP/2.0",
+			Host:       "jars.example.com",
+			RequestURI: "/r/jar/hooks?a=1&a=2",
+			TLS:        &models.TLSInfo{},
+			Body:       []byte(`{"ok":true}`),
+			Response:   &models.MockResponse{StatusCode: 201, ContentType: "text/plain", Body: "created"},
+		},
+		{
+			ID:     "2",
+			Method: "PUT",
+			Path:   "upload",
+			Body:   []byte{0xff, 0x00},
+			Fault:  &models.InjectedFault{Dropped: true},
+		},
+	}
+
+	requireArchive(t, writeArchive(t, "jar", "localhost:8080", requests), `{
+	"log": {
+		"version": "1.2",
+		"creator": {"name": "requestjar-go", "version": "1"},
+		"entries": [
+			{
+				"startedDateTime": "2024-05-01T12:00:00Z",
+				"time": 0,
+				"request": {
+					"method": "POST",
+					"url": "https://jars.example.com/r/jar/hooks?a=1\u0026a=2",
+					"httpVersion": "HTTP/2.0",
+					"cookies": [{"name": "session", "value": "abc"}],
+					"headers": [
+						{"name": "Content-Type", "value": "application/json"},
+						{"name": "Cookie", "value": "session=abc"}
+					],
+					"queryString": [{"name": "a", "value": "1"}, {"name": "a", "value": "2"}],
+					"postData": {"mimeType": "application/json", "text": "{\"ok\":true}"},
+					"headersSize": -1,
+					"bodySize": 11
+				},
+				"response": {
+					"status": 201,
+					"statusText": "Created",
+					"httpVersion": "HTTP/2.0",
+					"cookies": [],
+					"headers": [{"name": "Content-Type", "value": "text/plain"}],
+					"content": {"size": 7, "mimeType": "text/plain", "text": "created"},
+					"redirectURL": "",
+					"headersSize": -1,
+					"bodySize": 7
+				},
+				"cache": {},
+				"timings": {"send": 0, "wait": 0, "receive": 0}
+			},
+			{
+				"startedDateTime": "0001-01-01T00:00:00Z",
+				"time": 0,
+				"request": {
+					"method": "PUT",
+					"url": "http://localhost:8080/r/jar/upload",
+					"httpVersion": "HTTP/1.1",
+					"cookies": [],
+					"headers": [],
+					"queryString": [],
+					"postData": {"mimeType": "", "text": "/wA=", "encoding": "base64"},
+					"headersSize": -1,
+					"bodySize": 2
+				},
+				"response": {
+					"status": 0,
+					"statusText": "",
+					"httpVersion": "HTTP/1.1",
+					"cookies": [],
+					"headers": [],
+					"content": {"size": 0, "mimeType": ""},
+					"redirectURL": "",
+					"headersSize": -1,
+					"bodySize": -1,
+					"comment": "connection dropped by fault injection"
+				},
+				"cache": {},
+				"timings": {"send": 0, "wait": 0, "receive": 0}
+			}
+		]
+	}
+}`)
+}
+
+func TestWriterWithoutEntries(t *testing.T) {
+	requireArchive(t, writeArchive(t, "jar", "localhost:8080", nil),
+		`{"log": {"version": "1.2", "creator": {"name": "requestjar-go", "version": "1"}, "entries": []}}`)
+}
+
+func TestImportRoundTrip(t *testing.T) {
+	original := []*models.Request{
+		{
+			CreatedAt:  time.Date(2024, 5, 1, 12, 0, 1, 0, time.UTC),
+			Method:     "PUT",
+			Path:       "files/a.bin",
+			Headers:    http.Header{"Content-Type": {"application/octet-stream"}},
+			Host:       "localhost:8080",
+			RequestURI: "/r/jar/files/a.bin",
+			Body:       []byte{0xde, 0xad, 0xbe, 0xef},
+		},
+		{
+			CreatedAt:  time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+			Method:     "GET",
+			Path:       "search",
+			Headers:    http.Header{"Accept": {"application/json"}},
+			Query:      url.Values{"q": {"a b"}},
+			RawQuery:   "q=a+b",
+			Host:       "localhost:8080",
+			RequestURI: "/r/jar/search?q=a+b",
+			Response:   &models.MockResponse{StatusCode: 404, Headers: map[string]string{"X-Reason": "missing"}, Body: "nope"},
+		},
+	}
+
+	var archive HAR
+	err := json.Unmarshal(writeArchive(t, "jar", "", original), &archive)
+	if err != nil {
+		t.Fatalf("decoding archive: %v", err)
+	}
+
+	imported, err := Import(&archive)
+	if err != nil {
+		t.Fatalf("Import: %v", err)
+	}
+	if len(imported) != 2 {
+		t.Fatalf("expected 2 requests, got %d", len(imported))
+	}
+
+	// Entries come back in the order they were made
+	search, upload := imported[0], imported[1]
+
+	if search.Method != "GET" || search.Path != "search" || search.Query.Get("q") != "a b" || search.Headers.Get("Accept") != "application/json" {
+		t.Fatalf("unexpected request %+v", search)
+	}
+	if search.Response == nil || search.Response.StatusCode != 404 || search.Response.Body != "nope" || search.Response.Headers["X-Reason"] != "missing" {
+		t.Fatalf("expected the response to be imported, got %+v", search.Response)
+	}
+
+	if upload.Path != "files/a.bin" || !reflect.DeepEqual(upload.Body, original[0].Body) || !upload.CreatedAt.Equal(original[0].CreatedAt) {
+		t.Fatalf("unexpected request %+v", upload)
+	}
+	if upload.Response != nil {
+		t.Fatalf("expected no response, got %+v", upload.Response)
+	}
+}
+
+func TestImportRejectsInvalidEntries(t *testing.T) {
+	invalid := []Entry{
+		{Request: Request{URL: "http://example.com/"}},
+		{Request: Request{Method: "GET", URL: "http://[::1"}},
+		{Request: Request{Method: "POST", URL: "/", PostData: &PostData{Text: "!!", Encoding: "base64"}}},
+		{Request: Request{Method: "POST", URL: "/", PostData: &PostData{Text: "x", Encoding: "gzip"}}},
+	}
+
+	for _, entry := range invalid {
+		_, err := Import(&HAR{Log: Log{Entries: []Entry{entry}}})
+		if err == nil {
+			t.Errorf("expected %+v to be rejected", entry.Request)
+		}
+	}
+}
+
+func TestCapturedPath(t *testing.T) {
+	cases := map[string]string{
+		"/r/jar/hooks/stripe": "hooks/stripe",
+		"/r/jar/":             "",
+		"/api/users":          "api/users",
+		"":                    "",
+	}
+
+	for in, want := range cases {
+		if got := capturedPath(in); got != want {
+			t.Errorf("capturedPath(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
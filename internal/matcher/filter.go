@@ -0,0 +1,106 @@
+package matcher
+
+import (
+	"bytes"
+	"slices"
+	"strings"
+
+	"github.com/bpietroniro/requestjar-go/internal/models"
+)
+
+// Filter is a models.RequestFilter that is ready to match requests. A nil
+// *Filter matches every request.
+type Filter struct {
+	spec     models.RequestFilter
+	jsonPath *JSONPath
+}
+
+// NewFilter validates and compiles spec. A nil or empty spec gives a nil
+// filter.
+func NewFilter(spec *models.RequestFilter) (*Filter, error) {
+	if spec == nil || isEmptyFilter(spec) {
+		return nil, nil
+	}
+
+	f := &Filter{spec: *spec}
+
+	if spec.JSONPath != "" {
+		jsonPath, err := CompileJSONPath(spec.JSONPath)
+		if err != nil {
+			return nil, err
+		}
+		f.jsonPath = jsonPath
+	}
+
+	return f, nil
+}
+
+func isEmptyFilter(spec *models.RequestFilter) bool {
+	return len(spec.Methods) == 0 && spec.PathPrefix == "" && len(spec.HeadersPresent) == 0 &&
+		len(spec.Headers) == 0 && spec.BodyContains == "" && spec.JSONPath == ""
+}
+
+// Spec returns the filter's definition, or nil for a nil filter.
+func (f *Filter) Spec() *models.RequestFilter {
+	if f == nil {
+		return nil
+	}
+
+	spec := f.spec
+	return &spec
+}
+
+// Matches reports whether req satisfies every condition of the filter.
+func (f *Filter) Matches(req *models.Request) bool {
+	if f == nil {
+		return true
+	}
+
+	if len(f.spec.Methods) > 0 && !slices.ContainsFunc(f.spec.Methods, func(m string) bool { return strings.EqualFold(m, req.Method) }) {
+		return false
+	}
+
+	if f.spec.PathPrefix != "" && !strings.HasPrefix(normalizePath(req.Path), normalizePath(f.spec.PathPrefix)) {
+		return false
+	}
+
+	for _, name := range f.spec.HeadersPresent {
+		if len(req.Headers.Values(name)) == 0 {
+			return false
+		}
+	}
+
+	for name, want := range f.spec.Headers {
+		if !slices.Contains(req.Headers.Values(name), want) {
+			return false
+		}
+	}
+
+	if f.spec.BodyContains != "" && !bodyContains(req, f.spec.BodyContains) {
+		return false
+	}
+
+	if f.jsonPath != nil {
+		body, ok := RequestJSON(req)
+		if !ok || !f.jsonPath.Matches(body) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// bodyContains searches the raw body and, for bodies with a content encoding
+// or charset, the decoded text too.
+func bodyContains(req *models.Request, text string) bool {
+	if bytes.Contains(req.Body, []byte(text)) {
+		return true
+	}
+
+	decoded := req.Decoded
+	if decoded == nil {
+		return false
+	}
+
+	return bytes.Contains(decoded.JSON, []byte(text)) || strings.Contains(decoded.Text, text)
+}
@@ -0,0 +1,77 @@
+package matcher
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/bpietroniro/requestjar-go/internal/models"
+)
+
+func TestFilter(t *testing.T) {
+	req := &models.Request{
+		Method:  "POST",
+		Path:    "hooks/billing/invoices",
+		Headers: http.Header{"X-Service": {"billing"}, "X-Tag": {"a", "b"}},
+		Body:    []byte(`{"type":"invoice.paid","amount":1250}`),
+	}
+
+	tests := []struct {
+		name string
+		spec models.RequestFilter
+		want bool
+	}{
+		{"empty filter", models.RequestFilter{}, true},
+		{"method", models.RequestFilter{Methods: []string{"get", "post"}}, true},
+		{"wrong method", models.RequestFilter{Methods: []string{"GET"}}, false},
+		{"path prefix", models.RequestFilter{PathPrefix: "/hooks/billing"}, true},
+		{"wrong path prefix", models.RequestFilter{PathPrefix: "hooks/shipping"}, false},
+		{"header present", models.RequestFilter{HeadersPresent: []string{"x-service"}}, true},
+		{"header missing", models.RequestFilter{HeadersPresent: []string{"X-Other"}}, false},
+		{"header equal", models.RequestFilter{Headers: map[string]string{"X-Tag": "b"}}, true},
+		{"header not equal", models.RequestFilter{Headers: map[string]string{"X-Service": "shipping"}}, false},
+		{"body contains", models.RequestFilter{BodyContains: "invoice.paid"}, true},
+		{"body does not contain", models.RequestFilter{BodyContains: "refund"}, false},
+		{"json path", models.RequestFilter{JSONPath: "$.amount >= 1000"}, true},
+		{"json path fails", models.RequestFilter{JSONPath: `$.type == "invoice.voided"`}, false},
+		{"all conditions", models.RequestFilter{Methods: []string{"POST"}, PathPrefix: "hooks", JSONPath: "$.type"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := NewFilter(&tt.spec)
+			if err != nil {
+				t.Fatalf("NewFilter: %v", err)
+			}
+			if got := f.Matches(req); got != tt.want {
+				t.Fatalf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestFilterSearchesDecodedBodies(t *testing.T) {
+	req := &models.Request{
+		Body:    []byte{0x1f, 0x8b},
+		Decoded: &models.DecodedBody{Text: "hello there"},
+	}
+
+	f, _ := NewFilter(&models.RequestFilter{BodyContains: "there"})
+	if !f.Matches(req) {
+		t.Fatal("expected the decoded text to be searched")
+	}
+}
+
+func TestNewFilter(t *testing.T) {
+	f, err := NewFilter(&models.RequestFilter{})
+	if err != nil || f != nil {
+		t.Fatalf("expected an empty spec to give no filter, got %v, %v", f, err)
+	}
+	if !f.Matches(&models.Request{}) || f.Spec() != nil {
+		t.Fatal("expected a nil filter to match everything")
+	}
+
+	_, err = NewFilter(&models.RequestFilter{JSONPath: "type"})
+	if err == nil {
+		t.Fatal("expected an invalid JSONPath to be rejected")
+	}
+}
@@ -0,0 +1,271 @@
+package matcher
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// JSONPath is a compiled predicate on a JSON document: a JSONPath expression,
+// optionally followed by a comparison with a JSON value.
+//
+//	$.event.type == "invoice.paid"
+//	$.items[*].price >= 100
+//	$.data['customer-id']
+//
+// Paths support .name, ['name'], [index] and the .* and [*] wildcards. With a
+// comparison, the predicate holds when any value the path selects satisfies
+// it; without one, when the path selects anything at all. Numbers compare
+// numerically and strings lexically; values of different types are only ever
+// unequal.
+type JSONPath struct {
+	segments []pathSegment
+	op       string
+	want     any
+}
+
+type pathSegment struct {
+	key      string
+	index    int
+	isIndex  bool
+	wildcard bool
+}
+
+// comparisons are checked longest first so that "<=" isn't read as "<"
+var comparisons = []string{"==", "!=", "<=", ">=", "<", ">"}
+
+// CompileJSONPath parses a JSONPath predicate.
+func CompileJSONPath(expr string) (*JSONPath, error) {
+	s := strings.TrimSpace(expr)
+	if !strings.HasPrefix(s, "$") {
+		return nil, fmt.Errorf("JSONPath %q must start with $", expr)
+	}
+
+	p := &JSONPath{}
+
+	i := 1
+	for i < len(s) && (s[i] == '.' || s[i] == '[') {
+		var seg pathSegment
+		var err error
+
+		if s[i] == '.' {
+			seg, i, err = parseDotSegment(s, i+1)
+		} else {
+			seg, i, err = parseBracketSegment(s, i+1)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid JSONPath %q: %w", expr, err)
+		}
+
+		p.segments = append(p.segments, seg)
+	}
+
+	rest := strings.TrimSpace(s[i:])
+	if rest == "" {
+		return p, nil
+	}
+
+	for _, op := range comparisons {
+		if literal, ok := strings.CutPrefix(rest, op); ok {
+			p.op = op
+
+			want, err := parseLiteral(strings.TrimSpace(literal))
+			if err != nil {
+				return nil, fmt.Errorf("invalid JSONPath %q: %w", expr, err)
+			}
+			p.want = want
+
+			return p, nil
+		}
+	}
+
+	return nil, fmt.Errorf("invalid JSONPath %q: unexpected %q", expr, rest)
+}
+
+func parseDotSegment(s string, i int) (pathSegment, int, error) {
+	if i < len(s) && s[i] == '*' {
+		return pathSegment{wildcard: true}, i + 1, nil
+	}
+
+	start := i
+	for i < len(s) && isNameByte(s[i]) {
+		i++
+	}
+	if i == start {
+		return pathSegment{}, i, fmt.Errorf("expected a field name at offset %d", start)
+	}
+
+	return pathSegment{key: s[start:i]}, i, nil
+}
+
+func parseBracketSegment(s string, i int) (pathSegment, int, error) {
+	if i < len(s) && (s[i] == '\'' || s[i] == '"') {
+		quote := s[i]
+		end := strings.IndexByte(s[i+1:], quote)
+		if end < 0 {
+			return pathSegment{}, i, fmt.Errorf("unterminated quoted name at offset %d", i)
+		}
+
+		key := s[i+1 : i+1+end]
+		i += end + 2
+		if i >= len(s) || s[i] != ']' {
+			return pathSegment{}, i, fmt.Errorf("expected ] at offset %d", i)
+		}
+
+		return pathSegment{key: key}, i + 1, nil
+	}
+
+	end := strings.IndexByte(s[i:], ']')
+	if end < 0 {
+		return pathSegment{}, i, fmt.Errorf("unterminated [ at offset %d", i-1)
+	}
+
+	inner := strings.TrimSpace(s[i : i+end])
+	next := i + end + 1
+
+	if inner == "*" {
+		return pathSegment{wildcard: true}, next, nil
+	}
+
+	index, err := strconv.Atoi(inner)
+	if err != nil || index < 0 {
+		return pathSegment{}, i, fmt.Errorf("expected an index, a quoted name or * at offset %d", i)
+	}
+
+	return pathSegment{index: index, isIndex: true}, next, nil
+}
+
+func isNameByte(b byte) bool {
+	return b == '_' || b == '-' || b >= '0' && b <= '9' || b >= 'a' && b <= 'z' || b >= 'A' && b <= 'Z' || b >= 0x80
+}
+
+// parseLiteral decodes the value a path is compared with. Strings may be
+// single-quoted as well as double-quoted.
+func parseLiteral(literal string) (any, error) {
+	if len(literal) >= 2 && literal[0] == '\'' && literal[len(literal)-1] == '\'' {
+		return literal[1 : len(literal)-1], nil
+	}
+
+	dec := json.NewDecoder(strings.NewReader(literal))
+	dec.UseNumber()
+
+	var want any
+	err := dec.Decode(&want)
+	if err != nil || dec.InputOffset() != int64(len(literal)) {
+		return nil, fmt.Errorf("expected a JSON value after the comparison, got %q", literal)
+	}
+
+	switch want.(type) {
+	case map[string]any, []any:
+		return nil, fmt.Errorf("can't compare with an object or array")
+	}
+
+	return want, nil
+}
+
+// Matches reports whether the predicate holds for doc, as decoded by
+// DecodeJSON.
+func (p *JSONPath) Matches(doc any) bool {
+	for _, v := range p.selectValues(doc) {
+		if p.op == "" || compare(v, p.op, p.want) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (p *JSONPath) selectValues(doc any) []any {
+	nodes := []any{doc}
+
+	for _, seg := range p.segments {
+		var next []any
+
+		for _, node := range nodes {
+			switch n := node.(type) {
+			case map[string]any:
+				if seg.wildcard {
+					for _, v := range n {
+						next = append(next, v)
+					}
+				} else if v, ok := n[seg.key]; ok && !seg.isIndex {
+					next = append(next, v)
+				}
+			case []any:
+				if seg.wildcard {
+					next = append(next, n...)
+				} else if seg.isIndex && seg.index < len(n) {
+					next = append(next, n[seg.index])
+				}
+			}
+		}
+
+		nodes = next
+	}
+
+	return nodes
+}
+
+func compare(got any, op string, want any) bool {
+	var c int
+	comparable := false
+
+	switch w := want.(type) {
+	case json.Number:
+		if g, ok := got.(json.Number); ok {
+			gf, err1 := g.Float64()
+			wf, err2 := w.Float64()
+			if err1 == nil && err2 == nil {
+				c = cmpFloat(gf, wf)
+				comparable = true
+			}
+		}
+	case string:
+		if g, ok := got.(string); ok {
+			c = strings.Compare(g, w)
+			comparable = true
+		}
+	case bool:
+		if g, ok := got.(bool); ok {
+			if g != w {
+				c = 1
+			}
+			comparable = op == "==" || op == "!="
+		}
+	case nil:
+		if got == nil {
+			comparable = op == "==" || op == "!="
+		}
+	}
+
+	if !comparable {
+		return op == "!="
+	}
+
+	switch op {
+	case "==":
+		return c == 0
+	case "!=":
+		return c != 0
+	case "<":
+		return c < 0
+	case "<=":
+		return c <= 0
+	case ">":
+		return c > 0
+	default:
+		return c >= 0
+	}
+}
+
+func cmpFloat(a float64, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
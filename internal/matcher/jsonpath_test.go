@@ -0,0 +1,72 @@
+package matcher
+
+import "testing"
+
+func TestJSONPath(t *testing.T) {
+	doc, _ := DecodeJSON([]byte(`{
+		"event": {"type": "invoice.paid", "amount": 1250, "live": false, "note": null},
+		"items": [{"price": 5}, {"price": 150}],
+		"customer-id": "c_1"
+	}`))
+
+	tests := []struct {
+		expr string
+		want bool
+	}{
+		{`$`, true},
+		{`$.event.type == "invoice.paid"`, true},
+		{`$.event.type == 'invoice.paid'`, true},
+		{`$.event.type != "invoice.paid"`, false},
+		{`$.event.type`, true},
+		{`$.event.missing`, false},
+		{`$['event']['type'] == "invoice.paid"`, true},
+		{`$["customer-id"] == "c_1"`, true},
+		{`$.customer-id == "c_1"`, true},
+		{`$.event.amount > 1000`, true},
+		{`$.event.amount <= 1000`, false},
+		{`$.event.amount == 1250.0`, true},
+		{`$.event.live == false`, true},
+		{`$.event.note == null`, true},
+		{`$.event.type > "a"`, true},
+		{`$.event.amount == "1250"`, false},
+		{`$.event.amount != "1250"`, true},
+		{`$.items[1].price == 150`, true},
+		{`$.items[2].price`, false},
+		{`$.items[*].price > 100`, true},
+		{`$.items[*].price > 1000`, false},
+		{`$.event.* == "invoice.paid"`, true},
+	}
+
+	for _, tt := range tests {
+		p, err := CompileJSONPath(tt.expr)
+		if err != nil {
+			t.Errorf("CompileJSONPath(%q): %v", tt.expr, err)
+			continue
+		}
+		if got := p.Matches(doc); got != tt.want {
+			t.Errorf("%s: expected %v, got %v", tt.expr, tt.want, got)
+		}
+	}
+}
+
+func TestCompileJSONPathErrors(t *testing.T) {
+	invalid := []string{
+		``,
+		`event.type`,
+		`$.`,
+		`$[`,
+		`$['type`,
+		`$[-1]`,
+		`$[a]`,
+		`$.type ~= "a"`,
+		`$.type == `,
+		`$.type == {"a": 1}`,
+		`$.type == "a" extra`,
+	}
+
+	for _, expr := range invalid {
+		if _, err := CompileJSONPath(expr); err == nil {
+			t.Errorf("expected %q to be rejected", expr)
+		}
+	}
+}
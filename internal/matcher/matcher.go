@@ -0,0 +1,132 @@
+// Package matcher decides whether captured requests satisfy a
+// models.RequestMatcher.
+package matcher
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/bpietroniro/requestjar-go/internal/models"
+)
+
+// Validate reports whether m can be used for matching.
+func Validate(m *models.RequestMatcher) error {
+	if m.Path != "" {
+		_, err := path.Match(normalizePath(m.Path), "")
+		if err != nil {
+			return fmt.Errorf("invalid path pattern %q: %w", m.Path, err)
+		}
+	}
+
+	return nil
+}
+
+// Matches reports whether req satisfies every condition set on m.
+func Matches(m *models.RequestMatcher, req *models.Request) bool {
+	if m.Method != "" && !strings.EqualFold(m.Method, req.Method) {
+		return false
+	}
+
+	if m.Path != "" {
+		ok, err := path.Match(normalizePath(m.Path), normalizePath(req.Path))
+		if err != nil || !ok {
+			return false
+		}
+	}
+
+	for name, want := range m.Headers {
+		if !slices.Contains(req.Headers.Values(name), want) {
+			return false
+		}
+	}
+
+	for name, want := range m.Query {
+		if !slices.Contains(req.Query[name], want) {
+			return false
+		}
+	}
+
+	if len(m.JSONBody) > 0 {
+		body, ok := RequestJSON(req)
+		if !ok {
+			return false
+		}
+
+		for field, want := range m.JSONBody {
+			got, ok := LookupField(body, field)
+			if !ok || got != want {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+func normalizePath(p string) string {
+	return strings.TrimPrefix(p, "/")
+}
+
+// RequestJSON decodes a captured request's JSON body, using its decoded view
+// when there is one so that compressed or re-encoded bodies match too.
+func RequestJSON(req *models.Request) (any, bool) {
+	if req.Decoded != nil && req.Decoded.JSON != nil {
+		return DecodeJSON(req.Decoded.JSON)
+	}
+
+	return DecodeJSON(req.Body)
+}
+
+// DecodeJSON decodes a JSON body for LookupField, keeping numbers exactly as
+// they were written.
+func DecodeJSON(body []byte) (any, bool) {
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+
+	var v any
+	err := dec.Decode(&v)
+	if err != nil {
+		return nil, false
+	}
+
+	return v, true
+}
+
+// LookupField walks a dotted path through decoded JSON and returns the value at
+// the end as text: strings as-is, everything else in its JSON form.
+func LookupField(v any, field string) (string, bool) {
+	for _, key := range strings.Split(field, ".") {
+		switch node := v.(type) {
+		case map[string]any:
+			child, ok := node[key]
+			if !ok {
+				return "", false
+			}
+			v = child
+		case []any:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(node) {
+				return "", false
+			}
+			v = node[i]
+		default:
+			return "", false
+		}
+	}
+
+	if s, ok := v.(string); ok {
+		return s, true
+	}
+
+	text, err := json.Marshal(v)
+	if err != nil {
+		return "", false
+	}
+
+	return string(text), true
+}
@@ -0,0 +1,71 @@
+package matcher
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/bpietroniro/requestjar-go/internal/models"
+)
+
+func TestMatches(t *testing.T) {
+	req := &models.Request{
+		Method:  "POST",
+		Path:    "users/42/orders",
+		Headers: http.Header{"X-Github-Event": {"push"}, "Content-Type": {"application/json"}, "X-Tag": {"a", "b"}},
+		Query:   url.Values{"page": {"2"}, "id": {"1", "2"}},
+		Body:    []byte(`{"event":{"type":"order.created","id":12345678901234567890},"items":[{"sku":"a"}],"test":true}`),
+	}
+
+	tests := []struct {
+		name    string
+		matcher models.RequestMatcher
+		want    bool
+	}{
+		{"empty matcher", models.RequestMatcher{}, true},
+		{"method", models.RequestMatcher{Method: "post"}, true},
+		{"wrong method", models.RequestMatcher{Method: "GET"}, false},
+		{"path glob", models.RequestMatcher{Path: "users/*/orders"}, true},
+		{"path glob with leading slash", models.RequestMatcher{Path: "/users/*/orders"}, true},
+		{"glob does not cross segments", models.RequestMatcher{Path: "users/*"}, false},
+		{"header any case", models.RequestMatcher{Headers: map[string]string{"x-github-event": "push"}}, true},
+		{"wrong header", models.RequestMatcher{Headers: map[string]string{"X-Github-Event": "issues"}}, false},
+		{"missing header", models.RequestMatcher{Headers: map[string]string{"X-Missing": "1"}}, false},
+		{"any header value", models.RequestMatcher{Headers: map[string]string{"X-Tag": "b"}}, true},
+		{"query", models.RequestMatcher{Query: map[string]string{"page": "2"}}, true},
+		{"any query value", models.RequestMatcher{Query: map[string]string{"id": "2"}}, true},
+		{"missing query", models.RequestMatcher{Query: map[string]string{"size": ""}}, false},
+		{"json string field", models.RequestMatcher{JSONBody: map[string]string{"event.type": "order.created"}}, true},
+		{"json number field", models.RequestMatcher{JSONBody: map[string]string{"event.id": "12345678901234567890"}}, true},
+		{"json bool field", models.RequestMatcher{JSONBody: map[string]string{"test": "true"}}, true},
+		{"json array index", models.RequestMatcher{JSONBody: map[string]string{"items.0.sku": "a"}}, true},
+		{"json missing field", models.RequestMatcher{JSONBody: map[string]string{"event.name": "x"}}, false},
+		{"all conditions", models.RequestMatcher{Method: "POST", Path: "users/*/orders", JSONBody: map[string]string{"event.type": "order.created"}}, true},
+		{"one condition fails", models.RequestMatcher{Method: "POST", Path: "admins/*/orders"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Matches(&tt.matcher, req)
+			if got != tt.want {
+				t.Fatalf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestMatchesNonJSONBody(t *testing.T) {
+	req := &models.Request{Body: []byte("a=1&b=2")}
+	m := &models.RequestMatcher{JSONBody: map[string]string{"a": "1"}}
+
+	if Matches(m, req) {
+		t.Fatal("expected a form body not to match a JSON field condition")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	err := Validate(&models.RequestMatcher{Path: "users/[a-"})
+	if err == nil {
+		t.Fatal("expected an invalid glob to be rejected")
+	}
+}
@@ -1,20 +1,395 @@
 package models
 
-import "time"
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
 
 type Jar struct {
+	ID        string           `json:"id"`
+	Name      string           `json:"name"`
+	CreatedAt time.Time        `json:"createdAt"`
+	Retention *RetentionPolicy `json:"retention,omitempty"`
+	ExpiresAt *time.Time       `json:"expiresAt,omitempty"`
+	Response  *MockResponse    `json:"response,omitempty"`
+	Rules     []ResponseRule   `json:"rules,omitempty"`
+	Faults    *FaultConfig     `json:"faults,omitempty"`
+	// MaxBodySize is the largest request body, in bytes, the jar will capture.
+	// The server-wide limit applies when it is zero or larger.
+	MaxBodySize int64          `json:"maxBodySize,omitempty"`
+	Forward     *ForwardConfig `json:"forward,omitempty"`
+}
+
+type Request struct {
+	ID        string         `json:"id"`
+	CreatedAt time.Time      `json:"createdAt"`
+	Method    string         `json:"method"`
+	Path      string         `json:"path"`
+	Headers   http.Header    `json:"headerValues"`
+	ClientIP  string         `json:"clientIP"`
+	Body      []byte         `json:"body"`
+	Query     url.Values     `json:"queryValues"`
+	RawQuery  string         `json:"rawQuery"`
+	Fault     *InjectedFault `json:"fault,omitempty"`
+
+	// BodyInfo describes Body. It's nil for requests captured before it was
+	// recorded.
+	BodyInfo *BodyInfo `json:"bodyInfo,omitempty"`
+	// Decoded is a readable view of Body, or nil when there is nothing to
+	// decode. Body always keeps the bytes exactly as they were received.
+	Decoded *DecodedBody `json:"decoded,omitempty"`
+	// Response is what the jar answered with. It's nil when the answer came
+	// from a forward target (see Replay), and for requests captured before
+	// responses were recorded.
+	Response *MockResponse `json:"response,omitempty"`
+
+	// Proto is the protocol version, e.g. "HTTP/1.1"
+	Proto string `json:"proto"`
+	Host  string `json:"host"`
+	// RequestURI is the unmodified request-target sent by the client
+	RequestURI string `json:"requestURI"`
+	// ContentLength is -1 when the length wasn't known up front
+	ContentLength    int64       `json:"contentLength"`
+	TransferEncoding []string    `json:"transferEncoding,omitempty"`
+	Trailers         http.Header `json:"trailers,omitempty"`
+	// TLS is nil for requests that weren't made over TLS
+	TLS *TLSInfo `json:"tls,omitempty"`
+}
+
+// BodySize is the size of the captured body, including bodies that were
+// offloaded to blob storage.
+func (r *Request) BodySize() int64 {
+	if r.BodyInfo != nil {
+		return r.BodyInfo.Size
+	}
+	return int64(len(r.Body))
+}
+
+// URL reconstructs the URL the request was sent to. Requests captured before
+// the host and request URI were recorded are given fallbackHost and the path
+// they would have had in the jar.
+func (r *Request) URL(jarID string, fallbackHost string) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+
+	host := r.Host
+	if host == "" {
+		host = fallbackHost
+	}
+
+	uri := r.RequestURI
+	if uri == "" {
+		uri = "/r/" + jarID + "/" + r.Path
+		if r.RawQuery != "" {
+			uri += "?" + r.RawQuery
+		}
+	}
+
+	return scheme + "://" + host + uri
+}
+
+// BodyInfo describes a captured request body. Bodies larger than the server's
+// offload threshold are kept in blob storage rather than in Request.Body and
+// have to be downloaded separately.
+type BodyInfo struct {
+	Size int64 `json:"size"`
+	// SHA256 is the hex-encoded digest of the body
+	SHA256      string `json:"sha256"`
+	ContentType string `json:"contentType,omitempty"`
+	Offloaded   bool   `json:"offloaded,omitempty"`
+}
+
+// DecodedBody is a captured body with its content encodings undone and its text
+// converted to UTF-8. At most one of JSON, Form, Parts and Text is set,
+// depending on the body's media type.
+type DecodedBody struct {
+	// ContentEncoding lists the encodings that were undone, in the order the
+	// sender applied them
+	ContentEncoding []string `json:"contentEncoding,omitempty"`
+	// Charset is the character set the text was converted from
+	Charset string `json:"charset,omitempty"`
+	// Size is the length of the body once its content encodings are undone
+	Size  int64           `json:"size"`
+	JSON  json.RawMessage `json:"json,omitempty"`
+	Form  url.Values      `json:"form,omitempty"`
+	Parts []BodyPart      `json:"parts,omitempty"`
+	Text  string          `json:"text,omitempty"`
+	// Error explains why the body couldn't be decoded any further
+	Error string `json:"error,omitempty"`
+}
+
+// BodyPart describes one part of a multipart body. Value holds the contents
+// of form fields that aren't files.
+type BodyPart struct {
+	Name        string `json:"name,omitempty"`
+	Filename    string `json:"filename,omitempty"`
+	ContentType string `json:"contentType,omitempty"`
+	Size        int64  `json:"size"`
+	Value       string `json:"value,omitempty"`
+}
+
+// TLSInfo describes the TLS connection a request was made over.
+type TLSInfo struct {
+	Version            string `json:"version"`
+	CipherSuite        string `json:"cipherSuite"`
+	ServerName         string `json:"serverName,omitempty"`
+	NegotiatedProtocol string `json:"negotiatedProtocol,omitempty"`
+	ClientCertSubject  string `json:"clientCertSubject,omitempty"`
+	ClientCertIssuer   string `json:"clientCertIssuer,omitempty"`
+}
+
+// legacyRequestFields are the single-valued headers and query maps that
+// requests were serialised with before every value was kept. They're still
+// written, holding the first value of each key, so existing consumers keep
+// working, and are read back when the multi-valued fields are absent so that
+// data stored by older versions can be loaded.
+type legacyRequestFields struct {
+	Headers map[string]string `json:"headers"`
+	Query   map[string]string `json:"query"`
+}
+
+func (r Request) MarshalJSON() ([]byte, error) {
+	type plain Request
+	return json.Marshal(struct {
+		plain
+		legacyRequestFields
+	}{
+		plain(r),
+		legacyRequestFields{Headers: firstValues(r.Headers), Query: firstValues(r.Query)},
+	})
+}
+
+func (r *Request) UnmarshalJSON(data []byte) error {
+	type plain Request
+	var wire struct {
+		plain
+		legacyRequestFields
+	}
+
+	err := json.Unmarshal(data, &wire)
+	if err != nil {
+		return err
+	}
+
+	*r = Request(wire.plain)
+
+	legacy := wire.legacyRequestFields
+
+	if r.Headers == nil && legacy.Headers != nil {
+		r.Headers = make(http.Header, len(legacy.Headers))
+		for key, value := range legacy.Headers {
+			r.Headers[key] = []string{value}
+		}
+	}
+
+	if r.Query == nil && legacy.Query != nil {
+		r.Query = make(url.Values, len(legacy.Query))
+		for key, value := range legacy.Query {
+			r.Query[key] = []string{value}
+		}
+	}
+
+	return nil
+}
+
+func firstValues(values map[string][]string) map[string]string {
+	first := make(map[string]string, len(values))
+	for key, vs := range values {
+		if len(vs) > 0 {
+			first[key] = vs[0]
+		}
+	}
+	return first
+}
+
+// Replay records one resend of a captured request to a target URL and what
+// came back.
+type Replay struct {
 	ID        string    `json:"id"`
-	Name      string    `json:"name"`
+	RequestID string    `json:"requestID"`
 	CreatedAt time.Time `json:"createdAt"`
+
+	// Target, Method and Headers are what was actually sent. Body is only
+	// recorded when it was overridden; otherwise the captured body was sent.
+	Target  string      `json:"target"`
+	Method  string      `json:"method"`
+	Headers http.Header `json:"headers"`
+	Body    []byte      `json:"body,omitempty"`
+
+	// Response is nil, and Error set, when no response was received
+	Response *ReplayResponse `json:"response,omitempty"`
+	Error    string          `json:"error,omitempty"`
+	// Duration is the time from sending the request until the whole response
+	// was read; TimeToFirstByte is until the first byte of the response
+	Duration        Duration `json:"duration"`
+	TimeToFirstByte Duration `json:"timeToFirstByte,omitempty"`
+
+	// Forwarded is set on replays made by a jar's ForwardConfig rather than
+	// on request. Attempts counts the tries it took, including retries.
+	Forwarded bool `json:"forwarded,omitempty"`
+	Attempts  int  `json:"attempts,omitempty"`
 }
 
-type Request struct {
-	ID        string            `json:"id"`
-	CreatedAt time.Time         `json:"createdAt"`
-	Method    string            `json:"method"`
-	Path      string            `json:"path"`
-	Headers   map[string]string `json:"headers"`
-	ClientIP  string            `json:"clientIP"`
-	Body      []byte            `json:"body"`
-	Query     map[string]string `json:"query"`
+// ReplayResponse is the upstream's answer to a Replay.
+type ReplayResponse struct {
+	StatusCode int         `json:"statusCode"`
+	Headers    http.Header `json:"headers"`
+	Body       []byte      `json:"body"`
+	// Truncated is set when the body was longer than the server keeps
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+// Who answers the callers of a forwarding jar
+const (
+	RespondWithJar      = "jar"
+	RespondWithUpstream = "upstream"
+)
+
+// ForwardConfig makes a jar pass every captured request on to upstream
+// targets as it arrives, like a tee. Each target is a base URL that the
+// captured path and query are appended to. Forwards are recorded as replays of
+// the captured request.
+type ForwardConfig struct {
+	Targets []string `json:"targets"`
+	// Timeout limits each attempt (10s if unset)
+	Timeout Duration `json:"timeout,omitempty"`
+	// Retries is how many more times a target is tried after a connection
+	// error or 5xx response. The wait between attempts starts at Backoff
+	// (500ms if unset) and doubles each time.
+	Retries int      `json:"retries,omitempty"`
+	Backoff Duration `json:"backoff,omitempty"`
+	// Respond is RespondWithJar (the default) to answer callers with the
+	// jar's own response without waiting for the targets, or
+	// RespondWithUpstream to wait for and pass on the first target's response
+	Respond string `json:"respond,omitempty"`
+}
+
+// RetentionPolicy limits how many captured requests a jar keeps. A zero value
+// for any field means that dimension is unlimited. When a limit is exceeded the
+// oldest requests are evicted first.
+type RetentionPolicy struct {
+	MaxRequests  int      `json:"maxRequests,omitempty"`
+	MaxAge       Duration `json:"maxAge,omitempty"`
+	MaxBodyBytes int64    `json:"maxBodyBytes,omitempty"`
+}
+
+// MockResponse is what a jar sends back to the callers whose requests it
+// captures. A zero StatusCode means 200. When Template is set, Body and the
+// header values are Go text/template templates executed against the captured
+// request (see package templating).
+type MockResponse struct {
+	StatusCode  int               `json:"statusCode,omitempty"`
+	Headers     map[string]string `json:"headers,omitempty"`
+	Body        string            `json:"body,omitempty"`
+	ContentType string            `json:"contentType,omitempty"`
+	Template    bool              `json:"template,omitempty"`
+}
+
+// AllowsBody reports whether the response's status can carry a body: 204 and
+// 304 responses can't.
+func (r *MockResponse) AllowsBody() bool {
+	return r.StatusCode != http.StatusNoContent && r.StatusCode != http.StatusNotModified
+}
+
+// ResponseRule picks the response for captured requests that satisfy Match.
+// A jar's rules are tried in order and the first match wins; when none match
+// the jar's default Response is used.
+type ResponseRule struct {
+	Name     string         `json:"name,omitempty"`
+	Match    RequestMatcher `json:"match"`
+	Response MockResponse   `json:"response"`
+}
+
+// RequestMatcher describes the captured requests a rule applies to. Every set
+// field has to match; an empty matcher matches everything.
+type RequestMatcher struct {
+	// Method is compared case-insensitively
+	Method string `json:"method,omitempty"`
+	// Path is a glob (see path.Match) compared against the path captured after
+	// /r/{jarID}/
+	Path string `json:"path,omitempty"`
+	// Headers and Query map names to a value that one of the request's values
+	// for that name must equal exactly. Header names are case-insensitive.
+	Headers map[string]string `json:"headers,omitempty"`
+	Query   map[string]string `json:"query,omitempty"`
+	// JSONBody maps dotted field paths such as "event.type" or "items.0.id"
+	// to the value the field must have in a JSON request body
+	JSONBody map[string]string `json:"jsonBody,omitempty"`
+}
+
+// RequestFilter selects the captured requests a live connection is sent.
+// Every set field has to match; an empty filter matches everything.
+type RequestFilter struct {
+	// Methods lists the methods to accept, compared case-insensitively
+	Methods []string `json:"methods,omitempty"`
+	// PathPrefix is compared against the path captured after /r/{jarID}/
+	PathPrefix string `json:"pathPrefix,omitempty"`
+	// HeadersPresent lists headers the request must have, with any value
+	HeadersPresent []string `json:"headersPresent,omitempty"`
+	// Headers maps names to a value that one of the request's values for
+	// that header must equal exactly
+	Headers map[string]string `json:"headers,omitempty"`
+	// BodyContains is text the body must contain, after any content
+	// encoding is undone
+	BodyContains string `json:"bodyContains,omitempty"`
+	// JSONPath is a predicate on the JSON body, such as
+	// `$.event.type == "invoice.paid"` (see matcher.CompileJSONPath)
+	JSONPath string `json:"jsonPath,omitempty"`
+}
+
+// FaultConfig makes a jar misbehave on purpose so that webhook senders' timeout
+// and retry handling can be exercised. Rates are percentages from 0 to 100 and
+// are rolled independently for each captured request.
+type FaultConfig struct {
+	// Delay holds every response back by this long. If MaxDelay is also set,
+	// the delay is instead picked uniformly between Delay and MaxDelay.
+	Delay    Duration `json:"delay,omitempty"`
+	MaxDelay Duration `json:"maxDelay,omitempty"`
+	// ErrorRate of requests are answered with ErrorStatus (503 if unset)
+	// instead of the jar's mock response
+	ErrorRate   float64 `json:"errorRate,omitempty"`
+	ErrorStatus int     `json:"errorStatus,omitempty"`
+	// DropRate of connections are reset without any response
+	DropRate float64 `json:"dropRate,omitempty"`
+}
+
+// InjectedFault records what a FaultConfig did to one captured request.
+type InjectedFault struct {
+	Delay   Duration `json:"delay,omitempty"`
+	Status  int      `json:"status,omitempty"`
+	Dropped bool     `json:"dropped,omitempty"`
+}
+
+// Duration is a time.Duration that is written to JSON as a Go duration string
+// such as "36h" or "90s". When reading, a plain number is taken as seconds.
+type Duration time.Duration
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var seconds float64
+	if err := json.Unmarshal(data, &seconds); err == nil {
+		*d = Duration(seconds * float64(time.Second))
+		return nil
+	}
+
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("duration must be a string or a number of seconds")
+	}
+
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+
+	*d = Duration(parsed)
+	return nil
 }
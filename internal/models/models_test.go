@@ -1,6 +1,9 @@
 package models
 
 import (
+	"encoding/json"
+	"net/http"
+	"net/url"
 	"testing"
 	"time"
 )
@@ -17,3 +20,82 @@ func TestModelStructs(t *testing.T) {
 		t.Fatalf("unexpected request values: %+v", r)
 	}
 }
+
+func TestDurationJSON(t *testing.T) {
+	var p RetentionPolicy
+	err := json.Unmarshal([]byte(`{"maxAge":"36h"}`), &p)
+	if err != nil || time.Duration(p.MaxAge) != 36*time.Hour {
+		t.Fatalf("expected 36h, got %v (err %v)", time.Duration(p.MaxAge), err)
+	}
+
+	err = json.Unmarshal([]byte(`{"maxAge":90}`), &p)
+	if err != nil || time.Duration(p.MaxAge) != 90*time.Second {
+		t.Fatalf("expected 90s, got %v (err %v)", time.Duration(p.MaxAge), err)
+	}
+
+	err = json.Unmarshal([]byte(`{"maxAge":"soon"}`), &p)
+	if err == nil {
+		t.Fatal("expected an error for an invalid duration")
+	}
+
+	out, err := json.Marshal(RetentionPolicy{MaxAge: Duration(time.Minute)})
+	if err != nil || string(out) != `{"maxAge":"1m0s"}` {
+		t.Fatalf("unexpected encoding %s (err %v)", out, err)
+	}
+}
+
+func TestRequestJSON(t *testing.T) {
+	r := Request{
+		ID:       "r1",
+		Headers:  http.Header{"Set-Cookie": {"a=1", "b=2"}},
+		Query:    url.Values{"id": {"1", "2"}},
+		RawQuery: "id=1&id=2",
+	}
+
+	data, err := json.Marshal(r)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+
+	var fields map[string]json.RawMessage
+	_ = json.Unmarshal(data, &fields)
+	if string(fields["headerValues"]) != `{"Set-Cookie":["a=1","b=2"]}` || string(fields["queryValues"]) != `{"id":["1","2"]}` {
+		t.Fatalf("expected every value to be written, got %s", data)
+	}
+	if string(fields["headers"]) != `{"Set-Cookie":"a=1"}` || string(fields["query"]) != `{"id":"1"}` {
+		t.Fatalf("expected the legacy fields to hold the first values, got %s", data)
+	}
+
+	var decoded Request
+	err = json.Unmarshal(data, &decoded)
+	if err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	if len(decoded.Headers["Set-Cookie"]) != 2 || len(decoded.Query["id"]) != 2 || decoded.RawQuery != r.RawQuery {
+		t.Fatalf("request did not round-trip: %+v", decoded)
+	}
+}
+
+func TestRequestJSONReadsLegacyFields(t *testing.T) {
+	var r Request
+	err := json.Unmarshal([]byte(`{"id":"r1","headers":{"X-Test":"yes"},"query":{"q":"1"}}`), &r)
+	if err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+
+	if r.Headers.Get("X-Test") != "yes" || r.Query.Get("q") != "1" {
+		t.Fatalf("expected legacy headers and query to be read, got %+v", r)
+	}
+}
+
+func TestRequestURL(t *testing.T) {
+	captured := &Request{Host: "jars.example.com", RequestURI: "/r/jar/a?b=1", TLS: &TLSInfo{}}
+	if got := captured.URL("jar", "localhost"); got != "https://jars.example.com/r/jar/a?b=1" {
+		t.Errorf("unexpected URL %s", got)
+	}
+
+	legacy := &Request{Path: "a/b", RawQuery: "c=1"}
+	if got := legacy.URL("jar", "localhost:8080"); got != "http://localhost:8080/r/jar/a/b?c=1" {
+		t.Errorf("unexpected URL %s", got)
+	}
+}
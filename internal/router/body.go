@@ -0,0 +1,44 @@
+package router
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/bpietroniro/requestjar-go/internal/errors"
+)
+
+// DownloadRequestBody streams a captured request's raw body, including bodies
+// that were offloaded to blob storage.
+func (router *Router) DownloadRequestBody(w http.ResponseWriter, r *http.Request) {
+	jarID := r.PathValue("jarID")
+	reqID := r.PathValue("reqID")
+
+	req, body, err := router.svc.OpenRequestBody(jarID, reqID)
+	if err != nil {
+		slog.Error("failed to open request body", slog.String("jarID", jarID), slog.String("reqID", reqID), slog.Any("error", err))
+		errors.WriteHTTPError(w, err, "failed to open request body")
+		return
+	}
+	defer body.Close()
+
+	contentType := "application/octet-stream"
+	if req.BodyInfo != nil && req.BodyInfo.ContentType != "" {
+		contentType = req.BodyInfo.ContentType
+	}
+
+	// The body is whatever the caller sent, so keep browsers from rendering it
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", `attachment; filename="`+reqID+`"`)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Content-Length", strconv.FormatInt(req.BodySize(), 10))
+	if req.BodyInfo != nil {
+		w.Header().Set("ETag", `"`+req.BodyInfo.SHA256+`"`)
+	}
+
+	_, err = io.Copy(w, body)
+	if err != nil {
+		slog.Error("error streaming request body", slog.String("jarID", jarID), slog.String("reqID", reqID), slog.Any("error", err))
+	}
+}
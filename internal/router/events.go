@@ -0,0 +1,338 @@
+package router
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/bpietroniro/requestjar-go/internal/errors"
+	"github.com/bpietroniro/requestjar-go/internal/service"
+)
+
+const (
+	// sseRetry is how long browsers wait before reconnecting a dropped stream
+	sseRetry = 3 * time.Second
+	// resumePageSize is how many missed requests are read at a time when a
+	// client resumes a stream
+	resumePageSize = 100
+)
+
+// SetStreamTiming sets how often an idle event stream is sent a heartbeat, so
+// that proxies don't time it out, and how long a stream may stay open before
+// the client is asked to reconnect. Zero turns either off.
+func (router *Router) SetStreamTiming(heartbeat time.Duration, maxLifetime time.Duration) {
+	router.heartbeat = heartbeat
+	router.maxLifetime = maxLifetime
+}
+
+// streamTimers returns channels for the heartbeat ticks and the end of the
+// stream's lifetime, which never fire when they are turned off, and a function
+// to stop them.
+func (router *Router) streamTimers() (heartbeat <-chan time.Time, expired <-chan time.Time, stop func()) {
+	var ticker *time.Ticker
+	var timer *time.Timer
+
+	if router.heartbeat > 0 {
+		ticker = time.NewTicker(router.heartbeat)
+		heartbeat = ticker.C
+	}
+
+	if router.maxLifetime > 0 {
+		// Up to a tenth earlier, so streams opened together don't all
+		// reconnect together
+		lifetime := router.maxLifetime - rand.N(router.maxLifetime/10+1)
+		timer = time.NewTimer(lifetime)
+		expired = timer.C
+	}
+
+	stop = func() {
+		if ticker != nil {
+			ticker.Stop()
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+	}
+
+	return heartbeat, expired, stop
+}
+
+func (router *Router) HandleSSEConnection(w http.ResponseWriter, r *http.Request) {
+	jarID := r.PathValue("jarID")
+
+	slog.InfoContext(r.Context(), "adding new SSE connection", slog.String("jarID", jarID))
+
+	// Check that jar exists
+	_, err := router.svc.GetJarMetadata(jarID)
+	if err != nil {
+		slog.Error("jar not found", slog.String("jarID", jarID), slog.Any("error", err))
+		errors.WriteHTTPError(w, err, "jar not found")
+		return
+	}
+
+	filter, err := filterFromQuery(r.URL.Query())
+	if err != nil {
+		errors.WriteHTTPError(w, err, "invalid filter")
+		return
+	}
+
+	// Register the connection
+	conn, err := router.svc.AddConnection(jarID, filter)
+	if err != nil {
+		slog.Error("failed to add connection", slog.String("jarID", jarID), slog.Any("error", err))
+		if errors.Is(err, errors.ErrTooManyRequests) {
+			w.Header().Set("Retry-After", strconv.Itoa(int(sseRetry.Seconds())))
+		}
+		errors.WriteHTTPError(w, err, "failed to add connection")
+		return
+	}
+
+	// Clean up
+	defer func() {
+		slog.InfoContext(r.Context(), "removing SSE connection", slog.String("jarID", jarID), slog.Int64("dropped", conn.Dropped()))
+		router.svc.RemoveConnection(conn)
+	}()
+
+	// SSE headers
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		slog.WarnContext(r.Context(), "streaming unsupported, aborting new connection setup")
+		http.Error(w, "Streaming unsupported!", http.StatusInternalServerError)
+		return
+	}
+
+	_, err = fmt.Fprintf(w, "retry: %d\ndata: connected\n\n", sseRetry.Milliseconds())
+	if err != nil {
+		slog.Error("error writing connection response", slog.String("jarID", jarID), slog.Any("error", err))
+	}
+	flusher.Flush()
+
+	// Browsers send the ID of the last message they saw when they reconnect.
+	// The connection was registered before the missed requests are read, so
+	// none slip through in between; the ones sent here that also arrive as
+	// live events are skipped below.
+	replayed := replayedSet{}
+	lastID := r.Header.Get("Last-Event-ID")
+	if lastID != "" {
+		err = router.replayRequests(conn, jarID, lastID, replayed, func(event *service.Event) error {
+			return writeSSEEvent(w, event)
+		})
+		if err != nil {
+			slog.Error("error resuming stream", slog.String("jarID", jarID), slog.Any("error", err))
+			return
+		}
+		flusher.Flush()
+	}
+
+	// Client has disconnected
+	done := r.Context().Done()
+
+	heartbeat, expired, stopTimers := router.streamTimers()
+	defer stopTimers()
+
+	for {
+		select {
+		case event, ok := <-conn.Events:
+			// The service ended the connection
+			if !ok {
+				slog.Warn("connection closed, ending stream", slog.String("jarID", jarID), slog.String("reason", conn.CloseReason()))
+
+				// Slow clients can catch up by reconnecting
+				if conn.CloseReason() == service.CloseSlowConsumer {
+					_ = writeSSEReconnect(w, conn.CloseReason())
+					flusher.Flush()
+				}
+				return
+			}
+
+			if replayed.skip(event) {
+				continue
+			}
+
+			err = writeSSEEvent(w, event)
+			if err != nil {
+				slog.Error("error forwarding event", slog.String("jarID", jarID), slog.String("event", event.Type), slog.Any("error", err))
+				continue
+			}
+
+			flusher.Flush()
+		case <-heartbeat:
+			// A comment, which EventSource ignores
+			_, err = fmt.Fprint(w, ": heartbeat\n\n")
+			if err != nil {
+				slog.Debug("error writing heartbeat", slog.String("jarID", jarID), slog.Any("error", err))
+				return
+			}
+			flusher.Flush()
+		case <-expired:
+			slog.Debug("stream reached its maximum lifetime", slog.String("jarID", jarID))
+			_ = writeSSEReconnect(w, "maximum connection lifetime reached")
+			flusher.Flush()
+			return
+		case <-done:
+			slog.InfoContext(r.Context(), "Client disconnected", slog.String("jarID", jarID))
+			return
+		}
+	}
+}
+
+// replayedSet holds the IDs of requests that were sent from the store when a
+// stream was resumed, so that their live events aren't sent a second time.
+// IDs can't be compared with the last one replayed instead: a request is
+// occasionally stored after one with a later ID, and the replay wouldn't have
+// seen it.
+type replayedSet map[string]struct{}
+
+// skip reports whether event is the live event of a request that has already
+// been replayed. Live events arrive in ID order but for the rare request that
+// is stored late, so the IDs up to this one are forgotten: their live events
+// have arrived already, or weren't coming, as for requests captured before the
+// stream began.
+func (replayed replayedSet) skip(event *service.Event) bool {
+	if event.Type != service.EventRequestCreated {
+		return false
+	}
+
+	_, sent := replayed[event.Request.ID]
+	for id := range replayed {
+		if id <= event.Request.ID {
+			delete(replayed, id)
+		}
+	}
+
+	return sent
+}
+
+// takeBuffered takes the events waiting in conn's buffer, leaving out the live
+// events of requests that have already been replayed. It also returns the
+// highest request ID among them.
+func takeBuffered(conn *service.Connection, replayed replayedSet) (events []*service.Event, highWater string) {
+	for range len(conn.Events) {
+		event, ok := <-conn.Events
+		if !ok {
+			break
+		}
+
+		if replayed.skip(event) {
+			continue
+		}
+
+		events = append(events, event)
+		if event.Type == service.EventRequestCreated && event.Request.ID > highWater {
+			highWater = event.Request.ID
+		}
+	}
+
+	return events, highWater
+}
+
+// replayRequests passes send the requests captured after lastID that match
+// conn's filter as if they had just arrived, in ID order together with the
+// events already waiting in conn's buffer. Replayed requests above the highest
+// ID in the buffer may still arrive as live events, so they are added to
+// replayed.
+func (router *Router) replayRequests(conn *service.Connection, jarID string, lastID string, replayed replayedSet, send func(*service.Event) error) error {
+	buffered, highWater := takeBuffered(conn, replayed)
+
+	// A buffered event goes after every request with an ID up to the
+	// highest one that arrived live before it
+	positions := make([]string, len(buffered))
+	live := make(map[string]struct{})
+	position := ""
+	for i, event := range buffered {
+		if event.Type == service.EventRequestCreated {
+			live[event.Request.ID] = struct{}{}
+			position = max(position, event.Request.ID)
+		}
+		positions[i] = position
+	}
+
+	next := 0
+	sendBuffered := func(before string) error {
+		for ; next < len(buffered) && (before == "" || positions[next] < before); next++ {
+			err := send(buffered[next])
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	filter := conn.Filter()
+	for {
+		requests, err := router.svc.ListRequests(jarID, lastID, resumePageSize)
+		if err != nil {
+			return err
+		}
+
+		for _, req := range requests {
+			lastID = req.ID
+
+			// Requests whose live events are buffered are sent with them
+			if _, arrived := live[req.ID]; arrived || !filter.Matches(req) {
+				continue
+			}
+
+			err = sendBuffered(req.ID)
+			if err != nil {
+				return err
+			}
+
+			err = send(service.RequestCreatedEvent(jarID, req))
+			if err != nil {
+				return err
+			}
+			if req.ID > highWater {
+				replayed[req.ID] = struct{}{}
+			}
+		}
+
+		if len(requests) < resumePageSize {
+			slog.Debug("replayed missed requests", slog.String("jarID", jarID), slog.String("lastID", lastID))
+			return sendBuffered("")
+		}
+	}
+}
+
+// writeSSEReconnect tells a client that the server is about to end the stream
+// and that it should reconnect straight away, resuming from the last message
+// it saw.
+func writeSSEReconnect(w io.Writer, reason string) error {
+	data, err := json.Marshal(map[string]string{"reason": reason})
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(w, "retry: 0\nevent: reconnect\ndata: %s\n\n", data)
+	return err
+}
+
+// writeSSEEvent forwards an event to an SSE client as a message named after
+// the event's type, carrying the whole event. Captured requests also use the
+// request's ID as the message ID so that clients can resume after it.
+func writeSSEEvent(w io.Writer, event *service.Event) error {
+	eventJson, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	if event.Type == service.EventRequestCreated {
+		slog.Debug("sending request through channel", slog.String("reqID", event.Request.ID))
+		_, err = fmt.Fprintf(w, "id: %s\n", event.Request.ID)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, eventJson)
+	return err
+}
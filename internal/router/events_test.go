@@ -0,0 +1,236 @@
+package router
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bpietroniro/requestjar-go/internal/models"
+	"github.com/bpietroniro/requestjar-go/internal/service"
+)
+
+// sseMessage is one message of an event stream, or a comment
+type sseMessage struct {
+	id      string
+	event   string
+	data    string
+	comment string
+}
+
+// openStream subscribes to a jar's event stream, resuming after lastID if it
+// is set, and returns it once the connection has been registered.
+func openStream(t *testing.T, router *Router, jarID string, lastID string) *bufio.Reader {
+	t.Helper()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /jars/{jarID}/events", router.HandleSSEConnection)
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	// Also ends the stream, before the server is closed
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	t.Cleanup(cancel)
+
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"/jars/"+jarID+"/events", nil)
+	if lastID != "" {
+		req.Header.Set("Last-Event-ID", lastID)
+	}
+
+	resp, err := server.Client().Do(req)
+	if err != nil {
+		t.Fatalf("opening stream: %v", err)
+	}
+	t.Cleanup(func() { _ = resp.Body.Close() })
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected the stream to open, got %s", resp.Status)
+	}
+
+	stream := bufio.NewReader(resp.Body)
+	// Sent once the connection is registered
+	if msg := readMessage(t, stream); msg.data != "connected" {
+		t.Fatalf("expected the connected message, got %+v", msg)
+	}
+
+	return stream
+}
+
+// readMessage reads the next message from an event stream.
+func readMessage(t *testing.T, stream *bufio.Reader) sseMessage {
+	t.Helper()
+
+	var msg sseMessage
+	for {
+		line, err := stream.ReadString('\n')
+		if err != nil {
+			t.Fatalf("reading stream: %v", err)
+		}
+
+		line = strings.TrimSuffix(line, "\n")
+		if line == "" {
+			return msg
+		}
+
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+
+		switch field {
+		case "":
+			msg.comment = value
+		case "id":
+			msg.id = value
+		case "event":
+			msg.event = value
+		case "data":
+			msg.data = value
+		}
+	}
+}
+
+// requireRequest fails the test unless msg is the capture of reqID.
+func requireRequest(t *testing.T, msg sseMessage, reqID string) {
+	t.Helper()
+
+	if msg.event != service.EventRequestCreated || msg.id != reqID {
+		t.Fatalf("expected request %s, got %+v", reqID, msg)
+	}
+}
+
+func TestSSEDeliversCapturedRequests(t *testing.T) {
+	router, svc := newTestRouter(t)
+	jarID, _ := svc.CreateJar(&models.Jar{Name: "jar"})
+
+	stream := openStream(t, router, jarID, "")
+
+	req := &models.Request{Method: "POST", Path: "hooks"}
+	_, _ = svc.NewRequest(context.Background(), jarID, req)
+
+	msg := readMessage(t, stream)
+	requireRequest(t, msg, req.ID)
+	if !strings.Contains(msg.data, `"path":"hooks"`) {
+		t.Fatalf("expected the request in the message, got %s", msg.data)
+	}
+}
+
+func TestSSEResumesAfterLastEventID(t *testing.T) {
+	router, svc := newTestRouter(t)
+	jarID, _ := svc.CreateJar(&models.Jar{Name: "jar"})
+
+	seen := &models.Request{Method: "GET"}
+	missed := &models.Request{Method: "GET"}
+	_, _ = svc.NewRequest(context.Background(), jarID, seen)
+	_, _ = svc.NewRequest(context.Background(), jarID, missed)
+
+	stream := openStream(t, router, jarID, seen.ID)
+	requireRequest(t, readMessage(t, stream), missed.ID)
+
+	// Live requests follow, without the replayed one being sent again
+	live := &models.Request{Method: "GET"}
+	_, _ = svc.NewRequest(context.Background(), jarID, live)
+	requireRequest(t, readMessage(t, stream), live.ID)
+
+	// A request stored after one with a later ID is still delivered
+	late := &models.Request{ID: seen.ID + "0", Method: "GET"}
+	_, _ = svc.NewRequest(context.Background(), jarID, late)
+	requireRequest(t, readMessage(t, stream), late.ID)
+}
+
+func TestSSESendsHeartbeats(t *testing.T) {
+	router, svc := newTestRouter(t)
+	router.SetStreamTiming(10*time.Millisecond, 0)
+	jarID, _ := svc.CreateJar(&models.Jar{Name: "jar"})
+
+	stream := openStream(t, router, jarID, "")
+
+	if msg := readMessage(t, stream); msg.comment != "heartbeat" {
+		t.Fatalf("expected a heartbeat, got %+v", msg)
+	}
+}
+
+func TestSSEAsksClientsToReconnect(t *testing.T) {
+	router, svc := newTestRouter(t)
+	router.SetStreamTiming(0, 20*time.Millisecond)
+	jarID, _ := svc.CreateJar(&models.Jar{Name: "jar"})
+
+	stream := openStream(t, router, jarID, "")
+
+	msg := readMessage(t, stream)
+	if msg.event != "reconnect" || !strings.Contains(msg.data, "maximum connection lifetime reached") {
+		t.Fatalf("expected a reconnect message, got %+v", msg)
+	}
+
+	if _, err := stream.ReadString('\n'); err == nil {
+		t.Fatal("expected the stream to end")
+	}
+}
+
+func TestReplayIsMergedWithBufferedEvents(t *testing.T) {
+	router, svc := newTestRouter(t)
+	jarID, _ := svc.CreateJar(&models.Jar{Name: "jar"})
+
+	// Only in the store
+	early := &models.Request{Method: "GET"}
+	_, _ = svc.NewRequest(context.Background(), jarID, early)
+
+	conn, _ := svc.AddConnection(jarID, nil)
+	defer svc.RemoveConnection(conn)
+
+	// Both in the store and in the connection's buffer
+	live := &models.Request{Method: "GET"}
+	_, _ = svc.NewRequest(context.Background(), jarID, live)
+	_, _ = svc.SetMockResponse(jarID, &models.MockResponse{StatusCode: 204})
+	later := &models.Request{Method: "GET"}
+	_, _ = svc.NewRequest(context.Background(), jarID, later)
+
+	var sent []string
+	replayed := replayedSet{}
+	err := router.replayRequests(conn, jarID, "", replayed, func(event *service.Event) error {
+		if event.Request != nil {
+			sent = append(sent, event.Request.ID)
+		} else {
+			sent = append(sent, event.Type)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("replayRequests: %v", err)
+	}
+
+	want := []string{early.ID, live.ID, service.EventJarUpdated, later.ID}
+	if !slices.Equal(sent, want) {
+		t.Fatalf("expected %v, got %v", want, sent)
+	}
+	if len(replayed) != 0 || len(conn.Events) != 0 {
+		t.Fatalf("expected nothing left to skip or send, got %v and %d events", replayed, len(conn.Events))
+	}
+}
+
+func TestReplayedRequestsAreForgottenOnceLaterOnesArrive(t *testing.T) {
+	router, svc := newTestRouter(t)
+	jarID, _ := svc.CreateJar(&models.Jar{Name: "jar"})
+
+	// Captured before the stream began, so no live event will follow
+	early := &models.Request{Method: "GET"}
+	_, _ = svc.NewRequest(context.Background(), jarID, early)
+
+	conn, _ := svc.AddConnection(jarID, nil)
+	defer svc.RemoveConnection(conn)
+
+	replayed := replayedSet{}
+	_ = router.replayRequests(conn, jarID, "", replayed, func(*service.Event) error { return nil })
+
+	live := &models.Request{Method: "GET"}
+	_, _ = svc.NewRequest(context.Background(), jarID, live)
+
+	if replayed.skip(<-conn.Events) {
+		t.Fatal("expected the new request to be sent")
+	}
+	if len(replayed) != 0 {
+		t.Fatalf("expected the replayed request to be forgotten, got %v", replayed)
+	}
+}
@@ -0,0 +1,114 @@
+package router
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/bpietroniro/requestjar-go/internal/errors"
+	"github.com/bpietroniro/requestjar-go/internal/models"
+	"github.com/bpietroniro/requestjar-go/internal/util"
+)
+
+func (router *Router) GetFaults(w http.ResponseWriter, r *http.Request) {
+	jarID := r.PathValue("jarID")
+
+	jar, err := router.svc.GetJarMetadata(jarID)
+	if err != nil {
+		slog.Error("failed to retrieve jar", slog.String("jarID", jarID), slog.Any("error", err))
+		errors.WriteHTTPError(w, err, "failed to retrieve jar")
+		return
+	}
+
+	faults := jar.Faults
+	if faults == nil {
+		faults = &models.FaultConfig{}
+	}
+
+	util.WriteJSON(w, http.StatusOK, faults)
+}
+
+func (router *Router) SetFaults(w http.ResponseWriter, r *http.Request) {
+	jarID := r.PathValue("jarID")
+
+	var reqBody models.FaultConfig
+
+	err := json.NewDecoder(r.Body).Decode(&reqBody)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "failed to parse request body")
+		http.Error(w, "error parsing request", http.StatusBadRequest)
+		return
+	}
+
+	jar, err := router.svc.SetFaults(jarID, &reqBody)
+	if err != nil {
+		slog.Error("failed to set fault injection", slog.String("jarID", jarID), slog.Any("error", err))
+		errors.WriteHTTPError(w, err, "failed to set fault injection")
+		return
+	}
+
+	slog.Info("fault injection updated", slog.String("jarID", jarID))
+	util.WriteJSON(w, http.StatusOK, jar.Faults)
+}
+
+func (router *Router) DeleteFaults(w http.ResponseWriter, r *http.Request) {
+	jarID := r.PathValue("jarID")
+
+	_, err := router.svc.SetFaults(jarID, nil)
+	if err != nil {
+		slog.Error("failed to disable fault injection", slog.String("jarID", jarID), slog.Any("error", err))
+		errors.WriteHTTPError(w, err, "failed to disable fault injection")
+		return
+	}
+
+	slog.Info("fault injection disabled", slog.String("jarID", jarID))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// applyFault carries out the delay and connection drop recorded on a captured
+// request. It reports whether the caller should still write a response.
+func applyFault(w http.ResponseWriter, r *http.Request, fault *models.InjectedFault) bool {
+	if fault == nil {
+		return true
+	}
+
+	if fault.Delay > 0 {
+		timer := time.NewTimer(time.Duration(fault.Delay))
+		defer timer.Stop()
+
+		select {
+		case <-timer.C:
+		case <-r.Context().Done():
+			return false
+		}
+	}
+
+	if fault.Dropped {
+		dropConnection(w)
+		return false
+	}
+
+	return true
+}
+
+// dropConnection resets the client's connection without sending a response.
+func dropConnection(w http.ResponseWriter) {
+	conn, _, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		// Not hijackable (e.g. HTTP/2): abort the stream instead
+		slog.Debug("hijacking unsupported, aborting handler", slog.Any("error", err))
+		panic(http.ErrAbortHandler)
+	}
+
+	// With a zero linger the close sends a RST rather than a FIN
+	if tcp, ok := conn.(*net.TCPConn); ok {
+		_ = tcp.SetLinger(0)
+	}
+
+	err = conn.Close()
+	if err != nil {
+		slog.Error("error dropping connection", slog.Any("error", err))
+	}
+}
@@ -0,0 +1,61 @@
+package router
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/bpietroniro/requestjar-go/internal/errors"
+	"github.com/bpietroniro/requestjar-go/internal/matcher"
+	"github.com/bpietroniro/requestjar-go/internal/models"
+)
+
+// filterFromQuery reads a request filter from query parameters:
+//
+//	method=POST           repeatable, or comma-separated; any of them
+//	pathPrefix=/hooks     the captured path starts with this
+//	header=X-Service      repeatable; the header is present
+//	header=X-Service:api  repeatable; the header has this value
+//	bodyContains=text     the (decoded) body contains this
+//	jsonPath=$.type=="a"  a JSONPath predicate on the JSON body
+//
+// It returns nil when no filter parameters are set.
+func filterFromQuery(query url.Values) (*matcher.Filter, error) {
+	spec := &models.RequestFilter{
+		PathPrefix:   query.Get("pathPrefix"),
+		BodyContains: query.Get("bodyContains"),
+		JSONPath:     query.Get("jsonPath"),
+	}
+
+	for _, methods := range query["method"] {
+		for _, method := range strings.Split(methods, ",") {
+			if method = strings.TrimSpace(method); method != "" {
+				spec.Methods = append(spec.Methods, method)
+			}
+		}
+	}
+
+	for _, header := range query["header"] {
+		name, value, hasValue := strings.Cut(header, ":")
+		name = strings.TrimSpace(name)
+		if name == "" {
+			return nil, errors.BadRequest("header filters must name a header")
+		}
+
+		if !hasValue {
+			spec.HeadersPresent = append(spec.HeadersPresent, name)
+			continue
+		}
+
+		if spec.Headers == nil {
+			spec.Headers = map[string]string{}
+		}
+		spec.Headers[name] = strings.TrimSpace(value)
+	}
+
+	filter, err := matcher.NewFilter(spec)
+	if err != nil {
+		return nil, errors.BadRequest(err.Error())
+	}
+
+	return filter, nil
+}
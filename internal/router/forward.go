@@ -0,0 +1,66 @@
+package router
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+
+	"github.com/bpietroniro/requestjar-go/internal/errors"
+	"github.com/bpietroniro/requestjar-go/internal/models"
+	"github.com/bpietroniro/requestjar-go/internal/util"
+)
+
+func (router *Router) GetForward(w http.ResponseWriter, r *http.Request) {
+	jarID := r.PathValue("jarID")
+
+	jar, err := router.svc.GetJarMetadata(jarID)
+	if err != nil {
+		slog.Error("failed to retrieve jar", slog.String("jarID", jarID), slog.Any("error", err))
+		errors.WriteHTTPError(w, err, "failed to retrieve jar")
+		return
+	}
+
+	forward := jar.Forward
+	if forward == nil {
+		forward = &models.ForwardConfig{Targets: []string{}}
+	}
+
+	util.WriteJSON(w, http.StatusOK, forward)
+}
+
+func (router *Router) SetForward(w http.ResponseWriter, r *http.Request) {
+	jarID := r.PathValue("jarID")
+
+	var reqBody models.ForwardConfig
+
+	err := json.NewDecoder(r.Body).Decode(&reqBody)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "failed to parse request body")
+		http.Error(w, "error parsing request", http.StatusBadRequest)
+		return
+	}
+
+	jar, err := router.svc.SetForward(jarID, &reqBody)
+	if err != nil {
+		slog.Error("failed to set forwarding", slog.String("jarID", jarID), slog.Any("error", err))
+		errors.WriteHTTPError(w, err, "failed to set forwarding")
+		return
+	}
+
+	slog.Info("forwarding updated", slog.String("jarID", jarID))
+	util.WriteJSON(w, http.StatusOK, jar.Forward)
+}
+
+func (router *Router) DeleteForward(w http.ResponseWriter, r *http.Request) {
+	jarID := r.PathValue("jarID")
+
+	_, err := router.svc.SetForward(jarID, nil)
+	if err != nil {
+		slog.Error("failed to disable forwarding", slog.String("jarID", jarID), slog.Any("error", err))
+		errors.WriteHTTPError(w, err, "failed to disable forwarding")
+		return
+	}
+
+	slog.Info("forwarding disabled", slog.String("jarID", jarID))
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,84 @@
+package router
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+
+	"github.com/bpietroniro/requestjar-go/internal/errors"
+	"github.com/bpietroniro/requestjar-go/internal/har"
+	"github.com/bpietroniro/requestjar-go/internal/util"
+)
+
+// maxImportBytes caps the size of an uploaded HAR file
+const maxImportBytes = 100 << 20
+
+// ExportJar downloads a jar's requests in the format named by ?format=.
+// HAR is the only format so far.
+func (router *Router) ExportJar(w http.ResponseWriter, r *http.Request) {
+	jarID := r.PathValue("jarID")
+
+	format := r.URL.Query().Get("format")
+	if format != "" && format != "har" {
+		http.Error(w, "unsupported export format", http.StatusBadRequest)
+		return
+	}
+
+	download := &downloadWriter{w: w, filename: jarID + ".har"}
+
+	err := router.svc.ExportHAR(download, jarID, r.Host)
+	if err != nil && !download.started {
+		slog.Error("failed to export jar", slog.String("jarID", jarID), slog.Any("error", err))
+		errors.WriteHTTPError(w, err, "failed to export jar")
+		return
+	}
+	if err != nil {
+		// Part of the archive has been sent, so the status can't change; cut
+		// the response short so that the client doesn't take it as complete
+		slog.Error("failed partway through exporting jar", slog.String("jarID", jarID), slog.Any("error", err))
+		panic(http.ErrAbortHandler)
+	}
+}
+
+// downloadWriter sends the headers of a JSON file download ahead of the first
+// write, so that an error before then can still be answered with an error
+// status instead.
+type downloadWriter struct {
+	w        http.ResponseWriter
+	filename string
+	started  bool
+}
+
+func (d *downloadWriter) Write(p []byte) (int, error) {
+	if !d.started {
+		d.started = true
+		d.w.Header().Set("Content-Type", "application/json")
+		d.w.Header().Set("Content-Disposition", `attachment; filename="`+d.filename+`"`)
+		d.w.WriteHeader(http.StatusOK)
+	}
+
+	return d.w.Write(p)
+}
+
+// ImportJar creates a jar from an uploaded HAR file, named by ?name=.
+func (router *Router) ImportJar(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxImportBytes)
+
+	var archive har.HAR
+
+	err := json.NewDecoder(r.Body).Decode(&archive)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "failed to parse HAR", slog.Any("error", err))
+		http.Error(w, "error parsing HAR", http.StatusBadRequest)
+		return
+	}
+
+	jarID, count, err := router.svc.ImportHAR(r.URL.Query().Get("name"), &archive)
+	if err != nil {
+		slog.Error("failed to import HAR", slog.Any("error", err))
+		errors.WriteHTTPError(w, err, "failed to import HAR")
+		return
+	}
+
+	util.WriteJSON(w, http.StatusCreated, ImportJarResponse{ID: jarID, Requests: count})
+}
@@ -0,0 +1,57 @@
+package router
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+
+	"github.com/bpietroniro/requestjar-go/internal/errors"
+	"github.com/bpietroniro/requestjar-go/internal/service"
+	"github.com/bpietroniro/requestjar-go/internal/util"
+)
+
+func (router *Router) ReplayRequest(w http.ResponseWriter, r *http.Request) {
+	jarID := r.PathValue("jarID")
+	reqID := r.PathValue("reqID")
+
+	var reqBody ReplayRequestRequest
+
+	err := json.NewDecoder(r.Body).Decode(&reqBody)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "failed to parse request body")
+		http.Error(w, "error parsing request", http.StatusBadRequest)
+		return
+	}
+
+	opts := service.ReplayOptions{
+		Target:  reqBody.Target,
+		Method:  reqBody.Method,
+		Headers: reqBody.Headers,
+	}
+	if reqBody.Body != nil {
+		opts.Body = []byte(*reqBody.Body)
+	}
+
+	replay, err := router.svc.ReplayRequest(r.Context(), jarID, reqID, opts)
+	if err != nil {
+		slog.Error("failed to replay request", slog.String("jarID", jarID), slog.String("reqID", reqID), slog.Any("error", err))
+		errors.WriteHTTPError(w, err, "failed to replay request")
+		return
+	}
+
+	util.WriteJSON(w, http.StatusCreated, replay)
+}
+
+func (router *Router) ListReplays(w http.ResponseWriter, r *http.Request) {
+	jarID := r.PathValue("jarID")
+	reqID := r.PathValue("reqID")
+
+	replays, err := router.svc.ListReplays(jarID, reqID)
+	if err != nil {
+		slog.Error("failed to list replays", slog.String("jarID", jarID), slog.String("reqID", reqID), slog.Any("error", err))
+		errors.WriteHTTPError(w, err, "failed to list replays")
+		return
+	}
+
+	util.WriteJSON(w, http.StatusOK, replays)
+}
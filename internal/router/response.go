@@ -0,0 +1,103 @@
+package router
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/bpietroniro/requestjar-go/internal/errors"
+	"github.com/bpietroniro/requestjar-go/internal/models"
+	"github.com/bpietroniro/requestjar-go/internal/util"
+)
+
+func (router *Router) GetMockResponse(w http.ResponseWriter, r *http.Request) {
+	jarID := r.PathValue("jarID")
+
+	jar, err := router.svc.GetJarMetadata(jarID)
+	if err != nil {
+		slog.Error("failed to retrieve jar", slog.String("jarID", jarID), slog.Any("error", err))
+		errors.WriteHTTPError(w, err, "failed to retrieve jar")
+		return
+	}
+
+	response := jar.Response
+	if response == nil {
+		response = &models.MockResponse{StatusCode: http.StatusOK}
+	}
+
+	util.WriteJSON(w, http.StatusOK, response)
+}
+
+func (router *Router) SetMockResponse(w http.ResponseWriter, r *http.Request) {
+	jarID := r.PathValue("jarID")
+
+	var reqBody models.MockResponse
+
+	err := json.NewDecoder(r.Body).Decode(&reqBody)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "failed to parse request body")
+		http.Error(w, "error parsing request", http.StatusBadRequest)
+		return
+	}
+
+	jar, err := router.svc.SetMockResponse(jarID, &reqBody)
+	if err != nil {
+		slog.Error("failed to set mock response", slog.String("jarID", jarID), slog.Any("error", err))
+		errors.WriteHTTPError(w, err, "failed to set mock response")
+		return
+	}
+
+	slog.Info("mock response updated", slog.String("jarID", jarID))
+	util.WriteJSON(w, http.StatusOK, jar.Response)
+}
+
+func (router *Router) DeleteMockResponse(w http.ResponseWriter, r *http.Request) {
+	jarID := r.PathValue("jarID")
+
+	_, err := router.svc.SetMockResponse(jarID, nil)
+	if err != nil {
+		slog.Error("failed to reset mock response", slog.String("jarID", jarID), slog.Any("error", err))
+		errors.WriteHTTPError(w, err, "failed to reset mock response")
+		return
+	}
+
+	slog.Info("mock response reset", slog.String("jarID", jarID))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// writeMockResponse sends a jar's configured response to a captured request's
+// caller. A nil response is an empty 200. Statuses that can't have a body are
+// sent without one.
+func writeMockResponse(w http.ResponseWriter, response *models.MockResponse) {
+	if response == nil {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	for name, value := range response.Headers {
+		w.Header().Set(name, value)
+	}
+
+	if response.ContentType != "" {
+		w.Header().Set("Content-Type", response.ContentType)
+	}
+
+	status := response.StatusCode
+	if status == 0 {
+		status = http.StatusOK
+	}
+
+	if !response.AllowsBody() {
+		w.WriteHeader(status)
+		return
+	}
+
+	w.Header().Set("Content-Length", strconv.Itoa(len(response.Body)))
+	w.WriteHeader(status)
+
+	_, err := w.Write([]byte(response.Body))
+	if err != nil {
+		slog.Error("error writing mock response", slog.Any("error", err))
+	}
+}
@@ -1,11 +1,13 @@
 package router
 
 import (
+	"crypto/tls"
 	"encoding/json"
 	"fmt"
 	"io"
 	"log/slog"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/bpietroniro/requestjar-go/internal/errors"
@@ -16,6 +18,14 @@ import (
 
 type Router struct {
 	svc *service.JarService
+
+	// Event stream timing; see events.go
+	heartbeat   time.Duration
+	maxLifetime time.Duration
+
+	// wsOrigins are the other hosts browsers may open WebSockets from; see
+	// websocket.go
+	wsOrigins []string
 }
 
 func CreateRouter(svc *service.JarService) *Router {
@@ -33,7 +43,13 @@ func (router *Router) CreateJar(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	newJarID, err := router.svc.CreateJar(reqBody.Name)
+	jar := &models.Jar{Name: reqBody.Name, Retention: reqBody.Retention, MaxBodySize: reqBody.MaxBodySize}
+	if reqBody.TTL != nil {
+		expiresAt := time.Now().Add(time.Duration(*reqBody.TTL))
+		jar.ExpiresAt = &expiresAt
+	}
+
+	newJarID, err := router.svc.CreateJar(jar)
 	if err != nil {
 		slog.Error("failed to create jar", slog.Any("error", err))
 		errors.WriteHTTPError(w, err, "failed to create jar")
@@ -62,6 +78,46 @@ func (router *Router) DeleteJar(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusNoContent)
 }
 
+func (router *Router) ExtendJar(w http.ResponseWriter, r *http.Request) {
+	jarID := r.PathValue("jarID")
+
+	var reqBody ExtendJarRequest
+
+	err := json.NewDecoder(r.Body).Decode(&reqBody)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "failed to parse request body")
+		http.Error(w, "error parsing request", http.StatusBadRequest)
+		return
+	}
+
+	given := 0
+	for _, set := range []bool{reqBody.TTL != nil, reqBody.ExpiresAt != nil, reqBody.Permanent} {
+		if set {
+			given++
+		}
+	}
+	if given != 1 {
+		http.Error(w, "exactly one of ttl, expiresAt and permanent must be set", http.StatusBadRequest)
+		return
+	}
+
+	expiresAt := reqBody.ExpiresAt
+	if reqBody.TTL != nil {
+		t := time.Now().Add(time.Duration(*reqBody.TTL))
+		expiresAt = &t
+	}
+
+	jar, err := router.svc.ExtendJar(jarID, expiresAt)
+	if err != nil {
+		slog.Error("failed to extend jar", slog.String("jarID", jarID), slog.Any("error", err))
+		errors.WriteHTTPError(w, err, "failed to extend jar")
+		return
+	}
+
+	slog.Info("jar expiry changed", slog.String("jarID", jarID))
+	util.WriteJSON(w, http.StatusOK, jar)
+}
+
 func (router *Router) GetAllJarMetadata(w http.ResponseWriter, r *http.Request) {
 	jars, err := router.svc.ListAllJarMetadata()
 
@@ -89,6 +145,20 @@ func (router *Router) DeleteRequest(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// ClearJar deletes every request in a jar but keeps the jar.
+func (router *Router) ClearJar(w http.ResponseWriter, r *http.Request) {
+	jarID := r.PathValue("jarID")
+
+	err := router.svc.ClearJar(jarID)
+	if err != nil {
+		slog.Error("failed to clear jar", slog.String("jarID", jarID), slog.Any("error", err))
+		errors.WriteHTTPError(w, err, "failed to clear jar")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
 func (router *Router) GetJarWithRequests(w http.ResponseWriter, r *http.Request) {
 	jarID := r.PathValue("jarID")
 
@@ -108,98 +178,64 @@ func (router *Router) GetJarWithRequests(w http.ResponseWriter, r *http.Request)
 	util.WriteJSON(w, http.StatusCreated, resp)
 }
 
-func (router *Router) HandleSSEConnection(w http.ResponseWriter, r *http.Request) {
-	jarID := r.PathValue("jarID")
+const (
+	defaultPageSize = 100
+	maxPageSize     = 1000
+)
 
-	slog.InfoContext(r.Context(), "adding new SSE connection", slog.String("jarID", jarID))
+func (router *Router) ListRequests(w http.ResponseWriter, r *http.Request) {
+	jarID := r.PathValue("jarID")
+	after := r.URL.Query().Get("after")
 
-	// Check that jar exists
-	_, err := router.svc.GetJarMetadata(jarID)
-	if err != nil {
-		slog.Error("jar not found", slog.String("jarID", jarID), slog.Any("error", err))
-		errors.WriteHTTPError(w, err, "jar not found")
-		return
+	limit := defaultPageSize
+	if l := r.URL.Query().Get("limit"); l != "" {
+		parsed, err := strconv.Atoi(l)
+		if err != nil || parsed < 1 || parsed > maxPageSize {
+			http.Error(w, fmt.Sprintf("limit must be between 1 and %d", maxPageSize), http.StatusBadRequest)
+			return
+		}
+		limit = parsed
 	}
 
-	// SSE headers
-	w.Header().Set("Content-Type", "text/event-stream")
-	w.Header().Set("Cache-Control", "no-cache")
-	w.Header().Set("Connection", "keep-alive")
-
-	flusher, ok := w.(http.Flusher)
-	if !ok {
-		slog.WarnContext(r.Context(), "streaming unsupported, aborting new connection setup")
-		http.Error(w, "Streaming unsupported!", http.StatusInternalServerError)
+	requests, err := router.svc.ListRequests(jarID, after, limit)
+	if err != nil {
+		slog.Error("failed to list requests", slog.String("jarID", jarID), slog.Any("error", err))
+		errors.WriteHTTPError(w, err, "failed to list requests")
 		return
 	}
 
-	// Create a channel for this requesting client
-	eventChan := make(chan *models.Request)
-
-	// Register the connection
-	err = router.svc.AddConnection(jarID, eventChan)
-	if err != nil {
-		slog.Error("failed to add connection", slog.String("jarID", jarID), slog.Any("error", err))
+	resp := ListRequestsResponse{Requests: requests}
+	if len(requests) == limit {
+		resp.NextCursor = requests[len(requests)-1].ID
 	}
 
-	// Clean up
-	defer func() {
-		slog.InfoContext(r.Context(), "removing SSE connection", slog.String("jarID", jarID))
-
-		err = router.svc.RemoveConnection(jarID, eventChan)
-		if err != nil {
-			slog.Error("failed to remove connection", slog.String("jarID", jarID), slog.Any("error", err))
-		}
-
-		close(eventChan)
-	}()
-
-	_, err = fmt.Fprintf(w, "data: connected\n\n")
-	if err != nil {
-		slog.Error("error writing connection response", slog.String("jarID", jarID), slog.Any("error", err))
-	}
-	flusher.Flush()
-
-	// Client has disconnected
-	done := r.Context().Done()
-
-	for {
-		select {
-		case request, ok := <-eventChan:
-			// Channel was closed and likely deleted
-			if !ok {
-				slog.Warn("Channel closed, ending connection")
-				return
-			}
-
-			// Forward incoming request event to the client
-			requestJson, err := json.Marshal(request)
-			if err != nil {
-				slog.Error("error marshaling request", slog.Any("error", err))
-				continue
-			}
-
-			slog.Debug("sending request through channel", slog.String("jarID", jarID), slog.String("reqID", request.ID))
-			_, err = fmt.Fprintf(w, "data: %s\n\n", requestJson)
-			if err != nil {
-				slog.Error("error forwarding request", slog.String("jarID", jarID), slog.Any("error", err))
-				continue
-			}
-
-			flusher.Flush()
-		case <-done:
-			slog.InfoContext(r.Context(), "Client disconnected", slog.String("jarID", jarID))
-			return
-		}
-	}
+	util.WriteJSON(w, http.StatusOK, resp)
 }
 
 func (router *Router) CaptureRequest(w http.ResponseWriter, r *http.Request) {
 	jarID := r.PathValue("jarID")
 	path := r.PathValue(("path"))
 
+	limit, err := router.svc.BodyLimit(jarID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "failed to look up jar", slog.String("jarID", jarID), slog.Any("error", err))
+		errors.WriteHTTPError(w, err, "failed to create new request")
+		return
+	}
+
+	if limit > 0 {
+		r.Body = http.MaxBytesReader(w, r.Body, limit)
+	}
+
 	body, err := io.ReadAll(r.Body)
 
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		slog.InfoContext(r.Context(), "request body too large", slog.String("jarID", jarID), slog.Int64("limit", limit))
+		http.Error(w, fmt.Sprintf("request body exceeds the jar's limit of %d bytes", limit), http.StatusRequestEntityTooLarge)
+		return
+	}
+
 	if err != nil {
 		slog.ErrorContext(r.Context(), "failed to read request body")
 		http.Error(w, "Failed to read body", http.StatusInternalServerError) // TODO check correct status code
@@ -213,27 +249,29 @@ func (router *Router) CaptureRequest(w http.ResponseWriter, r *http.Request) {
 		}
 	}()
 
-	headers := make(map[string]string)
-	for key, values := range r.Header {
-		headers[key] = values[0] // TODO verify this
-	}
-
-	query := make(map[string]string)
-	for key, values := range r.URL.Query() {
-		query[key] = values[0] // TODO verify this
+	req := &models.Request{
+		CreatedAt:        time.Now(),
+		Method:           r.Method,
+		Path:             path,
+		Headers:          r.Header.Clone(),
+		Query:            r.URL.Query(),
+		RawQuery:         r.URL.RawQuery,
+		Body:             body,
+		ClientIP:         r.RemoteAddr,
+		Proto:            r.Proto,
+		Host:             r.Host,
+		RequestURI:       r.RequestURI,
+		ContentLength:    r.ContentLength,
+		TransferEncoding: r.TransferEncoding,
+		TLS:              tlsInfo(r.TLS),
 	}
 
-	req := &models.Request{
-		CreatedAt: time.Now(),
-		Method:    r.Method,
-		Path:      path,
-		Headers:   headers,
-		Query:     query,
-		Body:      body,
-		ClientIP:  r.RemoteAddr,
+	// Trailer values are only filled in once the body has been read
+	if len(r.Trailer) > 0 {
+		req.Trailers = r.Trailer.Clone()
 	}
 
-	err = router.svc.NewRequest(jarID, req)
+	response, err := router.svc.NewRequest(r.Context(), jarID, req)
 
 	if err != nil {
 		slog.ErrorContext(r.Context(), "failed to create new request", slog.String("jarID", jarID))
@@ -242,4 +280,30 @@ func (router *Router) CaptureRequest(w http.ResponseWriter, r *http.Request) {
 	}
 
 	slog.Info("request successfully captured", slog.String("jarID", jarID))
+
+	if !applyFault(w, r, req.Fault) {
+		return
+	}
+
+	writeMockResponse(w, response)
+}
+
+func tlsInfo(state *tls.ConnectionState) *models.TLSInfo {
+	if state == nil {
+		return nil
+	}
+
+	info := &models.TLSInfo{
+		Version:            tls.VersionName(state.Version),
+		CipherSuite:        tls.CipherSuiteName(state.CipherSuite),
+		ServerName:         state.ServerName,
+		NegotiatedProtocol: state.NegotiatedProtocol,
+	}
+
+	if len(state.PeerCertificates) > 0 {
+		info.ClientCertSubject = state.PeerCertificates[0].Subject.String()
+		info.ClientCertIssuer = state.PeerCertificates[0].Issuer.String()
+	}
+
+	return info
 }
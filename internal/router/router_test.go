@@ -0,0 +1,219 @@
+package router
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bpietroniro/requestjar-go/internal/models"
+	"github.com/bpietroniro/requestjar-go/internal/service"
+	"github.com/bpietroniro/requestjar-go/internal/store"
+)
+
+func newTestRouter(t *testing.T) (*Router, *service.JarService) {
+	t.Helper()
+
+	svc := service.NewJarService(store.NewInMemoryJarStore(), store.NewInMemoryRequestStore())
+	t.Cleanup(svc.Stop)
+
+	return CreateRouter(svc), svc
+}
+
+func TestExtendJar(t *testing.T) {
+	router, svc := newTestRouter(t)
+	jarID, _ := svc.CreateJar(&models.Jar{Name: "jar"})
+
+	tests := []struct {
+		body      string
+		want      int
+		permanent bool
+	}{
+		{`{}`, http.StatusBadRequest, false},
+		{`{"ttl": "1h", "permanent": true}`, http.StatusBadRequest, false},
+		{`{"ttl": "1h"}`, http.StatusOK, false},
+		{`{"permanent": true}`, http.StatusOK, true},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodPost, "/jars/"+jarID+"/extend", strings.NewReader(tt.body))
+		req.SetPathValue("jarID", jarID)
+		rec := httptest.NewRecorder()
+
+		router.ExtendJar(rec, req)
+
+		if rec.Code != tt.want {
+			t.Fatalf("%s: expected %d, got %d: %s", tt.body, tt.want, rec.Code, rec.Body)
+		}
+
+		jar, _ := svc.GetJarMetadata(jarID)
+		if tt.want == http.StatusOK && (jar.ExpiresAt == nil) != tt.permanent {
+			t.Fatalf("%s: expected permanent to be %v, got expiry %v", tt.body, tt.permanent, jar.ExpiresAt)
+		}
+	}
+}
+
+// captureServer serves the router's capture endpoint, as main does.
+func captureServer(router *Router) *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/r/{jarID}/{path...}", router.CaptureRequest)
+	return mux
+}
+
+// capturedRequest returns the only request captured in a jar.
+func capturedRequest(t *testing.T, svc *service.JarService, jarID string) *models.Request {
+	t.Helper()
+
+	requests, err := svc.ListRequests(jarID, "", 10)
+	if err != nil || len(requests) != 1 {
+		t.Fatalf("expected one captured request, got %d, %v", len(requests), err)
+	}
+
+	return requests[0]
+}
+
+func TestCaptureRecordsConnectionDetails(t *testing.T) {
+	router, svc := newTestRouter(t)
+	jarID, _ := svc.CreateJar(&models.Jar{Name: "jar"})
+
+	server := httptest.NewServer(captureServer(router))
+	defer server.Close()
+
+	// A body of unknown length is sent chunked, which is what allows trailers
+	req, _ := http.NewRequest(http.MethodPost, server.URL+"/r/"+jarID+"/hooks?a=1&a=2", nil)
+	req.Trailer = http.Header{"X-Checksum": nil}
+	req.Body = &trailerSetter{ReadCloser: io.NopCloser(strings.NewReader("hello")), trailer: req.Trailer}
+
+	resp, err := server.Client().Do(req)
+	if err != nil {
+		t.Fatalf("capturing: %v", err)
+	}
+	_ = resp.Body.Close()
+
+	got := capturedRequest(t, svc, jarID)
+
+	if got.Proto != "HTTP/1.1" || got.Host != strings.TrimPrefix(server.URL, "http://") {
+		t.Fatalf("expected HTTP/1.1 to %s, got %s to %s", server.URL, got.Proto, got.Host)
+	}
+	if got.RequestURI != "/r/"+jarID+"/hooks?a=1&a=2" || got.RawQuery != "a=1&a=2" {
+		t.Fatalf("expected the raw request URI and query, got %q and %q", got.RequestURI, got.RawQuery)
+	}
+	if got.ContentLength != -1 || len(got.TransferEncoding) != 1 || got.TransferEncoding[0] != "chunked" {
+		t.Fatalf("expected a chunked body of unknown length, got %d and %v", got.ContentLength, got.TransferEncoding)
+	}
+	if got.Trailers.Get("X-Checksum") != "abc123" {
+		t.Fatalf("expected the trailer to be recorded, got %v", got.Trailers)
+	}
+	if string(got.Body) != "hello" || got.TLS != nil {
+		t.Fatalf("expected the body and no TLS, got %q and %+v", got.Body, got.TLS)
+	}
+}
+
+// trailerSetter fills in the request's trailer once its body has been read,
+// as a client computing a checksum would.
+type trailerSetter struct {
+	io.ReadCloser
+	trailer http.Header
+}
+
+func (r *trailerSetter) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	if err == io.EOF {
+		r.trailer.Set("X-Checksum", "abc123")
+	}
+	return n, err
+}
+
+func TestCaptureRecordsTLS(t *testing.T) {
+	router, svc := newTestRouter(t)
+	jarID, _ := svc.CreateJar(&models.Jar{Name: "jar"})
+
+	server := httptest.NewUnstartedServer(captureServer(router))
+	server.TLS = &tls.Config{ClientAuth: tls.RequestClientCert}
+	server.StartTLS()
+	defer server.Close()
+
+	client := server.Client()
+	transport := client.Transport.(*http.Transport)
+	// The test server's certificate is valid for example.com, which is
+	// sent as the SNI name
+	transport.TLSClientConfig.ServerName = "example.com"
+	transport.TLSClientConfig.Certificates = []tls.Certificate{clientCertificate(t, "test-client")}
+
+	resp, err := client.Get(server.URL + "/r/" + jarID + "/secure")
+	if err != nil {
+		t.Fatalf("capturing: %v", err)
+	}
+	_ = resp.Body.Close()
+
+	got := capturedRequest(t, svc, jarID)
+
+	if got.TLS == nil {
+		t.Fatal("expected the TLS connection to be recorded")
+	}
+	if got.TLS.Version != "TLS 1.3" || got.TLS.CipherSuite == "" || got.TLS.ServerName != "example.com" {
+		t.Fatalf("expected TLS 1.3 to example.com, got %+v", got.TLS)
+	}
+	if got.TLS.ClientCertSubject != "CN=test-client" || got.TLS.ClientCertIssuer != "CN=test-client" {
+		t.Fatalf("expected the self-signed client certificate, got %+v", got.TLS)
+	}
+	if !strings.HasPrefix(got.URL(jarID, ""), "https://") {
+		t.Fatalf("expected an https URL, got %s", got.URL(jarID, ""))
+	}
+}
+
+// clientCertificate returns a self-signed client certificate.
+func clientCertificate(t *testing.T, name string) tls.Certificate {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generating key: %v", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("creating certificate: %v", err)
+	}
+
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+func TestWriteMockResponseOmitsBodyWhenNotAllowed(t *testing.T) {
+	tests := []struct {
+		status     int
+		wantLength string
+		wantBody   string
+	}{
+		{200, "4", "body"},
+		{204, "", ""},
+		{304, "", ""},
+	}
+
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		writeMockResponse(rec, &models.MockResponse{StatusCode: tt.status, Body: "body"})
+
+		if rec.Code != tt.status || rec.Header().Get("Content-Length") != tt.wantLength || rec.Body.String() != tt.wantBody {
+			t.Errorf("status %d: got %d with Content-Length %q and body %q", tt.status, rec.Code, rec.Header().Get("Content-Length"), rec.Body.String())
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package router
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+
+	"github.com/bpietroniro/requestjar-go/internal/errors"
+	"github.com/bpietroniro/requestjar-go/internal/models"
+	"github.com/bpietroniro/requestjar-go/internal/util"
+)
+
+func (router *Router) GetRules(w http.ResponseWriter, r *http.Request) {
+	jarID := r.PathValue("jarID")
+
+	jar, err := router.svc.GetJarMetadata(jarID)
+	if err != nil {
+		slog.Error("failed to retrieve jar", slog.String("jarID", jarID), slog.Any("error", err))
+		errors.WriteHTTPError(w, err, "failed to retrieve jar")
+		return
+	}
+
+	rules := jar.Rules
+	if rules == nil {
+		rules = []models.ResponseRule{}
+	}
+
+	util.WriteJSON(w, http.StatusOK, rules)
+}
+
+func (router *Router) SetRules(w http.ResponseWriter, r *http.Request) {
+	jarID := r.PathValue("jarID")
+
+	var reqBody []models.ResponseRule
+
+	err := json.NewDecoder(r.Body).Decode(&reqBody)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "failed to parse request body")
+		http.Error(w, "error parsing request", http.StatusBadRequest)
+		return
+	}
+
+	jar, err := router.svc.SetRules(jarID, reqBody)
+	if err != nil {
+		slog.Error("failed to set response rules", slog.String("jarID", jarID), slog.Any("error", err))
+		errors.WriteHTTPError(w, err, "failed to set response rules")
+		return
+	}
+
+	slog.Info("response rules updated", slog.String("jarID", jarID), slog.Int("numRules", len(jar.Rules)))
+	util.WriteJSON(w, http.StatusOK, jar.Rules)
+}
+
+func (router *Router) DeleteRules(w http.ResponseWriter, r *http.Request) {
+	jarID := r.PathValue("jarID")
+
+	_, err := router.svc.SetRules(jarID, nil)
+	if err != nil {
+		slog.Error("failed to delete response rules", slog.String("jarID", jarID), slog.Any("error", err))
+		errors.WriteHTTPError(w, err, "failed to delete response rules")
+		return
+	}
+
+	slog.Info("response rules deleted", slog.String("jarID", jarID))
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,51 @@
+package router
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/bpietroniro/requestjar-go/internal/errors"
+	"github.com/bpietroniro/requestjar-go/internal/snippet"
+	"github.com/bpietroniro/requestjar-go/internal/util"
+)
+
+// GetRequestSnippet renders a captured request as a command or program that
+// sends it again. ?format= picks curl, httpie or go and returns plain text;
+// without it, all of them are returned as JSON. ?target= sends the request to
+// another base URL instead of the jar.
+func (router *Router) GetRequestSnippet(w http.ResponseWriter, r *http.Request) {
+	jarID := r.PathValue("jarID")
+	reqID := r.PathValue("reqID")
+	format := r.URL.Query().Get("format")
+
+	req, err := router.svc.SnippetRequest(jarID, reqID, r.Host, r.URL.Query().Get("target"))
+	if err != nil {
+		slog.Error("failed to prepare snippet", slog.String("jarID", jarID), slog.String("reqID", reqID), slog.Any("error", err))
+		errors.WriteHTTPError(w, err, "failed to prepare snippet")
+		return
+	}
+
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	req.BodyURL = scheme + "://" + r.Host + "/jars/" + jarID + "/requests/" + reqID + "/body"
+
+	if format == "" {
+		snippets := make(map[string]string, len(snippet.Formats))
+		for _, f := range snippet.Formats {
+			snippets[f], _ = snippet.Render(f, req)
+		}
+		util.WriteJSON(w, http.StatusOK, snippets)
+		return
+	}
+
+	text, err := snippet.Render(format, req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	_, _ = w.Write([]byte(text))
+}
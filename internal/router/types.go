@@ -1,9 +1,25 @@
 package router
 
-import "github.com/bpietroniro/requestjar-go/internal/models"
+import (
+	"time"
+
+	"github.com/bpietroniro/requestjar-go/internal/models"
+)
 
 type CreateJarRequest struct {
-	Name string `json:"name"`
+	Name      string                  `json:"name"`
+	Retention *models.RetentionPolicy `json:"retention,omitempty"`
+	TTL       *models.Duration        `json:"ttl,omitempty"`
+	// MaxBodySize caps captured bodies at this many bytes (413 beyond it)
+	MaxBodySize int64 `json:"maxBodySize,omitempty"`
+}
+
+// ExtendJarRequest sets a jar's new expiry, either relative to now (TTL) or
+// as an absolute time, or makes the jar permanent. Exactly one must be given.
+type ExtendJarRequest struct {
+	TTL       *models.Duration `json:"ttl,omitempty"`
+	ExpiresAt *time.Time       `json:"expiresAt,omitempty"`
+	Permanent bool             `json:"permanent,omitempty"`
 }
 
 type DeleteJarRequest struct {
@@ -18,3 +34,52 @@ type GetJarWithRequestsResponse struct {
 	Jar      models.Jar        `json:"jar"`
 	Requests []*models.Request `json:"requests"` // TODO pointers or not?
 }
+
+type ListRequestsResponse struct {
+	Requests []*models.Request `json:"requests"`
+	// NextCursor is passed as ?after= to fetch the next page; it's omitted on
+	// the last page
+	NextCursor string `json:"nextCursor,omitempty"`
+}
+
+// ReplayRequestRequest says where to resend a captured request. Headers
+// replace the captured values of the headers they name, and an empty value
+// removes the header. Body, when present, replaces the captured body.
+type ReplayRequestRequest struct {
+	Target  string            `json:"target"`
+	Method  string            `json:"method,omitempty"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    *string           `json:"body,omitempty"`
+}
+
+type ImportJarResponse struct {
+	ID string `json:"id"`
+	// Requests is how many requests were imported
+	Requests int `json:"requests"`
+}
+
+// WebSocketCommand is sent by WebSocket clients to control their subscription.
+type WebSocketCommand struct {
+	// Command is "filter", "pause", "resume" or "ack"
+	Command string `json:"command"`
+	// Filter replaces the connection's filter; leaving it out removes it
+	Filter *models.RequestFilter `json:"filter,omitempty"`
+	// ID is the captured request being acknowledged
+	ID string `json:"id,omitempty"`
+}
+
+// WebSocketMessage is what WebSocket clients are sent besides events: the
+// greeting, replies to their commands and the hint to reconnect.
+type WebSocketMessage struct {
+	// Type is "connected", "ok", "error" or "reconnect"
+	Type    string                `json:"type"`
+	JarID   string                `json:"jarID,omitempty"`
+	Command string                `json:"command,omitempty"`
+	Filter  *models.RequestFilter `json:"filter,omitempty"`
+	Paused  bool                  `json:"paused,omitempty"`
+	Error   string                `json:"error,omitempty"`
+	Reason  string                `json:"reason,omitempty"`
+	// LastEventID is where to resume from, with ?lastEventID=, after
+	// reconnecting
+	LastEventID string `json:"lastEventID,omitempty"`
+}
@@ -0,0 +1,88 @@
+package router
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/bpietroniro/requestjar-go/internal/errors"
+	"github.com/bpietroniro/requestjar-go/internal/matcher"
+	"github.com/bpietroniro/requestjar-go/internal/models"
+	"github.com/bpietroniro/requestjar-go/internal/util"
+)
+
+const (
+	defaultWaitTimeout = 30 * time.Second
+	maxWaitTimeout     = 5 * time.Minute
+)
+
+// WaitForRequest responds with the first captured request that matches the
+// query, holding the response open until one arrives or the timeout passes.
+//
+//	GET /jars/{jarID}/requests/wait?match={"methods":["POST"]}&timeout=30s
+//
+// match is a JSON filter, as taken by the WebSocket's filter command; the
+// event stream's filter parameters can be used instead. after skips the
+// requests up to and including that ID.
+func (router *Router) WaitForRequest(w http.ResponseWriter, r *http.Request) {
+	jarID := r.PathValue("jarID")
+	query := r.URL.Query()
+
+	timeout := defaultWaitTimeout
+	if t := query.Get("timeout"); t != "" {
+		parsed, err := time.ParseDuration(t)
+		if err != nil || parsed <= 0 || parsed > maxWaitTimeout {
+			http.Error(w, fmt.Sprintf("timeout must be a duration of at most %s", maxWaitTimeout), http.StatusBadRequest)
+			return
+		}
+		timeout = parsed
+	}
+
+	filter, err := waitFilter(r)
+	if err != nil {
+		errors.WriteHTTPError(w, err, "invalid filter")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), timeout)
+	defer cancel()
+
+	req, err := router.svc.WaitForRequest(ctx, jarID, filter, query.Get("after"))
+	if err != nil {
+		if r.Context().Err() != nil {
+			slog.DebugContext(r.Context(), "client stopped waiting", slog.String("jarID", jarID))
+			return
+		}
+
+		slog.Info("failed waiting for request", slog.String("jarID", jarID), slog.Any("error", err))
+		errors.WriteHTTPError(w, err, "failed waiting for request")
+		return
+	}
+
+	util.WriteJSON(w, http.StatusOK, req)
+}
+
+// waitFilter reads the filter from the match parameter, or failing that from
+// the event stream's filter parameters.
+func waitFilter(r *http.Request) (*matcher.Filter, error) {
+	match := r.URL.Query().Get("match")
+	if match == "" {
+		return filterFromQuery(r.URL.Query())
+	}
+
+	var spec models.RequestFilter
+	err := json.Unmarshal([]byte(match), &spec)
+	if err != nil {
+		return nil, errors.BadRequest("match must be a JSON filter")
+	}
+
+	filter, err := matcher.NewFilter(&spec)
+	if err != nil {
+		return nil, errors.BadRequest(err.Error())
+	}
+
+	return filter, nil
+}
@@ -0,0 +1,323 @@
+package router
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/coder/websocket"
+	"github.com/coder/websocket/wsjson"
+
+	"github.com/bpietroniro/requestjar-go/internal/errors"
+	"github.com/bpietroniro/requestjar-go/internal/matcher"
+	"github.com/bpietroniro/requestjar-go/internal/service"
+	"github.com/bpietroniro/requestjar-go/internal/util"
+)
+
+// wsWriteTimeout is how long a WebSocket client has to take a message, or to
+// answer a ping
+const wsWriteTimeout = 10 * time.Second
+
+// WebSocket commands
+const (
+	wsFilter = "filter"
+	wsPause  = "pause"
+	wsResume = "resume"
+	wsAck    = "ack"
+)
+
+// SetWebSocketOrigins sets the hosts, as path.Match patterns such as
+// "localhost:5173", that browsers may open WebSockets from besides the
+// server's own.
+func (router *Router) SetWebSocketOrigins(patterns []string) {
+	router.wsOrigins = patterns
+}
+
+// wsSession is the state of one WebSocket client's subscription. It is only
+// touched by the goroutine serving the client.
+type wsSession struct {
+	router *Router
+	ws     *websocket.Conn
+	conn   *service.Connection
+	jarID  string
+
+	paused bool
+	// delivered is the ID of the last captured request sent, or where the
+	// session started
+	delivered string
+	// acked is the ID of the last captured request the client acknowledged
+	acked string
+	// replayed holds the requests sent from the store whose live events
+	// may still arrive
+	replayed replayedSet
+}
+
+// HandleWebSocket delivers a jar's events over a WebSocket, as
+// HandleSSEConnection does over SSE, and lets the client change its filter,
+// pause and resume delivery, and acknowledge the requests it has processed.
+func (router *Router) HandleWebSocket(w http.ResponseWriter, r *http.Request) {
+	jarID := r.PathValue("jarID")
+
+	slog.InfoContext(r.Context(), "adding new WebSocket connection", slog.String("jarID", jarID))
+
+	// Check that jar exists
+	_, err := router.svc.GetJarMetadata(jarID)
+	if err != nil {
+		slog.Error("jar not found", slog.String("jarID", jarID), slog.Any("error", err))
+		errors.WriteHTTPError(w, err, "jar not found")
+		return
+	}
+
+	filter, err := filterFromQuery(r.URL.Query())
+	if err != nil {
+		errors.WriteHTTPError(w, err, "invalid filter")
+		return
+	}
+
+	// Browsers can't set headers on WebSockets, so the resume point can be
+	// passed in the query instead
+	lastID := r.URL.Query().Get("lastEventID")
+	if lastID == "" {
+		lastID = r.Header.Get("Last-Event-ID")
+	}
+
+	// Without one, the session starts now: an ID generated before the
+	// connection is registered sorts before every request it will be sent
+	start := lastID
+	if start == "" {
+		start = util.GenerateID()
+	}
+
+	conn, err := router.svc.AddConnection(jarID, filter)
+	if err != nil {
+		slog.Error("failed to add connection", slog.String("jarID", jarID), slog.Any("error", err))
+		if errors.Is(err, errors.ErrTooManyRequests) {
+			w.Header().Set("Retry-After", strconv.Itoa(int(sseRetry.Seconds())))
+		}
+		errors.WriteHTTPError(w, err, "failed to add connection")
+		return
+	}
+
+	defer func() {
+		slog.InfoContext(r.Context(), "removing WebSocket connection", slog.String("jarID", jarID), slog.Int64("dropped", conn.Dropped()))
+		router.svc.RemoveConnection(conn)
+	}()
+
+	ws, err := websocket.Accept(w, r, &websocket.AcceptOptions{OriginPatterns: router.wsOrigins})
+	if err != nil {
+		// Accept has already written the error response
+		slog.Error("error accepting WebSocket", slog.String("jarID", jarID), slog.Any("error", err))
+		return
+	}
+	defer ws.CloseNow()
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	session := &wsSession{router: router, ws: ws, conn: conn, jarID: jarID, delivered: start, replayed: replayedSet{}}
+
+	err = session.send(ctx, WebSocketMessage{Type: "connected", JarID: jarID, Filter: filter.Spec()})
+	if err != nil {
+		slog.Error("error writing connection response", slog.String("jarID", jarID), slog.Any("error", err))
+		return
+	}
+
+	if lastID != "" {
+		err = session.replay(ctx, lastID)
+		if err != nil {
+			slog.Error("error resuming stream", slog.String("jarID", jarID), slog.Any("error", err))
+			return
+		}
+	}
+
+	// Commands are read in the background so that they are seen while
+	// events are being sent. Reading also answers the client's pings and
+	// notices when it goes away.
+	messages := make(chan []byte)
+	readErr := make(chan error, 1)
+	go func() {
+		for {
+			_, data, err := ws.Read(ctx)
+			if err != nil {
+				readErr <- err
+				return
+			}
+
+			select {
+			case messages <- data:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	heartbeat, expired, stopTimers := router.streamTimers()
+	defer stopTimers()
+
+	for {
+		// Paused sessions leave events in the connection's buffer
+		events := conn.Events
+		if session.paused {
+			events = nil
+		}
+
+		select {
+		case event, ok := <-events:
+			// The service ended the connection
+			if !ok {
+				slog.Warn("connection closed, ending stream", slog.String("jarID", jarID), slog.String("reason", conn.CloseReason()))
+
+				// Slow clients can catch up by reconnecting
+				if conn.CloseReason() == service.CloseSlowConsumer {
+					session.reconnect(ctx, conn.CloseReason())
+					return
+				}
+
+				_ = ws.Close(websocket.StatusNormalClosure, conn.CloseReason())
+				return
+			}
+
+			err = session.sendEvent(ctx, event)
+			if err != nil {
+				slog.Error("error forwarding event", slog.String("jarID", jarID), slog.String("event", event.Type), slog.Any("error", err))
+				return
+			}
+		case data := <-messages:
+			err = session.handleCommand(ctx, data)
+			if err != nil {
+				slog.Error("error answering command", slog.String("jarID", jarID), slog.Any("error", err))
+				return
+			}
+		case <-heartbeat:
+			pingCtx, cancelPing := context.WithTimeout(ctx, wsWriteTimeout)
+			err = ws.Ping(pingCtx)
+			cancelPing()
+			if err != nil {
+				slog.Debug("error pinging client", slog.String("jarID", jarID), slog.Any("error", err))
+				return
+			}
+		case <-expired:
+			slog.Debug("stream reached its maximum lifetime", slog.String("jarID", jarID))
+			session.reconnect(ctx, "maximum connection lifetime reached")
+			return
+		case err = <-readErr:
+			slog.InfoContext(r.Context(), "Client disconnected", slog.String("jarID", jarID), slog.Any("reason", err))
+			return
+		}
+	}
+}
+
+// send writes a message to the client as JSON.
+func (s *wsSession) send(ctx context.Context, v any) error {
+	ctx, cancel := context.WithTimeout(ctx, wsWriteTimeout)
+	defer cancel()
+
+	return wsjson.Write(ctx, s.ws, v)
+}
+
+// sendEvent forwards a live event, unless it is for a request that has
+// already been replayed.
+func (s *wsSession) sendEvent(ctx context.Context, event *service.Event) error {
+	if s.replayed.skip(event) {
+		return nil
+	}
+	return s.deliver(ctx, event)
+}
+
+// deliver sends an event and keeps track of the captured requests sent.
+func (s *wsSession) deliver(ctx context.Context, event *service.Event) error {
+	err := s.send(ctx, event)
+	if err != nil {
+		return err
+	}
+
+	if event.Type == service.EventRequestCreated && event.Request.ID > s.delivered {
+		s.delivered = event.Request.ID
+	}
+	return nil
+}
+
+// replay sends the requests captured after lastID that match the current
+// filter, even those that were sent before, in order with the events waiting
+// in the connection's buffer.
+func (s *wsSession) replay(ctx context.Context, lastID string) error {
+	return s.router.replayRequests(s.conn, s.jarID, lastID, s.replayed, func(event *service.Event) error {
+		return s.deliver(ctx, event)
+	})
+}
+
+// cursor is where the client should pick up from: after the last request it
+// acknowledged, or if it never has, the last one it was sent.
+func (s *wsSession) cursor() string {
+	if s.acked != "" {
+		return s.acked
+	}
+	return s.delivered
+}
+
+// handleCommand carries out a command from the client. Problems with the
+// command are reported to the client; only failing to do so is an error.
+func (s *wsSession) handleCommand(ctx context.Context, data []byte) error {
+	var cmd WebSocketCommand
+	err := json.Unmarshal(data, &cmd)
+	if err != nil {
+		return s.send(ctx, WebSocketMessage{Type: "error", Error: "commands must be JSON objects"})
+	}
+
+	slog.Debug("received WebSocket command", slog.String("jarID", s.jarID), slog.String("command", cmd.Command))
+
+	switch cmd.Command {
+	case wsFilter:
+		filter, err := matcher.NewFilter(cmd.Filter)
+		if err != nil {
+			return s.send(ctx, WebSocketMessage{Type: "error", Command: cmd.Command, Error: err.Error()})
+		}
+
+		s.conn.SetFilter(filter)
+		return s.send(ctx, WebSocketMessage{Type: "ok", Command: cmd.Command, Filter: filter.Spec()})
+	case wsPause:
+		s.paused = true
+		return s.send(ctx, WebSocketMessage{Type: "ok", Command: cmd.Command, Paused: true})
+	case wsResume:
+		if !s.paused {
+			return s.send(ctx, WebSocketMessage{Type: "ok", Command: cmd.Command})
+		}
+
+		// Whatever didn't fit in the buffer while paused, and any requests
+		// that weren't acknowledged, are sent again from the store, in order
+		// with the events that did fit
+		s.paused = false
+		err = s.send(ctx, WebSocketMessage{Type: "ok", Command: cmd.Command})
+		if err != nil {
+			return err
+		}
+		return s.replay(ctx, s.cursor())
+	case wsAck:
+		if cmd.ID == "" || cmd.ID > s.delivered {
+			return s.send(ctx, WebSocketMessage{Type: "error", Command: cmd.Command, Error: fmt.Sprintf("request %q hasn't been sent", cmd.ID)})
+		}
+
+		// Acknowledgements are cumulative and aren't answered
+		if cmd.ID > s.acked {
+			s.acked = cmd.ID
+		}
+		return nil
+	default:
+		return s.send(ctx, WebSocketMessage{Type: "error", Command: cmd.Command, Error: fmt.Sprintf("unknown command %q", cmd.Command)})
+	}
+}
+
+// reconnect tells the client that the server is ending the connection and
+// where to resume from once it has reconnected, then closes it.
+func (s *wsSession) reconnect(ctx context.Context, reason string) {
+	err := s.send(ctx, WebSocketMessage{Type: "reconnect", Reason: reason, LastEventID: s.cursor()})
+	if err != nil {
+		slog.Debug("error sending reconnect", slog.String("jarID", s.jarID), slog.Any("error", err))
+	}
+
+	_ = s.ws.Close(websocket.StatusTryAgainLater, reason)
+}
@@ -0,0 +1,248 @@
+package router
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coder/websocket"
+	"github.com/coder/websocket/wsjson"
+
+	"github.com/bpietroniro/requestjar-go/internal/models"
+	"github.com/bpietroniro/requestjar-go/internal/service"
+)
+
+// wsFrame holds the fields of both the events and the messages of the server's
+// own that WebSocket clients are sent.
+type wsFrame struct {
+	Type        string          `json:"type"`
+	Request     *models.Request `json:"request"`
+	Command     string          `json:"command"`
+	Error       string          `json:"error"`
+	Reason      string          `json:"reason"`
+	LastEventID string          `json:"lastEventID"`
+}
+
+// wsServer serves a router's WebSocket endpoint.
+func wsServer(t *testing.T, router *Router) *httptest.Server {
+	t.Helper()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /jars/{jarID}/ws", router.HandleWebSocket)
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+// dialWS opens a WebSocket to a jar, with query appended to its URL, and
+// returns it once the connection has been registered.
+func dialWS(t *testing.T, server *httptest.Server, jarID string, query string) *websocket.Conn {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/jars/" + jarID + "/ws" + query
+	ws, _, err := websocket.Dial(ctx, url, nil)
+	if err != nil {
+		t.Fatalf("dialing: %v", err)
+	}
+	// Before the server is closed, which waits for the handler
+	t.Cleanup(func() { _ = ws.CloseNow() })
+
+	// Sent once the connection is registered
+	if frame := readWS(t, ws); frame.Type != "connected" {
+		t.Fatalf("expected the connected message, got %+v", frame)
+	}
+
+	return ws
+}
+
+// readWS reads the next message from a WebSocket.
+func readWS(t *testing.T, ws *websocket.Conn) wsFrame {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var frame wsFrame
+	err := wsjson.Read(ctx, ws, &frame)
+	if err != nil {
+		t.Fatalf("reading: %v", err)
+	}
+
+	return frame
+}
+
+// sendWS sends a command over a WebSocket.
+func sendWS(t *testing.T, ws *websocket.Conn, cmd WebSocketCommand) {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	err := wsjson.Write(ctx, ws, cmd)
+	if err != nil {
+		t.Fatalf("writing: %v", err)
+	}
+}
+
+// requireWSRequest fails the test unless frame is the capture of reqID.
+func requireWSRequest(t *testing.T, frame wsFrame, reqID string) {
+	t.Helper()
+
+	if frame.Type != service.EventRequestCreated || frame.Request == nil || frame.Request.ID != reqID {
+		t.Fatalf("expected request %s, got %+v", reqID, frame)
+	}
+}
+
+// requireWSReply fails the test unless frame is a reply of the given type to
+// command.
+func requireWSReply(t *testing.T, frame wsFrame, replyType string, command string) {
+	t.Helper()
+
+	if frame.Type != replyType || frame.Command != command {
+		t.Fatalf("expected %s in reply to %s, got %+v", replyType, command, frame)
+	}
+}
+
+// capture stores a request in a jar and returns it.
+func capture(t *testing.T, svc *service.JarService, jarID string, req *models.Request) *models.Request {
+	t.Helper()
+
+	_, err := svc.NewRequest(context.Background(), jarID, req)
+	if err != nil {
+		t.Fatalf("NewRequest: %v", err)
+	}
+
+	return req
+}
+
+func TestWebSocketFilterCommand(t *testing.T) {
+	router, svc := newTestRouter(t)
+	jarID, _ := svc.CreateJar(&models.Jar{Name: "jar"})
+	ws := dialWS(t, wsServer(t, router), jarID, "")
+
+	sendWS(t, ws, WebSocketCommand{Command: wsFilter, Filter: &models.RequestFilter{Methods: []string{"POST"}}})
+	requireWSReply(t, readWS(t, ws), "ok", wsFilter)
+
+	capture(t, svc, jarID, &models.Request{Method: "GET"})
+	post := capture(t, svc, jarID, &models.Request{Method: "POST"})
+	requireWSRequest(t, readWS(t, ws), post.ID)
+
+	sendWS(t, ws, WebSocketCommand{Command: wsFilter, Filter: &models.RequestFilter{JSONPath: "$["}})
+	requireWSReply(t, readWS(t, ws), "error", wsFilter)
+}
+
+func TestWebSocketResumesFromTheAcknowledgedRequest(t *testing.T) {
+	router, svc := newTestRouter(t)
+	jarID, _ := svc.CreateJar(&models.Jar{Name: "jar"})
+	ws := dialWS(t, wsServer(t, router), jarID, "")
+
+	first := capture(t, svc, jarID, &models.Request{Method: "GET"})
+	second := capture(t, svc, jarID, &models.Request{Method: "GET"})
+	requireWSRequest(t, readWS(t, ws), first.ID)
+	requireWSRequest(t, readWS(t, ws), second.ID)
+
+	// Only the first is processed before the client pauses
+	sendWS(t, ws, WebSocketCommand{Command: wsAck, ID: first.ID})
+	sendWS(t, ws, WebSocketCommand{Command: wsPause})
+	requireWSReply(t, readWS(t, ws), "ok", wsPause)
+
+	whilePaused := capture(t, svc, jarID, &models.Request{Method: "GET"})
+
+	// Everything after the acknowledged request is sent again, once
+	sendWS(t, ws, WebSocketCommand{Command: wsResume})
+	requireWSReply(t, readWS(t, ws), "ok", wsResume)
+	requireWSRequest(t, readWS(t, ws), second.ID)
+	requireWSRequest(t, readWS(t, ws), whilePaused.ID)
+
+	live := capture(t, svc, jarID, &models.Request{Method: "GET"})
+	requireWSRequest(t, readWS(t, ws), live.ID)
+}
+
+func TestWebSocketResumeKeepsEventsInOrder(t *testing.T) {
+	router, svc := newTestRouter(t)
+	jarID, _ := svc.CreateJar(&models.Jar{Name: "jar"})
+	ws := dialWS(t, wsServer(t, router), jarID, "")
+
+	sendWS(t, ws, WebSocketCommand{Command: wsPause})
+	requireWSReply(t, readWS(t, ws), "ok", wsPause)
+
+	first := capture(t, svc, jarID, &models.Request{Method: "GET"})
+	_, _ = svc.SetMockResponse(jarID, &models.MockResponse{StatusCode: 204})
+	second := capture(t, svc, jarID, &models.Request{Method: "GET"})
+
+	sendWS(t, ws, WebSocketCommand{Command: wsResume})
+	requireWSReply(t, readWS(t, ws), "ok", wsResume)
+	requireWSRequest(t, readWS(t, ws), first.ID)
+	if frame := readWS(t, ws); frame.Type != service.EventJarUpdated {
+		t.Fatalf("expected the update between the requests, got %+v", frame)
+	}
+	requireWSRequest(t, readWS(t, ws), second.ID)
+}
+
+func TestWebSocketAckValidation(t *testing.T) {
+	router, svc := newTestRouter(t)
+	jarID, _ := svc.CreateJar(&models.Jar{Name: "jar"})
+	ws := dialWS(t, wsServer(t, router), jarID, "")
+
+	sent := capture(t, svc, jarID, &models.Request{Method: "GET"})
+	requireWSRequest(t, readWS(t, ws), sent.ID)
+
+	for _, id := range []string{"", sent.ID + "0"} {
+		sendWS(t, ws, WebSocketCommand{Command: wsAck, ID: id})
+		requireWSReply(t, readWS(t, ws), "error", wsAck)
+	}
+
+	// Valid acknowledgements aren't answered, so the next reply is to the
+	// command after it
+	sendWS(t, ws, WebSocketCommand{Command: wsAck, ID: sent.ID})
+	sendWS(t, ws, WebSocketCommand{Command: "bogus"})
+	requireWSReply(t, readWS(t, ws), "error", "bogus")
+}
+
+func TestWebSocketReconnectsAfterItsLifetime(t *testing.T) {
+	router, svc := newTestRouter(t)
+	router.SetStreamTiming(0, 200*time.Millisecond)
+	jarID, _ := svc.CreateJar(&models.Jar{Name: "jar"})
+	server := wsServer(t, router)
+	ws := dialWS(t, server, jarID, "")
+
+	seen := capture(t, svc, jarID, &models.Request{Method: "GET"})
+	requireWSRequest(t, readWS(t, ws), seen.ID)
+
+	frame := readWS(t, ws)
+	if frame.Type != "reconnect" || frame.LastEventID != seen.ID {
+		t.Fatalf("expected to be asked to reconnect after %s, got %+v", seen.ID, frame)
+	}
+
+	_, _, err := ws.Read(context.Background())
+	if websocket.CloseStatus(err) != websocket.StatusTryAgainLater {
+		t.Fatalf("expected the socket to be closed, got %v", err)
+	}
+
+	missed := capture(t, svc, jarID, &models.Request{Method: "GET"})
+
+	ws = dialWS(t, server, jarID, "?lastEventID="+frame.LastEventID)
+	requireWSRequest(t, readWS(t, ws), missed.ID)
+}
+
+func TestWebSocketDeliversRequestsStoredOutOfOrder(t *testing.T) {
+	router, svc := newTestRouter(t)
+	jarID, _ := svc.CreateJar(&models.Jar{Name: "jar"})
+
+	seen := capture(t, svc, jarID, &models.Request{Method: "GET"})
+	missed := capture(t, svc, jarID, &models.Request{Method: "GET"})
+
+	ws := dialWS(t, wsServer(t, router), jarID, "?lastEventID="+seen.ID)
+	requireWSRequest(t, readWS(t, ws), missed.ID)
+
+	// Sorts before the replayed request, but wasn't replayed
+	late := capture(t, svc, jarID, &models.Request{ID: seen.ID + "0", Method: "GET"})
+	requireWSRequest(t, readWS(t, ws), late.ID)
+}
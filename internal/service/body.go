@@ -0,0 +1,182 @@
+package service
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"log/slog"
+	"slices"
+
+	"github.com/bpietroniro/requestjar-go/internal/blob"
+	"github.com/bpietroniro/requestjar-go/internal/errors"
+	"github.com/bpietroniro/requestjar-go/internal/models"
+)
+
+// SetMaxBodySize caps the body of every captured request at n bytes. Jars may
+// set a lower limit of their own. Zero, the default, means no server-wide
+// limit.
+func (s *JarService) SetMaxBodySize(n int64) {
+	s.maxBodySize = n
+}
+
+// SetBlobStore makes the service keep request bodies larger than threshold
+// bytes in blobs instead of in the request store.
+func (s *JarService) SetBlobStore(blobs blob.Store, threshold int64) {
+	s.blobs = blobs
+	s.offloadThreshold = threshold
+}
+
+func validateMaxBodySize(n int64) error {
+	if n < 0 {
+		return errors.BadRequest("maxBodySize must not be negative")
+	}
+
+	return nil
+}
+
+// BodyLimit returns the largest body, in bytes, that the jar will capture, or
+// zero when there is no limit.
+func (s *JarService) BodyLimit(jarID string) (int64, error) {
+	jar, err := s.jarStore.Get(jarID)
+	if err != nil {
+		return 0, err
+	}
+
+	return lowerLimit(s.maxBodySize, jar.MaxBodySize), nil
+}
+
+// lowerLimit returns the stricter of two limits, where zero means no limit.
+func lowerLimit(a int64, b int64) int64 {
+	if a == 0 || (b > 0 && b < a) {
+		return b
+	}
+	return a
+}
+
+// OpenRequestBody returns a captured request together with a reader for its
+// raw body, wherever the body is kept. The caller must close the reader.
+func (s *JarService) OpenRequestBody(jarID string, reqID string) (*models.Request, io.ReadCloser, error) {
+	req, err := s.requestStore.Get(jarID, reqID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if req.BodyInfo == nil || !req.BodyInfo.Offloaded {
+		return req, io.NopCloser(bytes.NewReader(req.Body)), nil
+	}
+
+	if s.blobs == nil {
+		return nil, nil, errors.Internal("request body is in blob storage, which isn't configured")
+	}
+
+	body, err := s.blobs.Open(blobKey(jarID, reqID))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return req, body, nil
+}
+
+// readBody returns a request's whole body, wherever it is kept.
+func (s *JarService) readBody(jarID string, req *models.Request) ([]byte, error) {
+	if req.BodyInfo == nil || !req.BodyInfo.Offloaded {
+		return req.Body, nil
+	}
+
+	_, body, err := s.OpenRequestBody(jarID, req.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	return io.ReadAll(body)
+}
+
+// storeBody fills in request.BodyInfo and, when the body is over the offload
+// threshold, moves it out of the request and into blob storage.
+func (s *JarService) storeBody(jarID string, request *models.Request) error {
+	sum := sha256.Sum256(request.Body)
+	request.BodyInfo = &models.BodyInfo{
+		Size:        int64(len(request.Body)),
+		SHA256:      hex.EncodeToString(sum[:]),
+		ContentType: request.Headers.Get("Content-Type"),
+	}
+
+	if s.blobs == nil || s.offloadThreshold <= 0 || request.BodyInfo.Size <= s.offloadThreshold {
+		return nil
+	}
+
+	err := s.blobs.Put(blobKey(jarID, request.ID), bytes.NewReader(request.Body))
+	if err != nil {
+		return err
+	}
+
+	slog.Debug("offloaded request body", slog.String("jarID", jarID), slog.String("reqID", request.ID), slog.Int64("size", request.BodyInfo.Size))
+	request.Body = nil
+	request.BodyInfo.Offloaded = true
+	request.Decoded = summarizeDecoded(request.Decoded)
+	return nil
+}
+
+// defaultDecodeLimit caps decompressed bodies when there is no body size limit
+const defaultDecodeLimit = 64 << 20
+
+// decodeLimit is how large a body may get once its content encodings are
+// undone. Decoding stops there so that small compressed bodies can't expand
+// without bound.
+func (s *JarService) decodeLimit() int64 {
+	if s.maxBodySize > 0 {
+		return s.maxBodySize
+	}
+	return defaultDecodeLimit
+}
+
+// summarizeDecoded drops the parts of a decoded view that repeat the body's
+// contents, keeping only what describes it. Offloaded bodies are too large to
+// be copied into the request.
+func summarizeDecoded(decoded *models.DecodedBody) *models.DecodedBody {
+	if decoded == nil {
+		return nil
+	}
+
+	summary := *decoded
+	summary.JSON = nil
+	summary.Form = nil
+	summary.Text = ""
+
+	summary.Parts = slices.Clone(decoded.Parts)
+	for i := range summary.Parts {
+		summary.Parts[i].Value = ""
+	}
+
+	return &summary
+}
+
+// deleteBody removes a request's body from blob storage, if it is there.
+func (s *JarService) deleteBody(jarID string, reqID string) {
+	if s.blobs == nil {
+		return
+	}
+
+	err := s.blobs.Delete(blobKey(jarID, reqID))
+	if err != nil {
+		slog.Error("failed to delete request body", slog.String("jarID", jarID), slog.String("reqID", reqID), slog.Any("error", err))
+	}
+}
+
+// deleteJarBodies removes every body the jar has in blob storage.
+func (s *JarService) deleteJarBodies(jarID string) {
+	if s.blobs == nil {
+		return
+	}
+
+	err := s.blobs.DeleteAll(jarID)
+	if err != nil {
+		slog.Error("failed to delete request bodies", slog.String("jarID", jarID), slog.Any("error", err))
+	}
+}
+
+func blobKey(jarID string, reqID string) string {
+	return jarID + "/" + reqID
+}
@@ -0,0 +1,213 @@
+package service
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/bpietroniro/requestjar-go/internal/blob"
+	"github.com/bpietroniro/requestjar-go/internal/errors"
+	"github.com/bpietroniro/requestjar-go/internal/models"
+)
+
+func TestBodyLimit(t *testing.T) {
+	s := newTestService(t)
+	unlimited, _ := s.CreateJar(&models.Jar{Name: "unlimited"})
+	small, _ := s.CreateJar(&models.Jar{Name: "small", MaxBodySize: 10})
+	large, _ := s.CreateJar(&models.Jar{Name: "large", MaxBodySize: 1000})
+	retained, _ := s.CreateJar(&models.Jar{Name: "retained", Retention: &models.RetentionPolicy{MaxBodyBytes: 50}})
+
+	_, err := s.CreateJar(&models.Jar{Name: "negative", MaxBodySize: -1})
+	if err == nil {
+		t.Fatal("expected a negative maxBodySize to be rejected")
+	}
+
+	tests := []struct {
+		serverLimit int64
+		jarID       string
+		want        int64
+	}{
+		{0, unlimited, 0},
+		{0, small, 10},
+		{100, unlimited, 100},
+		{100, small, 10},
+		// Jars can't raise the server-wide limit
+		{100, large, 100},
+		// Retention budgets are trimmed down to, not enforced per request
+		{0, retained, 0},
+		{100, retained, 100},
+	}
+
+	for _, tt := range tests {
+		s.SetMaxBodySize(tt.serverLimit)
+
+		got, err := s.BodyLimit(tt.jarID)
+		if err != nil || got != tt.want {
+			t.Errorf("server limit %d: expected %d, got %d, %v", tt.serverLimit, tt.want, got, err)
+		}
+	}
+
+	_, err = s.BodyLimit("missing")
+	if !errors.Is(err, errors.ErrNotFound) {
+		t.Fatalf("expected a missing jar to be not found, got %v", err)
+	}
+}
+
+func TestLargeBodiesAreOffloaded(t *testing.T) {
+	blobs, err := blob.NewLocalStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewLocalStore: %v", err)
+	}
+
+	s := newTestService(t)
+	s.SetBlobStore(blobs, 8)
+	jarID, _ := s.CreateJar(&models.Jar{Name: "jar"})
+
+	_, err = s.SetMockResponse(jarID, &models.MockResponse{Body: "{{ .Body }}", Template: true})
+	if err != nil {
+		t.Fatalf("SetMockResponse: %v", err)
+	}
+
+	headers := http.Header{"Content-Type": {"text/plain"}}
+	small := &models.Request{Method: "POST", Headers: headers, Body: []byte("tiny")}
+	large := &models.Request{Method: "POST", Headers: headers, Body: []byte("larger than eight bytes")}
+
+	for _, req := range []*models.Request{small, large} {
+		response, err := s.NewRequest(context.Background(), jarID, req)
+		if err != nil {
+			t.Fatalf("NewRequest: %v", err)
+		}
+		if response.Body == "" {
+			t.Fatal("expected templates to see the whole body")
+		}
+	}
+
+	if small.BodyInfo.Offloaded || string(small.Body) != "tiny" {
+		t.Fatalf("expected the small body to stay inline, got %+v", small.BodyInfo)
+	}
+	if !large.BodyInfo.Offloaded || large.Body != nil || large.BodyInfo.Size != 23 || large.BodyInfo.ContentType != "text/plain" {
+		t.Fatalf("expected the large body to be offloaded, got %+v with body %q", large.BodyInfo, large.Body)
+	}
+	if small.BodyInfo.SHA256 != "8950abfda7b727630760dd35bcf5c3daa7631aff223a90f7728c0d2521dde10c" {
+		t.Fatalf("unexpected digest %s", small.BodyInfo.SHA256)
+	}
+
+	for _, req := range []*models.Request{small, large} {
+		_, body, err := s.OpenRequestBody(jarID, req.ID)
+		if err != nil {
+			t.Fatalf("OpenRequestBody: %v", err)
+		}
+		got, _ := io.ReadAll(body)
+		_ = body.Close()
+
+		if int64(len(got)) != req.BodyInfo.Size {
+			t.Fatalf("expected %d bytes of body, got %q", req.BodyInfo.Size, got)
+		}
+	}
+
+	err = s.DeleteRequest(jarID, large.ID)
+	if err != nil {
+		t.Fatalf("DeleteRequest: %v", err)
+	}
+
+	_, err = blobs.Open(jarID + "/" + large.ID)
+	if !errors.Is(err, errors.ErrNotFound) {
+		t.Fatalf("expected the deleted request's body to be removed, got %v", err)
+	}
+}
+
+func TestDeleteJarRemovesOffloadedBodies(t *testing.T) {
+	blobs, err := blob.NewLocalStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewLocalStore: %v", err)
+	}
+
+	s := newTestService(t)
+	s.SetBlobStore(blobs, 1)
+	jarID, _ := s.CreateJar(&models.Jar{Name: "jar"})
+
+	req := &models.Request{Method: "POST", Body: []byte(strings.Repeat("x", 10))}
+	_, err = s.NewRequest(context.Background(), jarID, req)
+	if err != nil {
+		t.Fatalf("NewRequest: %v", err)
+	}
+
+	err = s.DeleteJar(jarID)
+	if err != nil {
+		t.Fatalf("DeleteJar: %v", err)
+	}
+
+	_, err = blobs.Open(jarID + "/" + req.ID)
+	if !errors.Is(err, errors.ErrNotFound) {
+		t.Fatalf("expected the jar's bodies to be removed, got %v", err)
+	}
+}
+
+func TestNewRequestDecodesBody(t *testing.T) {
+	s := newTestService(t)
+	jarID, _ := s.CreateJar(&models.Jar{Name: "jar"})
+
+	_, err := s.SetRules(jarID, []models.ResponseRule{{
+		Match:    models.RequestMatcher{JSONBody: map[string]string{"event": "ping"}},
+		Response: models.MockResponse{StatusCode: 202},
+	}})
+	if err != nil {
+		t.Fatalf("SetRules: %v", err)
+	}
+
+	var compressed bytes.Buffer
+	gz := gzip.NewWriter(&compressed)
+	_, _ = gz.Write([]byte(`{"event":"ping"}`))
+	_ = gz.Close()
+
+	req := &models.Request{
+		Method:  "POST",
+		Headers: http.Header{"Content-Type": {"application/json"}, "Content-Encoding": {"gzip"}},
+		Body:    compressed.Bytes(),
+	}
+
+	response, err := s.NewRequest(context.Background(), jarID, req)
+	if err != nil {
+		t.Fatalf("NewRequest: %v", err)
+	}
+	if response == nil || response.StatusCode != 202 {
+		t.Fatalf("expected rules to match the decompressed body, got %+v", response)
+	}
+
+	if req.Decoded == nil || string(req.Decoded.JSON) != `{"event":"ping"}` {
+		t.Fatalf("expected a decoded JSON view, got %+v", req.Decoded)
+	}
+	if !bytes.Equal(req.Body, compressed.Bytes()) {
+		t.Fatal("expected the raw body to be kept")
+	}
+}
+
+func TestOffloadedBodiesKeepOnlyASummary(t *testing.T) {
+	blobs, err := blob.NewLocalStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewLocalStore: %v", err)
+	}
+
+	s := newTestService(t)
+	s.SetBlobStore(blobs, 4)
+	jarID, _ := s.CreateJar(&models.Jar{Name: "jar"})
+
+	req := &models.Request{
+		Method:  "POST",
+		Headers: http.Header{"Content-Type": {"text/plain"}},
+		Body:    []byte("more than four bytes"),
+	}
+
+	_, err = s.NewRequest(context.Background(), jarID, req)
+	if err != nil {
+		t.Fatalf("NewRequest: %v", err)
+	}
+
+	if req.Decoded == nil || req.Decoded.Text != "" || req.Decoded.Size != 20 {
+		t.Fatalf("expected a summary without the text, got %+v", req.Decoded)
+	}
+}
@@ -0,0 +1,285 @@
+package service
+
+import (
+	"fmt"
+	"log/slog"
+	"sync/atomic"
+
+	"github.com/bpietroniro/requestjar-go/internal/errors"
+	"github.com/bpietroniro/requestjar-go/internal/matcher"
+)
+
+// Slow consumer policies: what happens to a connection whose buffer is full
+// when an event arrives
+const (
+	// SlowConsumerDrop discards the event for that connection only
+	SlowConsumerDrop = "drop"
+	// SlowConsumerDisconnect closes the connection, so the client can
+	// reconnect and catch up
+	SlowConsumerDisconnect = "disconnect"
+)
+
+// defaultEventBuffer is how many events a connection can fall behind by
+const defaultEventBuffer = 64
+
+// Reasons a connection was closed by the service
+const (
+	CloseJarDeleted   = "jar deleted"
+	CloseSlowConsumer = "slow consumer"
+)
+
+// Connection is a live subscription to a jar's events. Each connection has a
+// bounded buffer, and events are never waited on: a connection that lets its
+// buffer fill up has events dropped or is closed, depending on the slow
+// consumer policy, so that slow clients can't hold up captures.
+type Connection struct {
+	// Events delivers the jar's events. It is closed when the service ends
+	// the connection, after which CloseReason says why.
+	Events <-chan *Event
+
+	jarID   string
+	events  chan *Event
+	filter  atomic.Pointer[matcher.Filter]
+	closed  bool // guarded by JarService.mu
+	reason  string
+	dropped atomic.Int64
+}
+
+// SetFilter changes which captured requests the connection is sent from now
+// on. A nil filter sends them all.
+func (c *Connection) SetFilter(filter *matcher.Filter) {
+	c.filter.Store(filter)
+}
+
+// Filter returns the connection's current filter.
+func (c *Connection) Filter() *matcher.Filter {
+	return c.filter.Load()
+}
+
+// wants reports whether an event should be sent to the connection. Filters
+// only apply to captured requests; every other event is always sent.
+func (c *Connection) wants(event *Event) bool {
+	if event.Type != EventRequestCreated {
+		return true
+	}
+
+	return c.Filter().Matches(event.matchable())
+}
+
+// CloseReason says why the service closed the connection. It is only
+// meaningful once Events has been closed.
+func (c *Connection) CloseReason() string {
+	return c.reason
+}
+
+// Dropped returns how many events the connection missed because its buffer was
+// full.
+func (c *Connection) Dropped() int64 {
+	return c.dropped.Load()
+}
+
+// EventStats summarizes event delivery since the service started.
+type EventStats struct {
+	Connections int   `json:"connections"`
+	Delivered   int64 `json:"delivered"`
+	Dropped     int64 `json:"dropped"`
+	// Evicted counts connections closed for being slow consumers
+	Evicted int64 `json:"evicted"`
+}
+
+type eventCounters struct {
+	delivered atomic.Int64
+	dropped   atomic.Int64
+	evicted   atomic.Int64
+}
+
+// SetEventBuffer sets how many undelivered events each new connection can
+// hold. It must be at least 1.
+func (s *JarService) SetEventBuffer(n int) error {
+	if n < 1 {
+		return errors.BadRequest(fmt.Sprintf("the event buffer must hold at least 1 event, got %d", n))
+	}
+
+	s.eventBuffer = n
+	return nil
+}
+
+// SetSlowConsumerPolicy chooses what happens to connections that fall more than
+// a buffer behind: SlowConsumerDrop or SlowConsumerDisconnect.
+func (s *JarService) SetSlowConsumerPolicy(policy string) error {
+	switch policy {
+	case SlowConsumerDrop, SlowConsumerDisconnect:
+	default:
+		return errors.BadRequest(fmt.Sprintf("slow consumer policy must be %q or %q", SlowConsumerDrop, SlowConsumerDisconnect))
+	}
+
+	s.slowConsumerPolicy = policy
+	return nil
+}
+
+// EventStats reports how many connections are open and what has happened to
+// the events sent to them.
+func (s *JarService) EventStats() EventStats {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return EventStats{
+		Connections: s.numConnections,
+		Delivered:   s.eventStats.delivered.Load(),
+		Dropped:     s.eventStats.dropped.Load(),
+		Evicted:     s.eventStats.evicted.Load(),
+	}
+}
+
+// SetConnectionLimits caps how many connections may be open to one jar and to
+// the whole service at once. Zero means no limit.
+func (s *JarService) SetConnectionLimits(perJar int, total int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.maxConnectionsPerJar = perJar
+	s.maxConnections = total
+}
+
+// AddConnection subscribes to a jar's events, or with a filter, to those
+// about the captured requests it matches. The connection must be removed with
+// RemoveConnection once the caller is done with it. It fails with a
+// TooManyRequests error when a connection limit has been reached.
+func (s *JarService) AddConnection(jarID string, filter *matcher.Filter) (*Connection, error) {
+	events := make(chan *Event, s.eventBuffer)
+	conn := &Connection{Events: events, jarID: jarID, events: events}
+	conn.SetFilter(filter)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.maxConnections > 0 && s.numConnections >= s.maxConnections {
+		return nil, errors.TooManyRequests("too many live connections, try again later")
+	}
+
+	if s.maxConnectionsPerJar > 0 && len(s.connections[jarID]) >= s.maxConnectionsPerJar {
+		return nil, errors.TooManyRequests("too many live connections to this jar, try again later")
+	}
+
+	_, exists := s.connections[jarID]
+	if !exists {
+		s.connections[jarID] = make(map[*Connection]struct{})
+	}
+
+	s.connections[jarID][conn] = struct{}{}
+	s.numConnections++
+
+	return conn, nil
+}
+
+// RemoveConnection unsubscribes a connection. It is safe to call on a
+// connection the service has already closed.
+func (s *JarService) RemoveConnection(conn *Connection) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.closeConnection(conn, "")
+}
+
+// closeConnection unregisters a connection and closes its channel. The caller
+// must hold s.mu.
+func (s *JarService) closeConnection(conn *Connection, reason string) {
+	if conn.closed {
+		return
+	}
+
+	conn.closed = true
+	conn.reason = reason
+	close(conn.events)
+
+	delete(s.connections[conn.jarID], conn)
+	s.numConnections--
+	if len(s.connections[conn.jarID]) == 0 {
+		delete(s.connections, conn.jarID)
+	}
+}
+
+// notifyClients hands an event to every connection of a jar without waiting
+// on any of them. Captures only share the read lock while they deliver; the
+// write lock is taken only when slow consumers have to be disconnected.
+func (s *JarService) notifyClients(jarID string, event *Event) {
+	slow := s.deliver(jarID, event)
+	if len(slow) == 0 {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, conn := range slow {
+		// Another capture may have got here first
+		if conn.closed {
+			continue
+		}
+
+		slog.Warn("disconnecting slow consumer", slog.String("jarID", jarID), slog.Int64("dropped", conn.Dropped()))
+		s.eventStats.evicted.Add(1)
+		s.closeConnection(conn, CloseSlowConsumer)
+	}
+}
+
+// deliver sends an event to the connections that want it and returns those
+// that have to be disconnected for having a full buffer.
+func (s *JarService) deliver(jarID string, event *Event) []*Connection {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	slog.Debug("notifying clients", slog.String("jarID", jarID), slog.String("event", event.Type), slog.Int("numConns", len(s.connections[jarID])))
+
+	var slow []*Connection
+	for conn := range s.connections[jarID] {
+		if !conn.wants(event) {
+			continue
+		}
+
+		select {
+		case conn.events <- event:
+			s.eventStats.delivered.Add(1)
+			continue
+		default:
+		}
+
+		conn.dropped.Add(1)
+		s.eventStats.dropped.Add(1)
+
+		if s.slowConsumerPolicy == SlowConsumerDisconnect {
+			slow = append(slow, conn)
+		}
+	}
+
+	return slow
+}
+
+// closeAllConnections sends every connection of a jar one last event and then
+// closes it. Nothing can follow the final event, so it is never dropped: when a
+// connection's buffer is full, its oldest event makes way instead.
+func (s *JarService) closeAllConnections(jarID string, final *Event) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	slog.Debug("closing all connections", slog.String("jarID", jarID), slog.Int("numConns", len(s.connections[jarID])))
+
+	for conn := range s.connections[jarID] {
+		select {
+		case conn.events <- final:
+		default:
+			// Nothing else sends while s.mu is held, so there is room once
+			// an event has been taken out, by this or by the reader
+			select {
+			case <-conn.events:
+				conn.dropped.Add(1)
+				s.eventStats.dropped.Add(1)
+			default:
+			}
+			conn.events <- final
+		}
+		s.eventStats.delivered.Add(1)
+
+		s.closeConnection(conn, CloseJarDeleted)
+	}
+}
@@ -0,0 +1,255 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bpietroniro/requestjar-go/internal/blob"
+	"github.com/bpietroniro/requestjar-go/internal/errors"
+	"github.com/bpietroniro/requestjar-go/internal/matcher"
+	"github.com/bpietroniro/requestjar-go/internal/models"
+)
+
+// captureWithin fails the test if capturing a request takes longer than d.
+func captureWithin(t *testing.T, s *JarService, jarID string, d time.Duration) {
+	t.Helper()
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := s.NewRequest(context.Background(), jarID, &models.Request{Method: "GET"})
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("NewRequest: %v", err)
+		}
+	case <-time.After(d):
+		t.Fatal("capture blocked on a slow connection")
+	}
+}
+
+func TestSlowConnectionsDropEvents(t *testing.T) {
+	s := newTestService(t)
+	_ = s.SetEventBuffer(2)
+	jarID, _ := s.CreateJar(&models.Jar{Name: "jar"})
+
+	slow, _ := s.AddConnection(jarID, nil)
+	fast, _ := s.AddConnection(jarID, nil)
+	defer s.RemoveConnection(slow)
+	defer s.RemoveConnection(fast)
+
+	received := 0
+	for range 5 {
+		captureWithin(t, s, jarID, time.Second)
+		<-fast.Events
+		received++
+	}
+
+	if received != 5 || fast.Dropped() != 0 {
+		t.Fatalf("expected the fast connection to get every event, got %d with %d dropped", received, fast.Dropped())
+	}
+	if len(slow.Events) != 2 || slow.Dropped() != 3 {
+		t.Fatalf("expected the slow connection to keep 2 events and drop 3, got %d and %d", len(slow.Events), slow.Dropped())
+	}
+
+	stats := s.EventStats()
+	if stats.Connections != 2 || stats.Delivered != 7 || stats.Dropped != 3 || stats.Evicted != 0 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+}
+
+func TestSlowConnectionsAreDisconnected(t *testing.T) {
+	s := newTestService(t)
+	_ = s.SetEventBuffer(1)
+	if err := s.SetSlowConsumerPolicy(SlowConsumerDisconnect); err != nil {
+		t.Fatalf("SetSlowConsumerPolicy: %v", err)
+	}
+	jarID, _ := s.CreateJar(&models.Jar{Name: "jar"})
+
+	slow, _ := s.AddConnection(jarID, nil)
+	defer s.RemoveConnection(slow)
+
+	captureWithin(t, s, jarID, time.Second)
+	captureWithin(t, s, jarID, time.Second)
+
+	// The buffered event is still delivered before the channel closes
+	if _, ok := <-slow.Events; !ok {
+		t.Fatal("expected the buffered event")
+	}
+	if _, ok := <-slow.Events; ok {
+		t.Fatal("expected the connection to be closed")
+	}
+	if slow.CloseReason() != CloseSlowConsumer {
+		t.Fatalf("unexpected close reason %q", slow.CloseReason())
+	}
+
+	stats := s.EventStats()
+	if stats.Connections != 0 || stats.Evicted != 1 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+
+	// Later events aren't sent to the closed connection
+	captureWithin(t, s, jarID, time.Second)
+}
+
+func TestConcurrentCapturesEvictSlowConnectionsOnce(t *testing.T) {
+	s := newTestService(t)
+	_ = s.SetEventBuffer(1)
+	_ = s.SetSlowConsumerPolicy(SlowConsumerDisconnect)
+	jarID, _ := s.CreateJar(&models.Jar{Name: "jar"})
+
+	for range 10 {
+		conn, _ := s.AddConnection(jarID, nil)
+		defer s.RemoveConnection(conn)
+	}
+
+	var wg sync.WaitGroup
+	for range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, _ = s.NewRequest(context.Background(), jarID, &models.Request{Method: "GET"})
+		}()
+	}
+	wg.Wait()
+
+	stats := s.EventStats()
+	if stats.Connections != 0 || stats.Evicted != 10 || stats.Delivered != 10 {
+		t.Fatalf("expected every connection to get one event and be evicted once, got %+v", stats)
+	}
+}
+
+func TestDeletingJarAlwaysDeliversTheFinalEvent(t *testing.T) {
+	s := newTestService(t)
+	_ = s.SetEventBuffer(1)
+	jarID, _ := s.CreateJar(&models.Jar{Name: "jar"})
+
+	conn, _ := s.AddConnection(jarID, nil)
+	_, _ = s.NewRequest(context.Background(), jarID, &models.Request{Method: "GET"})
+
+	// The buffer is full, so the capture gives way
+	if err := s.DeleteJar(jarID); err != nil {
+		t.Fatalf("DeleteJar: %v", err)
+	}
+
+	var events []*Event
+	for event := range conn.Events {
+		events = append(events, event)
+	}
+	if len(events) != 1 || events[0].Type != EventJarDeleted || conn.Dropped() != 1 {
+		t.Fatalf("expected only jar.deleted, with the capture dropped, got %+v and %d dropped", events, conn.Dropped())
+	}
+}
+
+func TestSetEventBufferRejectsEmptyBuffers(t *testing.T) {
+	s := newTestService(t)
+
+	for _, n := range []int{0, -1} {
+		err := s.SetEventBuffer(n)
+		if !errors.Is(err, errors.ErrBadRequest) {
+			t.Fatalf("expected a buffer of %d to be rejected, got %v", n, err)
+		}
+	}
+}
+
+func TestDeletingJarClosesConnections(t *testing.T) {
+	s := newTestService(t)
+	jarID, _ := s.CreateJar(&models.Jar{Name: "jar"})
+
+	conn, _ := s.AddConnection(jarID, nil)
+	if err := s.DeleteJar(jarID); err != nil {
+		t.Fatalf("DeleteJar: %v", err)
+	}
+
+	for range conn.Events {
+	}
+	if conn.CloseReason() != CloseJarDeleted {
+		t.Fatalf("unexpected close reason %q", conn.CloseReason())
+	}
+
+	// Removing a closed connection is harmless
+	s.RemoveConnection(conn)
+}
+
+func TestSetSlowConsumerPolicy(t *testing.T) {
+	s := newTestService(t)
+	if err := s.SetSlowConsumerPolicy("block"); err == nil {
+		t.Fatal("expected an unknown policy to be rejected")
+	}
+}
+
+func TestConnectionLimits(t *testing.T) {
+	s := newTestService(t)
+	s.SetConnectionLimits(2, 3)
+	jarA, _ := s.CreateJar(&models.Jar{Name: "a"})
+	jarB, _ := s.CreateJar(&models.Jar{Name: "b"})
+
+	first, _ := s.AddConnection(jarA, nil)
+	_, _ = s.AddConnection(jarA, nil)
+
+	_, err := s.AddConnection(jarA, nil)
+	if !errors.Is(err, errors.ErrTooManyRequests) {
+		t.Fatalf("expected the per-jar limit to apply, got %v", err)
+	}
+
+	_, err = s.AddConnection(jarB, nil)
+	if err != nil {
+		t.Fatalf("AddConnection: %v", err)
+	}
+
+	_, err = s.AddConnection(jarB, nil)
+	if !errors.Is(err, errors.ErrTooManyRequests) {
+		t.Fatalf("expected the global limit to apply, got %v", err)
+	}
+
+	// Closing a connection makes room for another
+	s.RemoveConnection(first)
+	_, err = s.AddConnection(jarB, nil)
+	if err != nil {
+		t.Fatalf("AddConnection after a removal: %v", err)
+	}
+}
+
+func TestFilteredConnections(t *testing.T) {
+	blobs, err := blob.NewLocalStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewLocalStore: %v", err)
+	}
+
+	s := newTestService(t)
+	s.SetBlobStore(blobs, 4)
+	jarID, _ := s.CreateJar(&models.Jar{Name: "jar"})
+
+	filter, _ := matcher.NewFilter(&models.RequestFilter{Methods: []string{"POST"}, BodyContains: "billing"})
+	conn, _ := s.AddConnection(jarID, filter)
+	defer s.RemoveConnection(conn)
+
+	_, _ = s.NewRequest(context.Background(), jarID, &models.Request{Method: "GET"})
+	_, _ = s.NewRequest(context.Background(), jarID, &models.Request{Method: "POST", Body: []byte("from shipping")})
+	// Filters see offloaded bodies in full
+	_, _ = s.NewRequest(context.Background(), jarID, &models.Request{Method: "POST", Body: []byte("from billing")})
+	_, _ = s.SetMockResponse(jarID, &models.MockResponse{StatusCode: 204})
+
+	if len(conn.Events) != 2 {
+		t.Fatalf("expected the matching request and the jar update, got %d events", len(conn.Events))
+	}
+
+	event := <-conn.Events
+	if event.Type != EventRequestCreated || !event.Request.BodyInfo.Offloaded {
+		t.Fatalf("expected the offloaded billing request, got %+v", event)
+	}
+	if event = <-conn.Events; event.Type != EventJarUpdated {
+		t.Fatalf("expected jar.updated to bypass the filter, got %+v", event)
+	}
+
+	// Changing the filter applies to later events
+	conn.SetFilter(nil)
+	_, _ = s.NewRequest(context.Background(), jarID, &models.Request{Method: "GET"})
+	if len(conn.Events) != 1 {
+		t.Fatalf("expected every request once the filter is cleared, got %d events", len(conn.Events))
+	}
+}
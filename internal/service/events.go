@@ -0,0 +1,77 @@
+package service
+
+import (
+	"time"
+
+	"github.com/bpietroniro/requestjar-go/internal/models"
+)
+
+// EventVersion is the version of the Event envelope. It changes whenever an
+// existing field changes meaning; new fields and event types don't change it.
+const EventVersion = 1
+
+// Event types pushed to a jar's live connections
+const (
+	EventRequestCreated   = "request.created"
+	EventRequestDeleted   = "request.deleted"
+	EventJarUpdated       = "jar.updated"
+	EventJarDeleted       = "jar.deleted"
+	EventJarCleared       = "jar.cleared"
+	EventRetentionEvicted = "retention.evicted"
+)
+
+// Reasons a jar was deleted, given in jar.deleted events
+const (
+	DeletedByRequest = "deleted"
+	DeletedExpired   = "expired"
+)
+
+// Event is delivered to every connection registered for a jar. Which of the
+// optional fields are set depends on the type:
+//
+//   - request.created: Request
+//   - request.deleted: RequestID
+//   - jar.updated: Jar, with its new settings
+//   - jar.deleted: Reason
+//   - jar.cleared: nothing more; every request was deleted
+//   - retention.evicted: RequestIDs
+type Event struct {
+	Version int       `json:"version"`
+	Type    string    `json:"type"`
+	JarID   string    `json:"jarID"`
+	Time    time.Time `json:"time"`
+
+	Request    *models.Request `json:"request,omitempty"`
+	RequestID  string          `json:"requestID,omitempty"`
+	RequestIDs []string        `json:"requestIDs,omitempty"`
+	Jar        *models.Jar     `json:"jar,omitempty"`
+	Reason     string          `json:"reason,omitempty"`
+
+	// captured is the request as it arrived, with its whole body even when
+	// Request's was offloaded, for filters to match against
+	captured *models.Request
+}
+
+// matchable returns the version of the event's request that filters should
+// see.
+func (e *Event) matchable() *models.Request {
+	if e.captured != nil {
+		return e.captured
+	}
+	return e.Request
+}
+
+func newEvent(eventType string, jarID string) *Event {
+	return &Event{Version: EventVersion, Type: eventType, JarID: jarID, Time: time.Now()}
+}
+
+// RequestCreatedEvent returns the event announcing a captured request. It is
+// exported so that requests a client missed can be sent as if they were live.
+func RequestCreatedEvent(jarID string, request *models.Request) *Event {
+	event := newEvent(EventRequestCreated, jarID)
+	if !request.CreatedAt.IsZero() {
+		event.Time = request.CreatedAt
+	}
+	event.Request = request
+	return event
+}
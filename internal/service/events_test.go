@@ -0,0 +1,158 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/bpietroniro/requestjar-go/internal/errors"
+	"github.com/bpietroniro/requestjar-go/internal/models"
+	"github.com/bpietroniro/requestjar-go/internal/store"
+)
+
+func TestMutationsPublishEvents(t *testing.T) {
+	s := newTestService(t)
+	jarID, _ := s.CreateJar(&models.Jar{Name: "jar"})
+
+	conn, _ := s.AddConnection(jarID, nil)
+	defer s.RemoveConnection(conn)
+
+	request := &models.Request{Method: "POST"}
+	_, _ = s.NewRequest(context.Background(), jarID, request)
+	_, _ = s.SetMockResponse(jarID, &models.MockResponse{StatusCode: 201})
+	_ = s.DeleteRequest(jarID, request.ID)
+	_, _ = s.NewRequest(context.Background(), jarID, &models.Request{Method: "GET"})
+	if err := s.ClearJar(jarID); err != nil {
+		t.Fatalf("ClearJar: %v", err)
+	}
+	_ = s.DeleteJar(jarID)
+
+	var events []*Event
+	for event := range conn.Events {
+		events = append(events, event)
+	}
+
+	want := []string{
+		EventRequestCreated, EventJarUpdated, EventRequestDeleted,
+		EventRequestCreated, EventJarCleared, EventJarDeleted,
+	}
+	if len(events) != len(want) {
+		t.Fatalf("expected %d events, got %d", len(want), len(events))
+	}
+	for i, event := range events {
+		if event.Type != want[i] || event.Version != EventVersion || event.JarID != jarID || event.Time.IsZero() {
+			t.Errorf("event %d: expected a %s envelope, got %+v", i, want[i], event)
+		}
+	}
+
+	if events[0].Request != request {
+		t.Errorf("expected request.created to carry the request, got %+v", events[0])
+	}
+	if events[1].Jar == nil || events[1].Jar.Response.StatusCode != 201 {
+		t.Errorf("expected jar.updated to carry the new settings, got %+v", events[1].Jar)
+	}
+	if events[2].RequestID != request.ID {
+		t.Errorf("expected request.deleted to name the request, got %+v", events[2])
+	}
+	if events[5].Reason != DeletedByRequest {
+		t.Errorf("unexpected deletion reason %q", events[5].Reason)
+	}
+}
+
+func TestDeletingAMissingRequestPublishesNothing(t *testing.T) {
+	s := newTestService(t)
+	jarID, _ := s.CreateJar(&models.Jar{Name: "jar"})
+
+	conn, _ := s.AddConnection(jarID, nil)
+	defer s.RemoveConnection(conn)
+
+	err := s.DeleteRequest(jarID, "missing")
+	if !errors.Is(err, errors.ErrNotFound) {
+		t.Fatalf("expected not found, got %v", err)
+	}
+
+	if len(conn.Events) != 0 {
+		t.Fatalf("expected no events, got %+v", <-conn.Events)
+	}
+}
+
+func TestClearJar(t *testing.T) {
+	s := newTestService(t)
+	jarID, _ := s.CreateJar(&models.Jar{Name: "jar"})
+	_, _ = s.NewRequest(context.Background(), jarID, &models.Request{Method: "GET"})
+
+	if err := s.ClearJar(jarID); err != nil {
+		t.Fatalf("ClearJar: %v", err)
+	}
+
+	_, requests, err := s.GetJarWithRequests(jarID)
+	if err != nil || len(requests) != 0 {
+		t.Fatalf("expected an empty jar, got %d requests, %v", len(requests), err)
+	}
+
+	// The jar still captures
+	if _, err = s.NewRequest(context.Background(), jarID, &models.Request{Method: "GET"}); err != nil {
+		t.Fatalf("NewRequest after clearing: %v", err)
+	}
+}
+
+// slowRequestStore takes a while to return from deletions, so that captures
+// overlap with whatever follows them
+type slowRequestStore struct {
+	store.RequestStore
+}
+
+func (s slowRequestStore) DeleteAllRrequests(jarID string) error {
+	err := s.RequestStore.DeleteAllRrequests(jarID)
+	time.Sleep(time.Millisecond)
+	return err
+}
+
+func (s slowRequestStore) ClearRequests(jarID string) ([]string, error) {
+	cleared, err := s.RequestStore.ClearRequests(jarID)
+	time.Sleep(time.Millisecond)
+	return cleared, err
+}
+
+func TestClearJarDoesNotInterruptCaptures(t *testing.T) {
+	s := NewJarService(store.NewInMemoryJarStore(), slowRequestStore{store.NewInMemoryRequestStore()})
+	t.Cleanup(s.Stop)
+	jarID, _ := s.CreateJar(&models.Jar{Name: "jar"})
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for range 20 {
+			_ = s.ClearJar(jarID)
+		}
+	}()
+
+	for {
+		select {
+		case <-done:
+			return
+		default:
+		}
+
+		_, err := s.NewRequest(context.Background(), jarID, &models.Request{Method: "GET"})
+		if err != nil {
+			t.Fatalf("expected captures to succeed while the jar is cleared, got %v", err)
+		}
+	}
+}
+
+func TestExpiredJarsAnnounceWhy(t *testing.T) {
+	s := newTestService(t)
+	expiresAt := time.Now().Add(time.Minute)
+	jarID, _ := s.CreateJar(&models.Jar{Name: "jar", ExpiresAt: &expiresAt})
+
+	conn, _ := s.AddConnection(jarID, nil)
+	defer s.RemoveConnection(conn)
+
+	s.sweepExpired(expiresAt.Add(time.Second))
+
+	event := <-conn.Events
+	if event.Type != EventJarDeleted || event.Reason != DeletedExpired {
+		t.Fatalf("expected an expiry to be announced, got %+v", event)
+	}
+}
@@ -0,0 +1,71 @@
+package service
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/bpietroniro/requestjar-go/internal/errors"
+	"github.com/bpietroniro/requestjar-go/internal/models"
+)
+
+func validateExpiry(expiresAt *time.Time) error {
+	if expiresAt != nil && !expiresAt.After(time.Now()) {
+		return errors.BadRequest("expiry must be in the future")
+	}
+
+	return nil
+}
+
+// ExtendJar moves the jar's expiry to expiresAt, which may be earlier or later
+// than the current one. A nil expiresAt makes the jar permanent.
+func (s *JarService) ExtendJar(jarID string, expiresAt *time.Time) (*models.Jar, error) {
+	err := validateExpiry(expiresAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.updateJar(jarID, func(jar *models.Jar) {
+		jar.ExpiresAt = expiresAt
+	})
+}
+
+// StartExpirySweeper deletes expired jars once per interval until Stop is
+// called.
+func (s *JarService) StartExpirySweeper(interval time.Duration) {
+	s.background.Add(1)
+	go func() {
+		defer s.background.Done()
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				s.sweepExpired(time.Now())
+			case <-s.stop:
+				return
+			}
+		}
+	}()
+}
+
+func (s *JarService) sweepExpired(now time.Time) {
+	jars, err := s.jarStore.List()
+	if err != nil {
+		slog.Error("expiry sweeper failed to list jars", slog.Any("error", err))
+		return
+	}
+
+	for _, jar := range jars {
+		if jar.ExpiresAt == nil || jar.ExpiresAt.After(now) {
+			continue
+		}
+
+		slog.Info("deleting expired jar", slog.String("jarID", jar.ID), slog.Time("expiresAt", *jar.ExpiresAt))
+		err = s.deleteJar(jar.ID, DeletedExpired)
+		if err != nil {
+			slog.Error("failed to delete expired jar", slog.String("jarID", jar.ID), slog.Any("error", err))
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bpietroniro/requestjar-go/internal/models"
+)
+
+func TestSweepExpired(t *testing.T) {
+	s := newTestService(t)
+
+	soon := time.Now().Add(time.Minute)
+	expiring, err := s.CreateJar(&models.Jar{Name: "expiring", ExpiresAt: &soon})
+	if err != nil {
+		t.Fatalf("CreateJar: %v", err)
+	}
+	permanent, _ := s.CreateJar(&models.Jar{Name: "permanent"})
+
+	later := time.Now().Add(time.Hour)
+	extended, _ := s.CreateJar(&models.Jar{Name: "extended", ExpiresAt: &soon})
+	_, err = s.ExtendJar(extended, &later)
+	if err != nil {
+		t.Fatalf("ExtendJar: %v", err)
+	}
+
+	s.sweepExpired(time.Now().Add(2 * time.Minute))
+
+	if _, err := s.GetJarMetadata(expiring); err == nil {
+		t.Fatal("expected the expired jar to be deleted")
+	}
+	for _, id := range []string{permanent, extended} {
+		if _, err := s.GetJarMetadata(id); err != nil {
+			t.Fatalf("expected jar %s to survive the sweep, got %v", id, err)
+		}
+	}
+}
+
+func TestExpiryMustBeInTheFuture(t *testing.T) {
+	s := newTestService(t)
+
+	past := time.Now().Add(-time.Minute)
+	_, err := s.CreateJar(&models.Jar{Name: "jar", ExpiresAt: &past})
+	if err == nil {
+		t.Fatal("expected an error creating a jar that has already expired")
+	}
+}
@@ -0,0 +1,91 @@
+package service
+
+import (
+	"math/rand/v2"
+	"net/http"
+
+	"github.com/bpietroniro/requestjar-go/internal/errors"
+	"github.com/bpietroniro/requestjar-go/internal/models"
+)
+
+func validateFaults(faults *models.FaultConfig) error {
+	if faults == nil {
+		return nil
+	}
+
+	if faults.Delay < 0 || faults.MaxDelay < 0 {
+		return errors.BadRequest("delays must not be negative")
+	}
+
+	if faults.MaxDelay != 0 && faults.MaxDelay < faults.Delay {
+		return errors.BadRequest("maxDelay must not be less than delay")
+	}
+
+	if faults.ErrorRate < 0 || faults.ErrorRate > 100 || faults.DropRate < 0 || faults.DropRate > 100 {
+		return errors.BadRequest("rates must be percentages between 0 and 100")
+	}
+
+	if faults.ErrorStatus != 0 && (faults.ErrorStatus < 500 || faults.ErrorStatus > 599) {
+		return errors.BadRequest("errorStatus must be a 5xx status code")
+	}
+
+	return nil
+}
+
+// SetFaults changes the faults injected into the jar's captures. A nil config
+// turns fault injection off.
+func (s *JarService) SetFaults(jarID string, faults *models.FaultConfig) (*models.Jar, error) {
+	err := validateFaults(faults)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.updateJar(jarID, func(jar *models.Jar) {
+		jar.Faults = faults
+	})
+}
+
+// rollFaults decides which faults to inject into one request, or returns nil
+// when there are none.
+func rollFaults(faults *models.FaultConfig) *models.InjectedFault {
+	if faults == nil {
+		return nil
+	}
+
+	var fault models.InjectedFault
+
+	fault.Delay = faults.Delay
+	if faults.MaxDelay > faults.Delay {
+		fault.Delay += models.Duration(rand.Int64N(int64(faults.MaxDelay - faults.Delay)))
+	}
+
+	// A dropped connection gets no response, so an error status would be moot
+	if chance(faults.DropRate) {
+		fault.Dropped = true
+	} else if chance(faults.ErrorRate) {
+		fault.Status = faults.ErrorStatus
+		if fault.Status == 0 {
+			fault.Status = http.StatusServiceUnavailable
+		}
+	}
+
+	if fault == (models.InjectedFault{}) {
+		return nil
+	}
+
+	return &fault
+}
+
+func chance(percent float64) bool {
+	return percent > 0 && rand.Float64()*100 < percent
+}
+
+// faultResponse is sent in place of the jar's mock response when an error was
+// injected.
+func faultResponse(fault *models.InjectedFault) *models.MockResponse {
+	return &models.MockResponse{
+		StatusCode:  fault.Status,
+		Body:        "injected fault: " + http.StatusText(fault.Status) + "\n",
+		ContentType: "text/plain; charset=utf-8",
+	}
+}
@@ -0,0 +1,51 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bpietroniro/requestjar-go/internal/models"
+)
+
+func TestRollFaults(t *testing.T) {
+	if fault := rollFaults(nil); fault != nil {
+		t.Fatalf("expected no fault without a config, got %+v", fault)
+	}
+
+	if fault := rollFaults(&models.FaultConfig{}); fault != nil {
+		t.Fatalf("expected no fault from an empty config, got %+v", fault)
+	}
+
+	fault := rollFaults(&models.FaultConfig{ErrorRate: 100})
+	if fault == nil || fault.Status != 503 || fault.Dropped {
+		t.Fatalf("expected a default 503, got %+v", fault)
+	}
+
+	fault = rollFaults(&models.FaultConfig{ErrorRate: 100, ErrorStatus: 502, DropRate: 100})
+	if fault == nil || !fault.Dropped || fault.Status != 0 {
+		t.Fatalf("expected a drop to take precedence over an error, got %+v", fault)
+	}
+
+	for i := 0; i < 100; i++ {
+		fault = rollFaults(&models.FaultConfig{Delay: models.Duration(time.Second), MaxDelay: models.Duration(2 * time.Second)})
+		if fault == nil || fault.Delay < models.Duration(time.Second) || fault.Delay >= models.Duration(2*time.Second) {
+			t.Fatalf("expected a delay in [1s, 2s), got %+v", fault)
+		}
+	}
+}
+
+func TestValidateFaults(t *testing.T) {
+	invalid := []models.FaultConfig{
+		{Delay: -1},
+		{Delay: models.Duration(time.Second), MaxDelay: models.Duration(time.Millisecond)},
+		{ErrorRate: 101},
+		{DropRate: -5},
+		{ErrorStatus: 404},
+	}
+
+	for _, faults := range invalid {
+		if err := validateFaults(&faults); err == nil {
+			t.Fatalf("expected %+v to be rejected", faults)
+		}
+	}
+}
@@ -0,0 +1,282 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/bpietroniro/requestjar-go/internal/errors"
+	"github.com/bpietroniro/requestjar-go/internal/models"
+	"github.com/bpietroniro/requestjar-go/internal/util"
+)
+
+const (
+	defaultForwardTimeout = 10 * time.Second
+	defaultForwardBackoff = 500 * time.Millisecond
+	maxForwardRetries     = 10
+	// defaultMaxForwards is how many forwards can be in flight in the
+	// background at once
+	defaultMaxForwards = 100
+	// maxUpstreamWait bounds how long a capture in upstream mode waits for
+	// its answer, retries and backoff included
+	maxUpstreamWait = 30 * time.Second
+)
+
+// ForwardedByHeader is added to every forwarded request and lists the jars it
+// has been forwarded by, so that a jar never forwards the same request twice
+// and forwarding loops end.
+const ForwardedByHeader = "X-Requestjar-Forwarded-By"
+
+// ForwardStats summarizes background forwarding since the service started.
+type ForwardStats struct {
+	InFlight int `json:"inFlight"`
+	// Dropped counts forwards that were skipped because too many were
+	// already in flight
+	Dropped int64 `json:"dropped"`
+}
+
+// SetMaxForwards limits how many forwards can be in flight in the background
+// at once. Captures that would go over the limit are still stored and
+// answered, but aren't forwarded.
+func (s *JarService) SetMaxForwards(n int) error {
+	if n < 1 {
+		return fmt.Errorf("the forward limit must be at least 1, got %d", n)
+	}
+
+	s.forwardSlots = make(chan struct{}, n)
+	return nil
+}
+
+// ForwardStats reports how many background forwards are in flight and how
+// many have been dropped.
+func (s *JarService) ForwardStats() ForwardStats {
+	return ForwardStats{
+		InFlight: len(s.forwardSlots),
+		Dropped:  s.forwardsDropped.Load(),
+	}
+}
+
+func validateForward(forward *models.ForwardConfig) error {
+	if forward == nil {
+		return nil
+	}
+
+	if len(forward.Targets) == 0 {
+		return errors.BadRequest("at least one forward target is required")
+	}
+
+	for i, target := range forward.Targets {
+		err := validateTarget(target)
+		if err != nil {
+			return errors.BadRequest(fmt.Sprintf("target %d: %v", i, err))
+		}
+	}
+
+	if forward.Timeout < 0 || forward.Backoff < 0 {
+		return errors.BadRequest("timeout and backoff must not be negative")
+	}
+
+	if forward.Retries < 0 || forward.Retries > maxForwardRetries {
+		return errors.BadRequest(fmt.Sprintf("retries must be between 0 and %d", maxForwardRetries))
+	}
+
+	switch forward.Respond {
+	case "", models.RespondWithJar, models.RespondWithUpstream:
+	default:
+		return errors.BadRequest(fmt.Sprintf("respond must be %q or %q", models.RespondWithJar, models.RespondWithUpstream))
+	}
+
+	return nil
+}
+
+// SetForward changes where the jar forwards captured requests. A nil config
+// turns forwarding off.
+func (s *JarService) SetForward(jarID string, forward *models.ForwardConfig) (*models.Jar, error) {
+	err := validateForward(forward)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.updateJar(jarID, func(jar *models.Jar) {
+		jar.Forward = forward
+	})
+}
+
+// forwardInBackground sends request to each of targets without waiting for
+// them. A target is skipped, and counted as dropped, when the limit on forwards
+// in flight has been reached. Stop cancels the forwards and waits for them to
+// be recorded; once it has been called, nothing more is forwarded.
+func (s *JarService) forwardInBackground(jarID string, forward *models.ForwardConfig, request *models.Request, targets []string) {
+	s.stopMu.RLock()
+	defer s.stopMu.RUnlock()
+
+	if s.stopped.Err() != nil {
+		return
+	}
+
+	for _, target := range targets {
+		select {
+		case s.forwardSlots <- struct{}{}:
+		default:
+			s.forwardsDropped.Add(1)
+			slog.Warn("too many forwards in flight, dropping one", slog.String("jarID", jarID), slog.String("reqID", request.ID), slog.String("target", target))
+			continue
+		}
+
+		s.background.Add(1)
+		go func() {
+			defer s.background.Done()
+			defer func() { <-s.forwardSlots }()
+			s.forwardTo(s.stopped, jarID, forward, request, target)
+		}()
+	}
+}
+
+// forwardedBy reports whether the jar has already forwarded a request with
+// these headers.
+func forwardedBy(headers http.Header, jarID string) bool {
+	for _, value := range headers.Values(ForwardedByHeader) {
+		for _, id := range strings.Split(value, ",") {
+			if strings.TrimSpace(id) == jarID {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// loopResponse answers a request that would otherwise have been forwarded by
+// the same jar a second time.
+func loopResponse(jarID string) *models.MockResponse {
+	return &models.MockResponse{
+		StatusCode: http.StatusLoopDetected,
+		Body:       "forwarding loop: the request was already forwarded by jar " + jarID + "\n",
+	}
+}
+
+// forwardTo sends request to one target, retrying connection errors and 5xx
+// responses with exponential backoff until ctx is done, and stores the outcome
+// as a replay.
+func (s *JarService) forwardTo(ctx context.Context, jarID string, forward *models.ForwardConfig, request *models.Request, target string) *models.Replay {
+	replay := &models.Replay{
+		ID:        util.GenerateID(),
+		RequestID: request.ID,
+		CreatedAt: time.Now(),
+		Target:    forwardURL(target, request),
+		Method:    request.Method,
+		Headers:   replayHeaders(request.Headers, nil),
+		Forwarded: true,
+	}
+	replay.Headers.Add(ForwardedByHeader, jarID)
+
+	timeout := time.Duration(forward.Timeout)
+	if timeout == 0 {
+		timeout = defaultForwardTimeout
+	}
+
+	backoff := time.Duration(forward.Backoff)
+	if backoff == 0 {
+		backoff = defaultForwardBackoff
+	}
+
+	for attempt := 1; ; attempt++ {
+		// Only the last attempt's outcome is recorded
+		replay.Attempts = attempt
+		replay.Response = nil
+		replay.Error = ""
+		replay.Duration = 0
+		replay.TimeToFirstByte = 0
+
+		attemptCtx, cancel := context.WithTimeout(ctx, timeout)
+		outbound, err := http.NewRequestWithContext(attemptCtx, replay.Method, replay.Target, bytes.NewReader(request.Body))
+		if err != nil {
+			cancel()
+			replay.Error = err.Error()
+			break
+		}
+		outbound.Header = replay.Headers.Clone()
+
+		send(s.forwardClient, replay, outbound)
+		cancel()
+
+		retryable := replay.Response == nil || replay.Response.StatusCode >= 500
+		if !retryable || attempt > forward.Retries {
+			break
+		}
+
+		slog.Debug("retrying forward", slog.String("jarID", jarID), slog.String("target", replay.Target), slog.Int("attempt", attempt))
+
+		select {
+		case <-time.After(backoff):
+			backoff *= 2
+			continue
+		case <-ctx.Done():
+		}
+
+		if replay.Error == "" {
+			replay.Error = "gave up retrying: " + ctx.Err().Error()
+		}
+		break
+	}
+
+	err := s.requestStore.CreateReplay(jarID, replay)
+	if err != nil {
+		slog.Error("failed to record forward", slog.String("jarID", jarID), slog.String("reqID", request.ID), slog.Any("error", err))
+	}
+
+	return replay
+}
+
+// forwardURL appends the captured path and query to a target's base URL.
+func forwardURL(target string, request *models.Request) string {
+	// Targets were validated when they were set
+	u, _ := url.Parse(target)
+
+	if request.Path != "" {
+		u = u.JoinPath(request.Path)
+	}
+
+	if request.RawQuery != "" {
+		if u.RawQuery != "" {
+			u.RawQuery += "&" + request.RawQuery
+		} else {
+			u.RawQuery = request.RawQuery
+		}
+	}
+
+	return u.String()
+}
+
+// upstreamResponse turns a forward's outcome into the response for the
+// caller whose request was forwarded: the upstream's response, or a 502 when
+// there wasn't one. Repeated headers are joined into one comma-separated
+// value.
+func upstreamResponse(replay *models.Replay) *models.MockResponse {
+	if replay.Response == nil {
+		return &models.MockResponse{
+			StatusCode: http.StatusBadGateway,
+			Body:       "forwarding failed: " + replay.Error + "\n",
+		}
+	}
+
+	headers := make(map[string]string, len(replay.Response.Headers))
+	for name, values := range replay.Response.Headers {
+		if slices.Contains(hopByHopHeaders, name) {
+			continue
+		}
+		headers[name] = strings.Join(values, ", ")
+	}
+
+	return &models.MockResponse{
+		StatusCode: replay.Response.StatusCode,
+		Headers:    headers,
+		Body:       string(replay.Response.Body),
+	}
+}
@@ -0,0 +1,338 @@
+package service
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/bpietroniro/requestjar-go/internal/models"
+)
+
+func TestValidateForward(t *testing.T) {
+	valid := []*models.ForwardConfig{
+		nil,
+		{Targets: []string{"http://localhost:3000"}},
+		{Targets: []string{"https://a.example.com", "http://b"}, Timeout: models.Duration(time.Second), Retries: 3, Respond: models.RespondWithUpstream},
+	}
+	for _, forward := range valid {
+		if err := validateForward(forward); err != nil {
+			t.Errorf("expected %+v to be valid, got %v", forward, err)
+		}
+	}
+
+	invalid := []*models.ForwardConfig{
+		{},
+		{Targets: []string{"localhost:3000"}},
+		{Targets: []string{"http://a"}, Retries: -1},
+		{Targets: []string{"http://a"}, Retries: maxForwardRetries + 1},
+		{Targets: []string{"http://a"}, Timeout: -1},
+		{Targets: []string{"http://a"}, Respond: "both"},
+	}
+	for _, forward := range invalid {
+		if err := validateForward(forward); err == nil {
+			t.Errorf("expected %+v to be rejected", forward)
+		}
+	}
+}
+
+func TestForwardRespondsWithUpstream(t *testing.T) {
+	var attempts atomic.Int32
+	var gotURL string
+	var gotBody []byte
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Fail the first attempt to exercise retries
+		if attempts.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		gotURL = r.URL.String()
+		gotBody, _ = io.ReadAll(r.Body)
+		w.Header().Add("X-Upstream", "a")
+		w.Header().Add("X-Upstream", "b")
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte("from upstream"))
+	}))
+	defer upstream.Close()
+
+	s := newTestService(t)
+	jarID, _ := s.CreateJar(&models.Jar{Name: "jar"})
+
+	_, err := s.SetForward(jarID, &models.ForwardConfig{
+		Targets: []string{upstream.URL + "/base?token=1"},
+		Retries: 2,
+		Backoff: models.Duration(time.Millisecond),
+		Respond: models.RespondWithUpstream,
+	})
+	if err != nil {
+		t.Fatalf("SetForward: %v", err)
+	}
+
+	req := &models.Request{Method: "POST", Path: "hooks/stripe", RawQuery: "a=1", Body: []byte("payload")}
+	response, err := s.NewRequest(context.Background(), jarID, req)
+	if err != nil {
+		t.Fatalf("NewRequest: %v", err)
+	}
+
+	if response.StatusCode != 201 || response.Body != "from upstream" || response.Headers["X-Upstream"] != "a, b" {
+		t.Fatalf("expected the upstream's response, got %+v", response)
+	}
+	if gotURL != "/base/hooks/stripe?token=1&a=1" || string(gotBody) != "payload" {
+		t.Fatalf("unexpected forwarded request %s %q", gotURL, gotBody)
+	}
+
+	replays, err := s.ListReplays(jarID, req.ID)
+	if err != nil || len(replays) != 1 {
+		t.Fatalf("expected the forward to be recorded, got %+v, %v", replays, err)
+	}
+	if !replays[0].Forwarded || replays[0].Attempts != 2 {
+		t.Fatalf("expected a forward that took two attempts, got %+v", replays[0])
+	}
+}
+
+func TestForwardFailureAnswers502(t *testing.T) {
+	upstream := httptest.NewServer(http.NotFoundHandler())
+	upstream.Close()
+
+	s := newTestService(t)
+	jarID, _ := s.CreateJar(&models.Jar{Name: "jar"})
+	_, _ = s.SetForward(jarID, &models.ForwardConfig{Targets: []string{upstream.URL}, Respond: models.RespondWithUpstream})
+
+	response, err := s.NewRequest(context.Background(), jarID, &models.Request{Method: "GET"})
+	if err != nil {
+		t.Fatalf("NewRequest: %v", err)
+	}
+	if response.StatusCode != http.StatusBadGateway {
+		t.Fatalf("expected a 502, got %+v", response)
+	}
+}
+
+func TestForwardStopsWaitingWhenTheCallerGoesAway(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer upstream.Close()
+
+	s := newTestService(t)
+	jarID, _ := s.CreateJar(&models.Jar{Name: "jar"})
+	_, _ = s.SetForward(jarID, &models.ForwardConfig{
+		Targets: []string{upstream.URL},
+		Retries: maxForwardRetries,
+		Backoff: models.Duration(time.Second),
+		Respond: models.RespondWithUpstream,
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	req := &models.Request{Method: "GET"}
+	response, err := s.NewRequest(ctx, jarID, req)
+	if err != nil {
+		t.Fatalf("NewRequest: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Fatalf("expected to stop retrying once the caller was gone, took %v", elapsed)
+	}
+	if response.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("expected the last upstream response, got %+v", response)
+	}
+
+	replays, _ := s.ListReplays(jarID, req.ID)
+	if len(replays) != 1 || replays[0].Attempts != 1 || replays[0].Error == "" {
+		t.Fatalf("expected one recorded attempt and why retrying stopped, got %+v", replays)
+	}
+}
+
+func TestForwardRecordsOnlyTheLastAttempt(t *testing.T) {
+	var attempts atomic.Int32
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if attempts.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		// No response at all the second time
+		conn, _, _ := w.(http.Hijacker).Hijack()
+		_ = conn.Close()
+	}))
+	defer upstream.Close()
+
+	s := newTestService(t)
+	jarID, _ := s.CreateJar(&models.Jar{Name: "jar"})
+	_, _ = s.SetForward(jarID, &models.ForwardConfig{
+		Targets: []string{upstream.URL},
+		Retries: 1,
+		Backoff: models.Duration(time.Millisecond),
+		Respond: models.RespondWithUpstream,
+	})
+
+	req := &models.Request{Method: "GET"}
+	_, _ = s.NewRequest(context.Background(), jarID, req)
+
+	replays, _ := s.ListReplays(jarID, req.ID)
+	if len(replays) != 1 || replays[0].Attempts != 2 || replays[0].Response != nil {
+		t.Fatalf("expected a second attempt without a response, got %+v", replays)
+	}
+	if replays[0].TimeToFirstByte != 0 {
+		t.Fatalf("expected no time to first byte from the failed attempt, got %v", replays[0].TimeToFirstByte)
+	}
+}
+
+func TestForwardRespondsWithJar(t *testing.T) {
+	forwarded := make(chan string, 1)
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		forwarded <- r.Method
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer upstream.Close()
+
+	s := newTestService(t)
+	jarID, _ := s.CreateJar(&models.Jar{Name: "jar"})
+	_, _ = s.SetMockResponse(jarID, &models.MockResponse{StatusCode: 204})
+	_, _ = s.SetForward(jarID, &models.ForwardConfig{Targets: []string{upstream.URL}})
+
+	response, err := s.NewRequest(context.Background(), jarID, &models.Request{Method: "DELETE"})
+	if err != nil {
+		t.Fatalf("NewRequest: %v", err)
+	}
+	if response.StatusCode != 204 {
+		t.Fatalf("expected the jar's own response, got %+v", response)
+	}
+
+	select {
+	case method := <-forwarded:
+		if method != "DELETE" {
+			t.Fatalf("expected a forwarded DELETE, got %s", method)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the request to be forwarded in the background")
+	}
+}
+
+func TestForwardAnswersWithoutWaitingForOtherTargets(t *testing.T) {
+	first := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer first.Close()
+
+	release := make(chan struct{})
+	second := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer second.Close()
+	defer close(release)
+
+	s := newTestService(t)
+	jarID, _ := s.CreateJar(&models.Jar{Name: "jar"})
+	_, _ = s.SetForward(jarID, &models.ForwardConfig{
+		Targets: []string{first.URL, second.URL},
+		Respond: models.RespondWithUpstream,
+	})
+
+	response, err := s.NewRequest(context.Background(), jarID, &models.Request{Method: "POST"})
+	if err != nil {
+		t.Fatalf("NewRequest: %v", err)
+	}
+	if response.StatusCode != http.StatusAccepted {
+		t.Fatalf("expected the first target's response, got %+v", response)
+	}
+	if stats := s.ForwardStats(); stats.InFlight != 1 {
+		t.Fatalf("expected the second target to still be in flight, got %+v", stats)
+	}
+}
+
+func TestForwardDropsBeyondTheLimit(t *testing.T) {
+	release := make(chan struct{})
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer upstream.Close()
+	defer close(release)
+
+	s := newTestService(t)
+	if err := s.SetMaxForwards(0); err == nil {
+		t.Fatal("expected a limit of 0 to be rejected")
+	}
+	_ = s.SetMaxForwards(1)
+
+	jarID, _ := s.CreateJar(&models.Jar{Name: "jar"})
+	_, _ = s.SetForward(jarID, &models.ForwardConfig{Targets: []string{upstream.URL}})
+
+	for range 3 {
+		_, err := s.NewRequest(context.Background(), jarID, &models.Request{Method: "POST"})
+		if err != nil {
+			t.Fatalf("NewRequest: %v", err)
+		}
+	}
+
+	if stats := s.ForwardStats(); stats.InFlight != 1 || stats.Dropped != 2 {
+		t.Fatalf("expected one forward in flight and two dropped, got %+v", stats)
+	}
+}
+
+func TestStopCancelsAndWaitsForForwards(t *testing.T) {
+	arrived := make(chan struct{}, 1)
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		arrived <- struct{}{}
+		<-r.Context().Done()
+	}))
+	defer upstream.Close()
+
+	s := newTestService(t)
+	jarID, _ := s.CreateJar(&models.Jar{Name: "jar"})
+	_, _ = s.SetForward(jarID, &models.ForwardConfig{Targets: []string{upstream.URL}})
+
+	req := &models.Request{Method: "GET"}
+	_, _ = s.NewRequest(context.Background(), jarID, req)
+	<-arrived
+
+	s.Stop()
+
+	replays, _ := s.ListReplays(jarID, req.ID)
+	if len(replays) != 1 || replays[0].Error == "" {
+		t.Fatalf("expected the cancelled forward to be recorded before Stop returned, got %+v", replays)
+	}
+
+	// Nothing is forwarded once the service has stopped
+	late := &models.Request{Method: "GET"}
+	_, _ = s.NewRequest(context.Background(), jarID, late)
+	if stats := s.ForwardStats(); stats.InFlight != 0 {
+		t.Fatalf("expected no forwards in flight after Stop, got %+v", stats)
+	}
+}
+
+func TestForwardLoopIsRefused(t *testing.T) {
+	s := newTestService(t)
+	jarID, _ := s.CreateJar(&models.Jar{Name: "jar"})
+
+	// A target that leads straight back to the jar
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		response, err := s.NewRequest(context.Background(), jarID, &models.Request{Method: r.Method, Headers: r.Header})
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(response.StatusCode)
+	}))
+	defer upstream.Close()
+
+	_, _ = s.SetForward(jarID, &models.ForwardConfig{Targets: []string{upstream.URL}, Respond: models.RespondWithUpstream})
+
+	response, err := s.NewRequest(context.Background(), jarID, &models.Request{Method: "POST"})
+	if err != nil {
+		t.Fatalf("NewRequest: %v", err)
+	}
+	if response.StatusCode != http.StatusLoopDetected {
+		t.Fatalf("expected the loop to be detected, got %+v", response)
+	}
+
+	requests, _ := s.ListRequests(jarID, "", 10)
+	if len(requests) != 2 {
+		t.Fatalf("expected the request to be captured twice, got %d", len(requests))
+	}
+}
@@ -0,0 +1,115 @@
+package service
+
+import (
+	"io"
+	"log/slog"
+
+	"github.com/bpietroniro/requestjar-go/internal/decoding"
+	"github.com/bpietroniro/requestjar-go/internal/errors"
+	"github.com/bpietroniro/requestjar-go/internal/har"
+	"github.com/bpietroniro/requestjar-go/internal/models"
+	"github.com/bpietroniro/requestjar-go/internal/util"
+)
+
+// ExportHAR writes an HTTP Archive of every request in the jar to w, with the
+// responses they were sent. host is used for requests captured without one.
+// Requests are loaded and written one at a time, so offloaded bodies are only
+// ever read into memory one by one. Nothing is written if the jar can't be
+// read; an error after that leaves the archive unfinished.
+func (s *JarService) ExportHAR(w io.Writer, jarID string, host string) error {
+	_, requests, err := s.GetJarWithRequests(jarID)
+	if err != nil {
+		return err
+	}
+
+	archive := har.NewWriter(w, jarID, host)
+	for _, stored := range requests {
+		// A copy, so that loading the body and response leaves the store's
+		// request alone
+		req := *stored
+
+		req.Body, err = s.readBody(jarID, stored)
+		if err != nil {
+			return err
+		}
+
+		// Requests answered by a forward target have no response of their own
+		if req.Response == nil && (req.Fault == nil || !req.Fault.Dropped) {
+			req.Response, err = s.forwardedResponse(jarID, req.ID)
+			if err != nil {
+				return err
+			}
+		}
+
+		err = archive.WriteEntry(&req)
+		if err != nil {
+			return err
+		}
+	}
+
+	return archive.Close()
+}
+
+// forwardedResponse returns the response a forward target gave for a request,
+// or nil if it wasn't forwarded.
+func (s *JarService) forwardedResponse(jarID string, reqID string) (*models.MockResponse, error) {
+	replays, err := s.requestStore.ListReplays(jarID, reqID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, replay := range replays {
+		if replay.Forwarded {
+			return upstreamResponse(replay), nil
+		}
+	}
+
+	return nil, nil
+}
+
+// ImportHAR creates a jar named name holding the requests recorded in archive,
+// and returns its ID and how many requests it holds.
+func (s *JarService) ImportHAR(name string, archive *har.HAR) (string, int, error) {
+	requests, err := har.Import(archive)
+	if err != nil {
+		return "", 0, errors.BadRequest("invalid HAR: " + err.Error())
+	}
+
+	jarID, err := s.CreateJar(&models.Jar{Name: name})
+	if err != nil {
+		return "", 0, err
+	}
+
+	for _, request := range requests {
+		err = s.importRequest(jarID, request)
+		if err != nil {
+			// Don't leave a half-imported jar behind
+			deleteErr := s.DeleteJar(jarID)
+			if deleteErr != nil {
+				slog.Error("failed to delete partially imported jar", slog.String("jarID", jarID), slog.Any("error", deleteErr))
+			}
+			return "", 0, err
+		}
+	}
+
+	slog.Info("imported HAR", slog.String("jarID", jarID), slog.Int("requests", len(requests)))
+	return jarID, len(requests), nil
+}
+
+func (s *JarService) importRequest(jarID string, request *models.Request) error {
+	request.ID = util.GenerateID()
+	request.Decoded = decoding.Body(request.Headers, request.Body, s.decodeLimit())
+
+	err := s.storeBody(jarID, request)
+	if err != nil {
+		return err
+	}
+
+	err = s.requestStore.CreateRequest(jarID, request)
+	if err != nil {
+		s.deleteBody(jarID, request.ID)
+		return err
+	}
+
+	return nil
+}
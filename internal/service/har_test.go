@@ -0,0 +1,131 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/bpietroniro/requestjar-go/internal/blob"
+	"github.com/bpietroniro/requestjar-go/internal/errors"
+	"github.com/bpietroniro/requestjar-go/internal/har"
+	"github.com/bpietroniro/requestjar-go/internal/models"
+)
+
+func TestExportAndImportHAR(t *testing.T) {
+	blobs, err := blob.NewLocalStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewLocalStore: %v", err)
+	}
+
+	s := newTestService(t)
+	s.SetBlobStore(blobs, 4)
+
+	jarID, _ := s.CreateJar(&models.Jar{Name: "source"})
+	_, _ = s.SetMockResponse(jarID, &models.MockResponse{StatusCode: 202, Body: "queued"})
+
+	_, _ = s.NewRequest(context.Background(), jarID, &models.Request{Method: "GET", Path: "ping"})
+	_, _ = s.NewRequest(context.Background(), jarID, &models.Request{
+		Method:  "POST",
+		Path:    "hooks",
+		Headers: http.Header{"Content-Type": {"application/json"}},
+		Body:    []byte(`{"offloaded":true}`),
+	})
+
+	archive := exportHAR(t, s, jarID, "localhost:8080")
+	if len(archive.Log.Entries) != 2 {
+		t.Fatalf("expected 2 entries, got %d", len(archive.Log.Entries))
+	}
+
+	hooks := archive.Log.Entries[1]
+	if hooks.Request.PostData == nil || hooks.Request.PostData.Text != `{"offloaded":true}` {
+		t.Fatalf("expected the offloaded body to be exported, got %+v", hooks.Request.PostData)
+	}
+	if hooks.Response.Status != 202 || hooks.Response.Content.Text != "queued" {
+		t.Fatalf("expected the mock response to be exported, got %+v", hooks.Response)
+	}
+
+	importedID, count, err := s.ImportHAR("copy", archive)
+	if err != nil || count != 2 {
+		t.Fatalf("ImportHAR: %d, %v", count, err)
+	}
+
+	jar, requests, err := s.GetJarWithRequests(importedID)
+	if err != nil {
+		t.Fatalf("GetJarWithRequests: %v", err)
+	}
+	if jar.Name != "copy" || len(requests) != 2 {
+		t.Fatalf("unexpected imported jar %+v with %d requests", jar, len(requests))
+	}
+
+	imported := requests[1]
+	if imported.Path != "hooks" || imported.BodyInfo == nil || !imported.BodyInfo.Offloaded || imported.Decoded == nil {
+		t.Fatalf("expected the import to be stored like a capture, got %+v", imported)
+	}
+	if imported.Response == nil || imported.Response.StatusCode != 202 {
+		t.Fatalf("expected the response to be imported, got %+v", imported.Response)
+	}
+}
+
+func TestExportHARIncludesForwardedResponses(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	}))
+	defer upstream.Close()
+
+	s := newTestService(t)
+	jarID, _ := s.CreateJar(&models.Jar{Name: "jar"})
+	_, _ = s.SetForward(jarID, &models.ForwardConfig{Targets: []string{upstream.URL}, Respond: models.RespondWithUpstream})
+	_, _ = s.NewRequest(context.Background(), jarID, &models.Request{Method: "GET"})
+
+	archive := exportHAR(t, s, jarID, "localhost")
+	if archive.Log.Entries[0].Response.Status != http.StatusTeapot {
+		t.Fatalf("expected the upstream's response, got %+v", archive.Log.Entries[0].Response)
+	}
+}
+
+func TestExportHARWritesNothingForMissingJars(t *testing.T) {
+	s := newTestService(t)
+
+	var buf bytes.Buffer
+	err := s.ExportHAR(&buf, "missing", "localhost")
+	if !errors.Is(err, errors.ErrNotFound) || buf.Len() != 0 {
+		t.Fatalf("expected a not found error and no output, got %v and %q", err, buf.String())
+	}
+}
+
+// exportHAR streams a jar's archive and decodes it.
+func exportHAR(t *testing.T, s *JarService, jarID string, host string) *har.HAR {
+	t.Helper()
+
+	var buf bytes.Buffer
+	err := s.ExportHAR(&buf, jarID, host)
+	if err != nil {
+		t.Fatalf("ExportHAR: %v", err)
+	}
+
+	var archive har.HAR
+	err = json.Unmarshal(buf.Bytes(), &archive)
+	if err != nil {
+		t.Fatalf("decoding the archive: %v", err)
+	}
+
+	return &archive
+}
+
+func TestImportHARRejectsInvalidArchives(t *testing.T) {
+	s := newTestService(t)
+
+	archive := &har.HAR{Log: har.Log{Entries: []har.Entry{{Request: har.Request{URL: "/"}}}}}
+	_, _, err := s.ImportHAR("bad", archive)
+	if !errors.Is(err, errors.ErrBadRequest) {
+		t.Fatalf("expected a bad request, got %v", err)
+	}
+
+	jars, _ := s.ListAllJarMetadata()
+	if len(jars) != 0 {
+		t.Fatalf("expected no jar to be created, got %d", len(jars))
+	}
+}
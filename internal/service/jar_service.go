@@ -1,30 +1,89 @@
 package service
 
 import (
+	"context"
 	"log/slog"
+	"net/http"
 	"sync"
+	"sync/atomic"
 
+	"github.com/bpietroniro/requestjar-go/internal/blob"
+	"github.com/bpietroniro/requestjar-go/internal/decoding"
 	"github.com/bpietroniro/requestjar-go/internal/errors"
 	"github.com/bpietroniro/requestjar-go/internal/models"
 	"github.com/bpietroniro/requestjar-go/internal/store"
+	"github.com/bpietroniro/requestjar-go/internal/templating"
+	"github.com/bpietroniro/requestjar-go/internal/util"
 )
 
 type JarService struct {
 	jarStore     store.JarStore
 	requestStore store.RequestStore
-	connections  map[string]map[chan *models.Request]struct{} // essentially a map of sets
+	connections  map[string]map[*Connection]struct{} // essentially a map of sets
 	mu           sync.RWMutex
+	retentionMu  sync.Mutex
+	updateMu     sync.Mutex // makes updateJar's read, change and write atomic
+	stop         chan struct{}
+	stopped      context.Context // cancelled along with stop
+	cancel       context.CancelFunc
+	stopOnce     sync.Once
+	background   sync.WaitGroup // the reaper, the sweeper and forwards
+	stopMu       sync.RWMutex   // held by Stop so nothing is added to background after it
+
+	// Body handling; see body.go
+	maxBodySize      int64
+	blobs            blob.Store
+	offloadThreshold int64
+
+	replayClient  *http.Client
+	forwardClient *http.Client // without a timeout; forwards set their own
+
+	// Background forwarding; see forward.go
+	forwardSlots    chan struct{} // holds one token per forward in flight
+	forwardsDropped atomic.Int64
+
+	// Event delivery; see connections.go
+	eventBuffer          int
+	slowConsumerPolicy   string
+	eventStats           eventCounters
+	numConnections       int // guarded by mu, like connections
+	maxConnectionsPerJar int
+	maxConnections       int
 }
 
 func NewJarService(jarStore store.JarStore, requestStore store.RequestStore) *JarService {
 	slog.Info("creating new jar service dependency")
+	stopped, cancel := context.WithCancel(context.Background())
 	return &JarService{
-		jarStore: jarStore, requestStore: requestStore, connections: make(map[string]map[chan *models.Request]struct{}),
+		jarStore: jarStore, requestStore: requestStore, connections: make(map[string]map[*Connection]struct{}),
+		stop:               make(chan struct{}),
+		stopped:            stopped,
+		cancel:             cancel,
+		replayClient:       newReplayClient(defaultReplayTimeout),
+		forwardClient:      newReplayClient(0),
+		forwardSlots:       make(chan struct{}, defaultMaxForwards),
+		eventBuffer:        defaultEventBuffer,
+		slowConsumerPolicy: SlowConsumerDrop,
 	}
 }
 
-func (s *JarService) CreateJar(name string) (string, error) {
-	jarID, err := s.jarStore.Create(name)
+func (s *JarService) CreateJar(jar *models.Jar) (string, error) {
+	err := validateRetention(jar.Retention)
+	if err != nil {
+		return "", err
+	}
+
+	err = validateExpiry(jar.ExpiresAt)
+	if err != nil {
+		return "", err
+	}
+
+	err = validateMaxBodySize(jar.MaxBodySize)
+	if err != nil {
+		return "", err
+	}
+
+	jarID, err := s.jarStore.Create(jar)
 	if err != nil {
 		return "", err
 	}
@@ -38,6 +97,12 @@ func (s *JarService) CreateJar(name string) (string, error) {
 }
 
 func (s *JarService) DeleteJar(jarID string) error {
+	return s.deleteJar(jarID, DeletedByRequest)
+}
+
+// deleteJar deletes a jar and its requests, then tells the jar's connections
+// why before closing them.
+func (s *JarService) deleteJar(jarID string, reason string) error {
 	slog.Info("deleting all requests for jar...", slog.String("jarID", jarID))
 	err := s.requestStore.DeleteAllRrequests(jarID)
 	if err != nil {
@@ -50,8 +115,13 @@ func (s *JarService) DeleteJar(jarID string) error {
 		return err
 	}
 
+	s.deleteJarBodies(jarID)
+
+	event := newEvent(EventJarDeleted, jarID)
+	event.Reason = reason
+
 	slog.Info("closing all connections for jar...", slog.String("jarID", jarID))
-	s.closeAllConnections(jarID)
+	s.closeAllConnections(jarID, event)
 	return nil
 }
 
@@ -77,70 +147,191 @@ func (s *JarService) GetJarWithRequests(jarID string) (*models.Jar, []*models.Re
 	return jarMetadata, requests, nil
 }
 
-func (s *JarService) AddConnection(jarID string, eventChan chan *models.Request) error {
-	s.mu.Lock()
-	defer s.mu.Unlock()
+// ListRequests pages through a jar's requests in capture order. Pass the ID of
+// the last request of one page as afterID to get the next.
+func (s *JarService) ListRequests(jarID string, afterID string, limit int) ([]*models.Request, error) {
+	_, err := s.jarStore.Get(jarID)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.requestStore.ListAfter(jarID, afterID, limit)
+}
+
+// updateJar applies change to a copy of the stored jar and saves the result.
+// Updates are applied one at a time, so concurrent changes to different
+// settings don't undo each other.
+func (s *JarService) updateJar(jarID string, change func(jar *models.Jar)) (*models.Jar, error) {
+	s.updateMu.Lock()
+	defer s.updateMu.Unlock()
 
-	_, exists := s.connections[jarID]
-	if !exists {
-		s.connections[jarID] = make(map[chan *models.Request]struct{})
+	jar, err := s.jarStore.Get(jarID)
+	if err != nil {
+		return nil, err
 	}
 
-	s.connections[jarID][eventChan] = struct{}{}
+	updated := *jar
+	change(&updated)
 
-	return nil
+	err = s.jarStore.Update(&updated)
+	if err != nil {
+		return nil, err
+	}
+
+	event := newEvent(EventJarUpdated, jarID)
+	event.Jar = &updated
+	s.notifyClients(jarID, event)
+
+	return &updated, nil
 }
 
-func (s *JarService) RemoveConnection(jarID string, eventChan chan *models.Request) error {
-	s.mu.Lock()
-	defer s.mu.Unlock()
+// NewRequest stores a captured request, notifies the jar's connections and
+// returns the response the caller should be sent (see resolveResponse), or nil
+// for an empty 200. Any faults to inject are recorded on request.Fault for the
+// caller to act on, and the response is recorded on request.Response. In
+// upstream mode the response is waited for until ctx is done, and for no
+// longer than maxUpstreamWait.
+func (s *JarService) NewRequest(ctx context.Context, jarID string, request *models.Request) (*models.MockResponse, error) {
+	jar, err := s.jarStore.Get(jarID)
+	if err != nil {
+		return nil, err
+	}
 
-	_, exists := s.connections[jarID]
-	if !exists {
-		return errors.NotFound("no connections found")
+	if request.ID == "" {
+		request.ID = util.GenerateID()
 	}
 
-	delete(s.connections[jarID], eventChan)
-	return nil
-}
+	request.Fault = rollFaults(jar.Faults)
+
+	request.Decoded = decoding.Body(request.Headers, request.Body, s.decodeLimit())
+
+	// Rules, templates and forwards see the whole body even when it gets
+	// offloaded
+	captured := *request
+
+	forward := jar.Forward
+	looped := forward != nil && forwardedBy(request.Headers, jarID)
+	if looped {
+		slog.Warn("not forwarding a request the jar already forwarded", slog.String("jarID", jarID), slog.String("reqID", request.ID))
+		forward = nil
+	}
+
+	injectedStatus := request.Fault != nil && request.Fault.Status != 0
+	// Injected errors still take precedence over the upstream's answer
+	respondWithUpstream := forward != nil && forward.Respond == models.RespondWithUpstream && !injectedStatus
+
+	var response *models.MockResponse
+	var renderErr error
+
+	switch {
+	case respondWithUpstream:
+		// Answered once the request has been stored and forwarded
+	case injectedStatus:
+		response = faultResponse(request.Fault)
+	case looped && jar.Forward.Respond == models.RespondWithUpstream:
+		response = loopResponse(jarID)
+	default:
+		response, renderErr = templating.Render(resolveResponse(jar, &captured), &captured)
+	}
 
-func (s *JarService) NewRequest(jarID string, request *models.Request) error {
-	err := s.requestStore.CreateRequest(jarID, request)
+	if renderErr == nil && !respondWithUpstream {
+		request.Response = response
+		if response == nil {
+			request.Response = &models.MockResponse{StatusCode: http.StatusOK}
+		}
+	}
 
+	err = s.storeBody(jarID, request)
 	if err != nil {
-		return err
+		return nil, err
 	}
+	captured.BodyInfo = request.BodyInfo
 
-	s.notifyClients(jarID, request)
+	err = s.requestStore.CreateRequest(jarID, request)
 
-	return nil
+	if err != nil {
+		s.deleteBody(jarID, request.ID)
+		return nil, err
+	}
+
+	event := RequestCreatedEvent(jarID, request)
+	event.captured = &captured
+	s.notifyClients(jarID, event)
+
+	// Age limits are left to the reaper; count and size limits are kept exact
+	if jar.Retention != nil && (jar.Retention.MaxRequests > 0 || jar.Retention.MaxBodyBytes > 0) {
+		err = s.enforceRetention(jar)
+		if err != nil {
+			slog.Error("failed to enforce retention", slog.String("jarID", jarID), slog.Any("error", err))
+		}
+	}
+
+	if respondWithUpstream {
+		// Only the first target's answer is needed; the rest can catch up
+		s.forwardInBackground(jarID, forward, &captured, forward.Targets[1:])
+		ctx, cancel := context.WithTimeout(ctx, maxUpstreamWait)
+		defer cancel()
+		return upstreamResponse(s.forwardTo(ctx, jarID, forward, &captured, forward.Targets[0])), nil
+	}
+
+	if forward != nil {
+		s.forwardInBackground(jarID, forward, &captured, forward.Targets)
+	}
+
+	if renderErr != nil {
+		slog.Error("failed to render mock response", slog.String("jarID", jarID), slog.String("reqID", request.ID), slog.Any("error", renderErr))
+		return nil, errors.Internal("request captured, but its mock response failed to render")
+	}
+
+	return response, nil
 }
 
 func (s *JarService) DeleteRequest(jarID string, reqID string) error {
-	return s.requestStore.DeleteOneRequest(jarID, reqID)
-}
+	err := s.requestStore.DeleteOneRequest(jarID, reqID)
+	if err != nil {
+		return err
+	}
 
-func (s *JarService) notifyClients(jarID string, request *models.Request) {
-	slog.Debug("notifying clients", slog.String("jarID", jarID), slog.String("reqID", request.ID), slog.Int("numConns", len(s.connections[jarID])))
-	s.mu.RLock()
-	defer s.mu.RUnlock()
+	s.deleteBody(jarID, reqID)
 
-	for c := range s.connections[jarID] {
-		c <- request
-	}
+	event := newEvent(EventRequestDeleted, jarID)
+	event.RequestID = reqID
+	s.notifyClients(jarID, event)
+
+	return nil
 }
 
-func (s *JarService) closeAllConnections(jarID string) {
-	slog.Debug("closing all connections", slog.String("jarID", jarID), slog.Int("numConns", len(s.connections[jarID])))
-	s.mu.Lock()
-	defer s.mu.Unlock()
+// ClearJar deletes every request in a jar, keeping the jar and its settings.
+func (s *JarService) ClearJar(jarID string) error {
+	_, err := s.jarStore.Get(jarID)
+	if err != nil {
+		return err
+	}
+
+	cleared, err := s.requestStore.ClearRequests(jarID)
+	if err != nil {
+		return err
+	}
 
-	if conns, exists := s.connections[jarID]; exists {
-		for c := range conns {
-			close(c)
-		}
-		return
+	// Only the cleared requests' bodies: requests captured since then keep
+	// theirs
+	for _, reqID := range cleared {
+		s.deleteBody(jarID, reqID)
 	}
 
-	slog.Warn("no connections found, no action taken", slog.String("jarID", jarID))
+	s.notifyClients(jarID, newEvent(EventJarCleared, jarID))
+	return nil
+}
+
+// Stop ends the service's background goroutines and waits for them to finish.
+// It is safe to call more than once.
+func (s *JarService) Stop() {
+	s.stopOnce.Do(func() {
+		s.stopMu.Lock()
+		defer s.stopMu.Unlock()
+
+		close(s.stop)
+		s.cancel()
+	})
+	s.background.Wait()
 }
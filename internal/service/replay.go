@@ -0,0 +1,190 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"time"
+
+	"github.com/bpietroniro/requestjar-go/internal/errors"
+	"github.com/bpietroniro/requestjar-go/internal/models"
+	"github.com/bpietroniro/requestjar-go/internal/util"
+)
+
+const (
+	defaultReplayTimeout = 30 * time.Second
+	// maxReplayResponseBytes is how much of an upstream response body a
+	// replay keeps
+	maxReplayResponseBytes = 1 << 20
+)
+
+// hopByHopHeaders describe the connection a request arrived on rather than the
+// request itself, so they aren't replayed. Content-Length and Host are set to
+// suit the new request instead.
+var hopByHopHeaders = []string{
+	"Connection", "Keep-Alive", "Proxy-Authenticate", "Proxy-Authorization", "Proxy-Connection",
+	"Te", "Trailer", "Transfer-Encoding", "Upgrade", "Content-Length", "Host",
+}
+
+// ReplayOptions say where to replay a captured request and what to change
+// about it.
+type ReplayOptions struct {
+	// Target is the absolute http or https URL to send the request to
+	Target string
+	// Method replaces the captured method when set
+	Method string
+	// Headers replace the captured values of the headers they name. An empty
+	// value removes the header.
+	Headers map[string]string
+	// Body replaces the captured body when it isn't nil
+	Body []byte
+}
+
+// SetReplayTimeout limits how long a replay waits for the upstream to answer.
+func (s *JarService) SetReplayTimeout(timeout time.Duration) {
+	s.replayClient = newReplayClient(timeout)
+}
+
+func newReplayClient(timeout time.Duration) *http.Client {
+	return &http.Client{
+		Timeout: timeout,
+		// Report redirects as they are rather than following them
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+func validateTarget(rawURL string) error {
+	target, err := url.Parse(rawURL)
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+		return errors.BadRequest("target must be an absolute http or https URL")
+	}
+
+	return nil
+}
+
+// ReplayRequest resends a captured request to opts.Target and records what
+// happened. Failing to reach the target isn't an error: the replay is stored
+// and returned with its Error set. If ctx is cancelled first, the replay is
+// abandoned and not stored.
+func (s *JarService) ReplayRequest(ctx context.Context, jarID string, reqID string, opts ReplayOptions) (*models.Replay, error) {
+	err := validateTarget(opts.Target)
+	if err != nil {
+		return nil, err
+	}
+
+	req, body, err := s.OpenRequestBody(jarID, reqID)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	payload := opts.Body
+	if payload == nil {
+		payload, err = io.ReadAll(body)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	replay := &models.Replay{
+		ID:        util.GenerateID(),
+		RequestID: reqID,
+		CreatedAt: time.Now(),
+		Target:    opts.Target,
+		Method:    req.Method,
+		Headers:   replayHeaders(req.Headers, opts.Headers),
+		Body:      opts.Body,
+	}
+	if opts.Method != "" {
+		replay.Method = opts.Method
+	}
+
+	outbound, err := http.NewRequestWithContext(ctx, replay.Method, replay.Target, bytes.NewReader(payload))
+	if err != nil {
+		return nil, errors.BadRequest(err.Error())
+	}
+	outbound.Header = replay.Headers.Clone()
+
+	send(s.replayClient, replay, outbound)
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	err = s.requestStore.CreateReplay(jarID, replay)
+	if err != nil {
+		return nil, err
+	}
+
+	slog.Info("request replayed", slog.String("jarID", jarID), slog.String("reqID", reqID), slog.String("target", replay.Target))
+	return replay, nil
+}
+
+// ListReplays returns the recorded replays of a captured request, oldest first.
+func (s *JarService) ListReplays(jarID string, reqID string) ([]*models.Replay, error) {
+	return s.requestStore.ListReplays(jarID, reqID)
+}
+
+// send makes the outbound request and records its outcome and timing on replay.
+func send(client *http.Client, replay *models.Replay, outbound *http.Request) {
+	start := time.Now()
+
+	trace := &httptrace.ClientTrace{
+		GotFirstResponseByte: func() {
+			replay.TimeToFirstByte = models.Duration(time.Since(start))
+		},
+	}
+	outbound = outbound.WithContext(httptrace.WithClientTrace(outbound.Context(), trace))
+
+	resp, err := client.Do(outbound)
+	if err != nil {
+		replay.Duration = models.Duration(time.Since(start))
+		replay.Error = err.Error()
+		return
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxReplayResponseBytes+1))
+	replay.Duration = models.Duration(time.Since(start))
+
+	replay.Response = &models.ReplayResponse{
+		StatusCode: resp.StatusCode,
+		Headers:    resp.Header,
+		Body:       data,
+	}
+	if len(data) > maxReplayResponseBytes {
+		replay.Response.Body = data[:maxReplayResponseBytes]
+		replay.Response.Truncated = true
+	}
+
+	if err != nil {
+		replay.Error = "reading response: " + err.Error()
+	}
+}
+
+// replayHeaders returns the captured headers to send, with overrides applied.
+func replayHeaders(captured http.Header, overrides map[string]string) http.Header {
+	headers := captured.Clone()
+	if headers == nil {
+		headers = http.Header{}
+	}
+
+	for _, name := range hopByHopHeaders {
+		headers.Del(name)
+	}
+
+	for name, value := range overrides {
+		if value == "" {
+			headers.Del(name)
+			continue
+		}
+		headers.Set(name, value)
+	}
+
+	return headers
+}
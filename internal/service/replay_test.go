@@ -0,0 +1,149 @@
+package service
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/bpietroniro/requestjar-go/internal/errors"
+	"github.com/bpietroniro/requestjar-go/internal/models"
+)
+
+func TestReplayRequest(t *testing.T) {
+	var got *http.Request
+	var gotBody []byte
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+		gotBody, _ = io.ReadAll(r.Body)
+		w.Header().Set("X-Upstream", "yes")
+		w.WriteHeader(http.StatusAccepted)
+		_, _ = w.Write([]byte("thanks"))
+	}))
+	defer upstream.Close()
+
+	s := newTestService(t)
+	jarID, _ := s.CreateJar(&models.Jar{Name: "jar"})
+
+	captured := &models.Request{
+		Method: "POST",
+		Headers: http.Header{
+			"Content-Type":   {"application/json"},
+			"X-Signature":    {"abc"},
+			"X-Remove":       {"1"},
+			"Content-Length": {"11"},
+		},
+		Body: []byte(`{"ping":1}`),
+	}
+	_, err := s.NewRequest(context.Background(), jarID, captured)
+	if err != nil {
+		t.Fatalf("NewRequest: %v", err)
+	}
+
+	replay, err := s.ReplayRequest(context.Background(), jarID, captured.ID, ReplayOptions{
+		Target:  upstream.URL + "/hooks?x=1",
+		Headers: map[string]string{"X-Signature": "def", "X-Remove": ""},
+	})
+	if err != nil {
+		t.Fatalf("ReplayRequest: %v", err)
+	}
+
+	if got.Method != "POST" || got.URL.String() != "/hooks?x=1" || string(gotBody) != `{"ping":1}` {
+		t.Fatalf("unexpected upstream request %s %s %q", got.Method, got.URL, gotBody)
+	}
+	if got.Header.Get("X-Signature") != "def" || got.Header.Get("X-Remove") != "" || got.Header.Get("Content-Type") != "application/json" {
+		t.Fatalf("expected header overrides to be applied, got %v", got.Header)
+	}
+
+	if replay.Error != "" || replay.Response == nil || replay.Response.StatusCode != 202 ||
+		string(replay.Response.Body) != "thanks" || replay.Response.Headers.Get("X-Upstream") != "yes" {
+		t.Fatalf("unexpected replay result %+v", replay)
+	}
+	if replay.Duration <= 0 {
+		t.Fatal("expected the replay to be timed")
+	}
+
+	replay, err = s.ReplayRequest(context.Background(), jarID, captured.ID, ReplayOptions{Target: upstream.URL, Method: "PUT", Body: []byte("new")})
+	if err != nil {
+		t.Fatalf("ReplayRequest: %v", err)
+	}
+	if got.Method != "PUT" || string(gotBody) != "new" || string(replay.Body) != "new" {
+		t.Fatalf("expected method and body overrides, got %s %q", got.Method, gotBody)
+	}
+
+	replays, err := s.ListReplays(jarID, captured.ID)
+	if err != nil || len(replays) != 2 {
+		t.Fatalf("expected both replays to be stored, got %+v, %v", replays, err)
+	}
+}
+
+func TestReplayRequestRecordsUnreachableTargets(t *testing.T) {
+	upstream := httptest.NewServer(http.NotFoundHandler())
+	upstream.Close()
+
+	s := newTestService(t)
+	jarID, _ := s.CreateJar(&models.Jar{Name: "jar"})
+	captured := &models.Request{Method: "GET"}
+	_, _ = s.NewRequest(context.Background(), jarID, captured)
+
+	replay, err := s.ReplayRequest(context.Background(), jarID, captured.ID, ReplayOptions{Target: upstream.URL})
+	if err != nil {
+		t.Fatalf("ReplayRequest: %v", err)
+	}
+	if replay.Error == "" || replay.Response != nil {
+		t.Fatalf("expected the failure to be recorded, got %+v", replay)
+	}
+}
+
+func TestReplayRequestIsCancelledWithItsContext(t *testing.T) {
+	release := make(chan struct{})
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer upstream.Close()
+	defer close(release)
+
+	s := newTestService(t)
+	jarID, _ := s.CreateJar(&models.Jar{Name: "jar"})
+	captured := &models.Request{Method: "GET"}
+	_, _ = s.NewRequest(context.Background(), jarID, captured)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	_, err := s.ReplayRequest(ctx, jarID, captured.ID, ReplayOptions{Target: upstream.URL})
+	if err != context.DeadlineExceeded {
+		t.Fatalf("expected the replay to be cancelled, got %v", err)
+	}
+
+	replays, _ := s.ListReplays(jarID, captured.ID)
+	if len(replays) != 0 {
+		t.Fatalf("expected the cancelled replay not to be stored, got %+v", replays)
+	}
+}
+
+func TestReplayRequestValidation(t *testing.T) {
+	s := newTestService(t)
+	jarID, _ := s.CreateJar(&models.Jar{Name: "jar"})
+	captured := &models.Request{Method: "GET"}
+	_, _ = s.NewRequest(context.Background(), jarID, captured)
+
+	for _, target := range []string{"", "localhost:3000", "ftp://example.com", "/relative"} {
+		_, err := s.ReplayRequest(context.Background(), jarID, captured.ID, ReplayOptions{Target: target})
+		if !errors.Is(err, errors.ErrBadRequest) {
+			t.Errorf("expected target %q to be rejected, got %v", target, err)
+		}
+	}
+
+	_, err := s.ReplayRequest(context.Background(), jarID, captured.ID, ReplayOptions{Target: "http://localhost", Method: "BAD METHOD"})
+	if !errors.Is(err, errors.ErrBadRequest) {
+		t.Errorf("expected an invalid method to be rejected, got %v", err)
+	}
+
+	_, err = s.ReplayRequest(context.Background(), jarID, "missing", ReplayOptions{Target: "http://localhost"})
+	if !errors.Is(err, errors.ErrNotFound) {
+		t.Errorf("expected a missing request to be not found, got %v", err)
+	}
+}
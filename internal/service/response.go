@@ -0,0 +1,50 @@
+package service
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/bpietroniro/requestjar-go/internal/errors"
+	"github.com/bpietroniro/requestjar-go/internal/models"
+	"github.com/bpietroniro/requestjar-go/internal/templating"
+)
+
+func validateMockResponse(response *models.MockResponse) error {
+	if response == nil {
+		return nil
+	}
+
+	if response.StatusCode != 0 && (response.StatusCode < 200 || response.StatusCode > 599) {
+		return errors.BadRequest("status code must be between 200 and 599")
+	}
+
+	if response.Body != "" && !response.AllowsBody() {
+		return errors.BadRequest(fmt.Sprintf("a %d response can't have a body", response.StatusCode))
+	}
+
+	for name := range response.Headers {
+		if http.CanonicalHeaderKey(name) == "Content-Length" {
+			return errors.BadRequest("content-length is set automatically")
+		}
+	}
+
+	err := templating.Validate(response)
+	if err != nil {
+		return errors.BadRequest(err.Error())
+	}
+
+	return nil
+}
+
+// SetMockResponse changes what the jar sends back to the callers it captures.
+// A nil response restores the default empty 200.
+func (s *JarService) SetMockResponse(jarID string, response *models.MockResponse) (*models.Jar, error) {
+	err := validateMockResponse(response)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.updateJar(jarID, func(jar *models.Jar) {
+		jar.Response = response
+	})
+}
@@ -0,0 +1,51 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/bpietroniro/requestjar-go/internal/models"
+)
+
+func TestNewRequestReturnsMockResponse(t *testing.T) {
+	s := newTestService(t)
+	jarID, _ := s.CreateJar(&models.Jar{Name: "jar"})
+
+	response, err := s.NewRequest(context.Background(), jarID, &models.Request{Method: "POST"})
+	if err != nil || response != nil {
+		t.Fatalf("expected no mock response by default, got %+v, %v", response, err)
+	}
+
+	want := &models.MockResponse{StatusCode: 503, Body: "try later"}
+	_, err = s.SetMockResponse(jarID, want)
+	if err != nil {
+		t.Fatalf("SetMockResponse: %v", err)
+	}
+
+	response, err = s.NewRequest(context.Background(), jarID, &models.Request{Method: "POST"})
+	if err != nil || response == nil || response.StatusCode != 503 || response.Body != "try later" {
+		t.Fatalf("expected the configured mock response, got %+v, %v", response, err)
+	}
+
+	_, err = s.SetMockResponse(jarID, &models.MockResponse{StatusCode: 42})
+	if err == nil {
+		t.Fatal("expected an invalid status code to be rejected")
+	}
+
+	for _, status := range []int{204, 304} {
+		_, err = s.SetMockResponse(jarID, &models.MockResponse{StatusCode: status, Body: "body"})
+		if err == nil {
+			t.Fatalf("expected a body with status %d to be rejected", status)
+		}
+
+		_, err = s.SetMockResponse(jarID, &models.MockResponse{StatusCode: status})
+		if err != nil {
+			t.Fatalf("expected an empty %d to be accepted, got %v", status, err)
+		}
+	}
+
+	_, err = s.SetRules(jarID, []models.ResponseRule{{Response: models.MockResponse{StatusCode: 204, Body: "body"}}})
+	if err == nil {
+		t.Fatal("expected a rule's 204 with a body to be rejected")
+	}
+}
@@ -0,0 +1,137 @@
+package service
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/bpietroniro/requestjar-go/internal/errors"
+	"github.com/bpietroniro/requestjar-go/internal/models"
+)
+
+func validateRetention(policy *models.RetentionPolicy) error {
+	if policy == nil {
+		return nil
+	}
+
+	if policy.MaxRequests < 0 || policy.MaxAge < 0 || policy.MaxBodyBytes < 0 {
+		return errors.BadRequest("retention limits must not be negative")
+	}
+
+	return nil
+}
+
+// StartRetentionReaper enforces every jar's retention policy once per interval
+// until Stop is called. Count and size limits are also enforced on every
+// capture; the reaper is what expires requests by age.
+func (s *JarService) StartRetentionReaper(interval time.Duration) {
+	s.background.Add(1)
+	go func() {
+		defer s.background.Done()
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				s.reapAll()
+			case <-s.stop:
+				return
+			}
+		}
+	}()
+}
+
+func (s *JarService) reapAll() {
+	jars, err := s.jarStore.List()
+	if err != nil {
+		slog.Error("retention reaper failed to list jars", slog.Any("error", err))
+		return
+	}
+
+	for _, jar := range jars {
+		if jar.Retention == nil {
+			continue
+		}
+
+		err = s.enforceRetention(jar)
+		if err != nil {
+			slog.Error("failed to enforce retention", slog.String("jarID", jar.ID), slog.Any("error", err))
+		}
+	}
+}
+
+// enforceRetention evicts the oldest requests in the jar until it is within its
+// retention policy, then tells the jar's connections which requests went.
+func (s *JarService) enforceRetention(jar *models.Jar) error {
+	policy := jar.Retention
+	if policy == nil {
+		return nil
+	}
+
+	s.retentionMu.Lock()
+	defer s.retentionMu.Unlock()
+
+	requests, err := s.requestStore.List(jar.ID)
+	if err != nil {
+		return err
+	}
+
+	evict := evictionCount(policy, requests, time.Now())
+	if evict == 0 {
+		return nil
+	}
+
+	evicted := make([]string, 0, evict)
+	for _, req := range requests[:evict] {
+		err = s.requestStore.DeleteOneRequest(jar.ID, req.ID)
+		// Deleted in the meantime, and announced then
+		if errors.Is(err, errors.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if req.BodyInfo != nil && req.BodyInfo.Offloaded {
+			s.deleteBody(jar.ID, req.ID)
+		}
+		evicted = append(evicted, req.ID)
+	}
+
+	slog.Debug("evicted requests", slog.String("jarID", jar.ID), slog.Int("count", len(evicted)))
+	event := newEvent(EventRetentionEvicted, jar.ID)
+	event.RequestIDs = evicted
+	s.notifyClients(jar.ID, event)
+
+	return nil
+}
+
+// evictionCount returns how many of the oldest requests (requests is in capture
+// order) have to go for the rest to satisfy policy.
+func evictionCount(policy *models.RetentionPolicy, requests []*models.Request, now time.Time) int {
+	evict := 0
+
+	if policy.MaxRequests > 0 && len(requests) > policy.MaxRequests {
+		evict = len(requests) - policy.MaxRequests
+	}
+
+	if policy.MaxAge > 0 {
+		cutoff := now.Add(-time.Duration(policy.MaxAge))
+		for evict < len(requests) && requests[evict].CreatedAt.Before(cutoff) {
+			evict++
+		}
+	}
+
+	if policy.MaxBodyBytes > 0 {
+		var total int64
+		for _, req := range requests[evict:] {
+			total += req.BodySize()
+		}
+		for evict < len(requests) && total > policy.MaxBodyBytes {
+			total -= requests[evict].BodySize()
+			evict++
+		}
+	}
+
+	return evict
+}
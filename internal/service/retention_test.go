@@ -0,0 +1,50 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bpietroniro/requestjar-go/internal/models"
+)
+
+func TestEvictionCount(t *testing.T) {
+	now := time.Now()
+	requests := []*models.Request{
+		{ID: "1", CreatedAt: now.Add(-3 * time.Hour), Body: make([]byte, 100)},
+		{ID: "2", CreatedAt: now.Add(-2 * time.Hour), Body: make([]byte, 100)},
+		{ID: "3", CreatedAt: now.Add(-time.Hour), Body: make([]byte, 100)},
+		{ID: "4", CreatedAt: now, Body: make([]byte, 100)},
+	}
+
+	tests := []struct {
+		name   string
+		policy models.RetentionPolicy
+		want   int
+	}{
+		{"unlimited", models.RetentionPolicy{}, 0},
+		{"under count", models.RetentionPolicy{MaxRequests: 10}, 0},
+		{"over count", models.RetentionPolicy{MaxRequests: 1}, 3},
+		{"max age", models.RetentionPolicy{MaxAge: models.Duration(90 * time.Minute)}, 2},
+		{"max body bytes", models.RetentionPolicy{MaxBodyBytes: 250}, 2},
+		{"body smaller than newest request", models.RetentionPolicy{MaxBodyBytes: 50}, 4},
+		{"strictest limit wins", models.RetentionPolicy{MaxRequests: 3, MaxAge: models.Duration(90 * time.Minute)}, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := evictionCount(&tt.policy, requests, now)
+			if got != tt.want {
+				t.Fatalf("expected %d evictions, got %d", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestStopWaitsAndIsIdempotent(t *testing.T) {
+	s := newTestService(t)
+	s.StartRetentionReaper(time.Millisecond)
+	s.StartExpirySweeper(time.Millisecond)
+
+	s.Stop()
+	s.Stop()
+}
@@ -0,0 +1,50 @@
+package service
+
+import (
+	"fmt"
+
+	"github.com/bpietroniro/requestjar-go/internal/errors"
+	"github.com/bpietroniro/requestjar-go/internal/matcher"
+	"github.com/bpietroniro/requestjar-go/internal/models"
+)
+
+func validateRules(rules []models.ResponseRule) error {
+	for i := range rules {
+		err := matcher.Validate(&rules[i].Match)
+		if err != nil {
+			return errors.BadRequest(fmt.Sprintf("rule %d: %v", i, err))
+		}
+
+		err = validateMockResponse(&rules[i].Response)
+		if err != nil {
+			return errors.BadRequest(fmt.Sprintf("rule %d: %v", i, err))
+		}
+	}
+
+	return nil
+}
+
+// SetRules replaces the jar's ordered response rules. An empty list leaves
+// only the jar's default response.
+func (s *JarService) SetRules(jarID string, rules []models.ResponseRule) (*models.Jar, error) {
+	err := validateRules(rules)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.updateJar(jarID, func(jar *models.Jar) {
+		jar.Rules = rules
+	})
+}
+
+// resolveResponse returns the response of the first rule that matches request,
+// falling back to the jar's default response.
+func resolveResponse(jar *models.Jar, request *models.Request) *models.MockResponse {
+	for i := range jar.Rules {
+		if matcher.Matches(&jar.Rules[i].Match, request) {
+			return &jar.Rules[i].Response
+		}
+	}
+
+	return jar.Response
+}
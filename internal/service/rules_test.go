@@ -0,0 +1,80 @@
+package service
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bpietroniro/requestjar-go/internal/models"
+	"github.com/bpietroniro/requestjar-go/internal/store"
+)
+
+func TestResolveResponse(t *testing.T) {
+	jar := &models.Jar{
+		Response: &models.MockResponse{StatusCode: 404},
+		Rules: []models.ResponseRule{
+			{Match: models.RequestMatcher{Method: "GET", Path: "users/*"}, Response: models.MockResponse{StatusCode: 200}},
+			{Match: models.RequestMatcher{Path: "users/*"}, Response: models.MockResponse{StatusCode: 405}},
+		},
+	}
+
+	tests := []struct {
+		method, path string
+		want         int
+	}{
+		{"GET", "users/1", 200},
+		{"DELETE", "users/1", 405},
+		{"GET", "orders/1", 404},
+	}
+
+	for _, tt := range tests {
+		got := resolveResponse(jar, &models.Request{Method: tt.method, Path: tt.path})
+		if got == nil || got.StatusCode != tt.want {
+			t.Fatalf("%s %s: expected status %d, got %+v", tt.method, tt.path, tt.want, got)
+		}
+	}
+
+	if got := resolveResponse(&models.Jar{}, &models.Request{}); got != nil {
+		t.Fatalf("expected no response for a jar without rules or a default, got %+v", got)
+	}
+}
+
+// slowJarStore takes a while to hand over the jars it reads, so that
+// concurrent updates overlap
+type slowJarStore struct {
+	store.JarStore
+}
+
+func (s slowJarStore) Get(jarID string) (*models.Jar, error) {
+	jar, err := s.JarStore.Get(jarID)
+	time.Sleep(time.Millisecond)
+	return jar, err
+}
+
+func TestConcurrentUpdatesKeepBothChanges(t *testing.T) {
+	s := NewJarService(slowJarStore{store.NewInMemoryJarStore()}, store.NewInMemoryRequestStore())
+	t.Cleanup(s.Stop)
+	rules := []models.ResponseRule{{Match: models.RequestMatcher{Method: "POST"}, Response: models.MockResponse{StatusCode: 201}}}
+	faults := &models.FaultConfig{ErrorRate: 0.5}
+
+	for i := 0; i < 10; i++ {
+		jarID, _ := s.CreateJar(&models.Jar{Name: "jar"})
+
+		var wg sync.WaitGroup
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			_, _ = s.SetRules(jarID, rules)
+		}()
+		go func() {
+			defer wg.Done()
+			_, _ = s.SetFaults(jarID, faults)
+		}()
+		wg.Wait()
+
+		jar, _ := s.GetJarMetadata(jarID)
+		if len(jar.Rules) != 1 || jar.Faults == nil {
+			t.Fatalf("expected both the rules and the faults to be kept, got rules %+v and faults %+v", jar.Rules, jar.Faults)
+		}
+	}
+}
@@ -0,0 +1,18 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/bpietroniro/requestjar-go/internal/store"
+)
+
+// newTestService returns a service backed by in-memory stores, stopped when
+// the test ends.
+func newTestService(t *testing.T) *JarService {
+	t.Helper()
+
+	s := NewJarService(store.NewInMemoryJarStore(), store.NewInMemoryRequestStore())
+	t.Cleanup(s.Stop)
+
+	return s
+}
@@ -0,0 +1,46 @@
+package service
+
+import (
+	"github.com/bpietroniro/requestjar-go/internal/snippet"
+)
+
+// SnippetRequest prepares a captured request for rendering as a snippet. It is
+// sent to the URL it was captured on, with host filling in for requests
+// captured without one, or to target with the captured path and query
+// appended.
+func (s *JarService) SnippetRequest(jarID string, reqID string, host string, target string) (*snippet.Request, error) {
+	if target != "" {
+		err := validateTarget(target)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	req, err := s.requestStore.Get(jarID, reqID)
+	if err != nil {
+		return nil, err
+	}
+
+	out := &snippet.Request{
+		Method:  req.Method,
+		URL:     req.URL(jarID, host),
+		Headers: replayHeaders(req.Headers, nil),
+	}
+	if target != "" {
+		out.URL = forwardURL(target, req)
+	}
+
+	if req.BodySize() > 0 {
+		out.BodyFile = reqID + ".body"
+
+		// Larger bodies are only ever referred to by file
+		if req.BodySize() <= snippet.MaxInlineBody {
+			out.Body, err = s.readBody(jarID, req)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return out, nil
+}
@@ -0,0 +1,75 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/bpietroniro/requestjar-go/internal/blob"
+	"github.com/bpietroniro/requestjar-go/internal/errors"
+	"github.com/bpietroniro/requestjar-go/internal/models"
+	"github.com/bpietroniro/requestjar-go/internal/snippet"
+)
+
+func TestSnippetRequest(t *testing.T) {
+	blobs, err := blob.NewLocalStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewLocalStore: %v", err)
+	}
+
+	s := newTestService(t)
+	s.SetBlobStore(blobs, 4)
+	jarID, _ := s.CreateJar(&models.Jar{Name: "jar"})
+
+	captured := &models.Request{
+		Method:   "POST",
+		Path:     "hooks",
+		RawQuery: "a=1",
+		Headers:  http.Header{"Content-Type": {"text/plain"}, "Content-Length": {"11"}, "Connection": {"close"}},
+		Body:     []byte("hello there"),
+	}
+	_, _ = s.NewRequest(context.Background(), jarID, captured)
+
+	req, err := s.SnippetRequest(jarID, captured.ID, "localhost:8080", "")
+	if err != nil {
+		t.Fatalf("SnippetRequest: %v", err)
+	}
+	if req.URL != "http://localhost:8080/r/"+jarID+"/hooks?a=1" {
+		t.Fatalf("expected the capture URL, got %s", req.URL)
+	}
+	if req.Headers.Get("Content-Length") != "" || req.Headers.Get("Connection") != "" || req.Headers.Get("Content-Type") != "text/plain" {
+		t.Fatalf("expected connection headers to be dropped, got %v", req.Headers)
+	}
+	if string(req.Body) != "hello there" || req.BodyFile != captured.ID+".body" {
+		t.Fatalf("expected the offloaded body to be loaded, got %q from %q", req.Body, req.BodyFile)
+	}
+
+	req, err = s.SnippetRequest(jarID, captured.ID, "localhost:8080", "http://localhost:3000/api")
+	if err != nil {
+		t.Fatalf("SnippetRequest: %v", err)
+	}
+	if req.URL != "http://localhost:3000/api/hooks?a=1" {
+		t.Fatalf("expected the request to be sent to the target, got %s", req.URL)
+	}
+
+	_, err = s.SnippetRequest(jarID, captured.ID, "localhost:8080", "localhost:3000")
+	if !errors.Is(err, errors.ErrBadRequest) {
+		t.Fatalf("expected an invalid target to be rejected, got %v", err)
+	}
+}
+
+func TestSnippetRequestLeavesLargeBodiesInFiles(t *testing.T) {
+	s := newTestService(t)
+	jarID, _ := s.CreateJar(&models.Jar{Name: "jar"})
+
+	captured := &models.Request{Method: "PUT", Body: make([]byte, snippet.MaxInlineBody+1)}
+	_, _ = s.NewRequest(context.Background(), jarID, captured)
+
+	req, err := s.SnippetRequest(jarID, captured.ID, "localhost", "")
+	if err != nil {
+		t.Fatalf("SnippetRequest: %v", err)
+	}
+	if req.Body != nil || req.BodyFile == "" {
+		t.Fatalf("expected only a file reference, got %d bytes from %q", len(req.Body), req.BodyFile)
+	}
+}
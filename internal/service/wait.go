@@ -0,0 +1,113 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/bpietroniro/requestjar-go/internal/errors"
+	"github.com/bpietroniro/requestjar-go/internal/matcher"
+	"github.com/bpietroniro/requestjar-go/internal/models"
+)
+
+// waitPageSize is how many stored requests are read at a time while looking
+// for one that is already there
+const waitPageSize = 100
+
+// WaitForRequest returns the first request captured after afterID (or the
+// first of all, when it is empty) that matches filter, waiting for one to
+// arrive if none has yet. It gives up with a RequestTimeout error when ctx's
+// deadline passes, and with a NotFound error if the jar is deleted.
+//
+// The subscription is made before the stored requests are read, so a request
+// captured in between is caught either way. If the subscription then misses
+// events, because they were dropped or it was disconnected as a slow consumer,
+// the store is read again from where it left off.
+func (s *JarService) WaitForRequest(ctx context.Context, jarID string, filter *matcher.Filter, afterID string) (*models.Request, error) {
+	conn, err := s.AddConnection(jarID, filter)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { s.RemoveConnection(conn) }()
+
+	for {
+		// Taken before the store is read: anything dropped after this was
+		// captured too late to be read
+		dropped := conn.Dropped()
+
+		req, err := s.firstStored(jarID, filter, &afterID)
+		if err != nil || req != nil {
+			return req, err
+		}
+
+		slog.Debug("waiting for a matching request", slog.String("jarID", jarID), slog.String("afterID", afterID))
+
+		conn, req, err = s.nextCaptured(ctx, conn, dropped)
+		if err != nil || req != nil {
+			return req, err
+		}
+	}
+}
+
+// firstStored returns the first stored request after *afterID that matches
+// filter, or nil if there isn't one, and moves *afterID past the requests it
+// read.
+func (s *JarService) firstStored(jarID string, filter *matcher.Filter, afterID *string) (*models.Request, error) {
+	for {
+		requests, err := s.ListRequests(jarID, *afterID, waitPageSize)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, req := range requests {
+			if filter.Matches(req) {
+				return req, nil
+			}
+			*afterID = req.ID
+		}
+
+		if len(requests) < waitPageSize {
+			return nil, nil
+		}
+	}
+}
+
+// nextCaptured waits for conn to be sent a matching request. It returns
+// without one when conn has missed events, having dropped more than dropped
+// of them or been disconnected, so that the caller can look for them in the
+// store; a disconnected connection is replaced by a new one.
+func (s *JarService) nextCaptured(ctx context.Context, conn *Connection, dropped int64) (*Connection, *models.Request, error) {
+	for {
+		select {
+		case event, ok := <-conn.Events:
+			if !ok {
+				if conn.CloseReason() == CloseJarDeleted {
+					return conn, nil, errors.NotFound("jar was deleted while waiting")
+				}
+
+				// Evicted as a slow consumer
+				resubscribed, err := s.AddConnection(conn.jarID, conn.Filter())
+				if err != nil {
+					return conn, nil, err
+				}
+				return resubscribed, nil, nil
+			}
+
+			// Only matching requests get through the connection's filter,
+			// which sees offloaded bodies in full
+			if event.Type == EventRequestCreated {
+				return conn, event.Request, nil
+			}
+
+			// Events are only dropped when the buffer is full, so a drop is
+			// always followed by an event that notices it
+			if conn.Dropped() > dropped {
+				return conn, nil, nil
+			}
+		case <-ctx.Done():
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				return conn, nil, errors.RequestTimeout("no matching request arrived in time")
+			}
+			return conn, nil, ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bpietroniro/requestjar-go/internal/errors"
+	"github.com/bpietroniro/requestjar-go/internal/matcher"
+	"github.com/bpietroniro/requestjar-go/internal/models"
+	"github.com/bpietroniro/requestjar-go/internal/store"
+)
+
+// waitForConnections waits until the service has n open connections.
+func waitForConnections(t *testing.T, s *JarService, n int) {
+	t.Helper()
+
+	deadline := time.Now().Add(time.Second)
+	for s.EventStats().Connections != n {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected %d connections, got %d", n, s.EventStats().Connections)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestWaitForRequest(t *testing.T) {
+	s := newTestService(t)
+	jarID, _ := s.CreateJar(&models.Jar{Name: "jar"})
+	posts, _ := matcher.NewFilter(&models.RequestFilter{Methods: []string{"POST"}})
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	// Requests already in the jar are found straight away
+	_, _ = s.NewRequest(context.Background(), jarID, &models.Request{Method: "GET"})
+	first := &models.Request{Method: "POST"}
+	_, _ = s.NewRequest(context.Background(), jarID, first)
+	second := &models.Request{Method: "POST"}
+	_, _ = s.NewRequest(context.Background(), jarID, second)
+
+	req, err := s.WaitForRequest(ctx, jarID, posts, "")
+	if err != nil || req.ID != first.ID {
+		t.Fatalf("expected the first stored POST, got %+v, %v", req, err)
+	}
+
+	// Later ones are waited for
+	go func() {
+		waitForConnections(t, s, 1)
+		_, _ = s.NewRequest(context.Background(), jarID, &models.Request{Method: "GET"})
+		_, _ = s.NewRequest(context.Background(), jarID, &models.Request{Method: "POST", Path: "later"})
+	}()
+
+	req, err = s.WaitForRequest(ctx, jarID, posts, second.ID)
+	if err != nil || req.Path != "later" {
+		t.Fatalf("expected the POST that arrived while waiting, got %+v, %v", req, err)
+	}
+
+	if s.EventStats().Connections != 0 {
+		t.Fatalf("expected the subscriptions to be removed, got %d", s.EventStats().Connections)
+	}
+}
+
+func TestWaitForRequestTimesOut(t *testing.T) {
+	s := newTestService(t)
+	jarID, _ := s.CreateJar(&models.Jar{Name: "jar"})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	_, err := s.WaitForRequest(ctx, jarID, nil, "")
+	if !errors.Is(err, errors.ErrRequestTimeout) {
+		t.Fatalf("expected a request timeout, got %v", err)
+	}
+}
+
+func TestWaitForRequestJarDeleted(t *testing.T) {
+	s := newTestService(t)
+	jarID, _ := s.CreateJar(&models.Jar{Name: "jar"})
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	go func() {
+		waitForConnections(t, s, 1)
+		_ = s.DeleteJar(jarID)
+	}()
+
+	_, err := s.WaitForRequest(ctx, jarID, nil, "")
+	if !errors.Is(err, errors.ErrNotFound) {
+		t.Fatalf("expected not found once the jar is deleted, got %v", err)
+	}
+}
+
+// pausingRequestStore holds on to the first page of requests it lists until
+// released, so that events can be missed in the meantime
+type pausingRequestStore struct {
+	store.RequestStore
+	once     sync.Once
+	listed   chan struct{}
+	released chan struct{}
+}
+
+func (s *pausingRequestStore) ListAfter(jarID string, afterID string, limit int) ([]*models.Request, error) {
+	requests, err := s.RequestStore.ListAfter(jarID, afterID, limit)
+	s.once.Do(func() {
+		close(s.listed)
+		<-s.released
+	})
+	return requests, err
+}
+
+func TestWaitForRequestFindsMissedRequests(t *testing.T) {
+	for _, policy := range []string{SlowConsumerDrop, SlowConsumerDisconnect} {
+		t.Run(policy, func(t *testing.T) {
+			requests := &pausingRequestStore{
+				RequestStore: store.NewInMemoryRequestStore(),
+				listed:       make(chan struct{}),
+				released:     make(chan struct{}),
+			}
+			s := NewJarService(store.NewInMemoryJarStore(), requests)
+			t.Cleanup(s.Stop)
+			_ = s.SetEventBuffer(1)
+			_ = s.SetSlowConsumerPolicy(policy)
+			jarID, _ := s.CreateJar(&models.Jar{Name: "jar"})
+			posts, _ := matcher.NewFilter(&models.RequestFilter{Methods: []string{"POST"}})
+
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+
+			// The update fills the waiting connection's buffer, so the POST
+			// captured after it is never sent
+			go func() {
+				<-requests.listed
+				_, _ = s.SetMockResponse(jarID, &models.MockResponse{StatusCode: 204})
+				_, _ = s.NewRequest(context.Background(), jarID, &models.Request{Method: "POST", Path: "missed"})
+				close(requests.released)
+			}()
+
+			req, err := s.WaitForRequest(ctx, jarID, posts, "")
+			if err != nil || req.Path != "missed" {
+				t.Fatalf("expected the missed POST to be found in the store, got %+v, %v", req, err)
+			}
+
+			if s.EventStats().Connections != 0 {
+				t.Fatalf("expected the subscriptions to be removed, got %d", s.EventStats().Connections)
+			}
+		})
+	}
+}
@@ -0,0 +1,255 @@
+// Package snippet renders captured requests as commands and code that send
+// them again: curl and HTTPie command lines and a Go net/http program.
+package snippet
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	FormatCurl   = "curl"
+	FormatHTTPie = "httpie"
+	FormatGo     = "go"
+)
+
+// Formats lists every format Render accepts
+var Formats = []string{FormatCurl, FormatHTTPie, FormatGo}
+
+// MaxInlineBody is the largest body written into a snippet. Larger bodies, and
+// binary ones, are read from BodyFile instead.
+const MaxInlineBody = 64 << 10
+
+// Request is what a snippet sends.
+type Request struct {
+	Method  string
+	URL     string
+	Headers http.Header
+	// Body is written into the snippet when it is text of at most
+	// MaxInlineBody bytes
+	Body []byte
+	// BodyFile is the file the snippet reads the body from otherwise. An empty
+	// BodyFile means there is no body.
+	BodyFile string
+	// BodyURL is where the body file can be downloaded, if known
+	BodyURL string
+}
+
+// Render returns the request as a snippet in the given format.
+func Render(format string, req *Request) (string, error) {
+	switch format {
+	case FormatCurl:
+		return Curl(req), nil
+	case FormatHTTPie:
+		return HTTPie(req), nil
+	case FormatGo:
+		return Go(req), nil
+	default:
+		return "", fmt.Errorf("unknown snippet format %q", format)
+	}
+}
+
+// inline reports whether a body can be written into a snippet as text.
+func inline(body []byte) bool {
+	return len(body) > 0 && len(body) <= MaxInlineBody && utf8.Valid(body) && !bytes.ContainsRune(body, 0)
+}
+
+func (r *Request) hasBody() bool {
+	return len(r.Body) > 0 || r.BodyFile != ""
+}
+
+func (r *Request) bodyFromFile() bool {
+	return r.BodyFile != "" && !inline(r.Body)
+}
+
+// bodyComment says where to get the body file, in the comment syntax given.
+func (r *Request) bodyComment(prefix string) string {
+	if !r.bodyFromFile() {
+		return ""
+	}
+
+	if r.BodyURL == "" {
+		return fmt.Sprintf("%s The request body is read from %s\n", prefix, r.BodyFile)
+	}
+
+	return fmt.Sprintf("%s Download the request body first:\n%s   curl -o %s %s\n", prefix, prefix, shellQuote(r.BodyFile), shellQuote(r.BodyURL))
+}
+
+// headerLines returns the headers as name/value pairs, sorted by name.
+func (r *Request) headerLines() [][2]string {
+	names := make([]string, 0, len(r.Headers))
+	for name := range r.Headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var lines [][2]string
+	for _, name := range names {
+		for _, value := range r.Headers[name] {
+			lines = append(lines, [2]string{name, value})
+		}
+	}
+
+	return lines
+}
+
+// Curl renders the request as a curl command.
+func Curl(req *Request) string {
+	command := "curl"
+
+	switch {
+	case req.Method == http.MethodHead:
+		command += " --head"
+	case req.Method == http.MethodGet && !req.hasBody(),
+		req.Method == http.MethodPost && req.hasBody():
+		// curl's default for the request
+	default:
+		command += " -X " + shellQuote(req.Method)
+	}
+
+	lines := []string{command + " " + shellQuote(req.URL)}
+
+	for _, header := range req.headerLines() {
+		// "Name;" is how curl sends a header with an empty value
+		if header[1] == "" {
+			lines = append(lines, "-H "+shellQuote(header[0]+";"))
+		} else {
+			lines = append(lines, "-H "+shellQuote(header[0]+": "+header[1]))
+		}
+	}
+
+	switch {
+	case req.bodyFromFile():
+		lines = append(lines, "--data-binary "+shellQuote("@"+req.BodyFile))
+	case req.hasBody():
+		lines = append(lines, "--data-raw "+shellQuote(string(req.Body)))
+	}
+
+	return req.bodyComment("#") + joinLines(lines)
+}
+
+// HTTPie renders the request as an HTTPie command.
+func HTTPie(req *Request) string {
+	command := "http"
+	if !req.bodyFromFile() {
+		// Otherwise HTTPie reads the body from stdin when it isn't a terminal,
+		// as in scripts
+		command += " --ignore-stdin"
+	}
+
+	lines := []string{command + " " + shellQuote(req.Method) + " " + shellQuote(req.URL)}
+
+	for _, header := range req.headerLines() {
+		// "Name;" is how HTTPie sends a header with an empty value
+		if header[1] == "" {
+			lines = append(lines, shellQuote(header[0]+";"))
+		} else {
+			lines = append(lines, shellQuote(header[0]+":"+header[1]))
+		}
+	}
+
+	switch {
+	case req.bodyFromFile():
+		lines = append(lines, "< "+shellQuote(req.BodyFile))
+	case req.hasBody():
+		lines = append(lines, "--raw "+shellQuote(string(req.Body)))
+	}
+
+	return req.bodyComment("#") + joinLines(lines)
+}
+
+// Go renders the request as a Go program that sends it and prints the
+// response.
+func Go(req *Request) string {
+	var b strings.Builder
+
+	imports := []string{"fmt", "io", "net/http", "os"}
+	if req.hasBody() && !req.bodyFromFile() {
+		imports = append(imports, "strings")
+	}
+	sort.Strings(imports)
+
+	b.WriteString(req.bodyComment("//"))
+	b.WriteString("package main\n\nimport (\n")
+	for _, imp := range imports {
+		fmt.Fprintf(&b, "\t%q\n", imp)
+	}
+	b.WriteString(")\n\nfunc main() {\n")
+
+	body := "nil"
+	switch {
+	case req.bodyFromFile():
+		fmt.Fprintf(&b, "body, err := os.Open(%s)\nif err != nil {\npanic(err)\n}\ndefer body.Close()\n\n", strconv.Quote(req.BodyFile))
+		body = "body"
+	case req.hasBody():
+		fmt.Fprintf(&b, "body := strings.NewReader(%s)\n\n", goString(string(req.Body)))
+		body = "body"
+	}
+
+	fmt.Fprintf(&b, "req, err := http.NewRequest(%s, %s, %s)\nif err != nil {\npanic(err)\n}\n", strconv.Quote(req.Method), strconv.Quote(req.URL), body)
+
+	for _, header := range req.headerLines() {
+		fmt.Fprintf(&b, "req.Header.Add(%s, %s)\n", strconv.Quote(header[0]), strconv.Quote(header[1]))
+	}
+
+	b.WriteString(`
+resp, err := http.DefaultClient.Do(req)
+if err != nil {
+panic(err)
+}
+defer resp.Body.Close()
+
+fmt.Println(resp.Status)
+_, _ = io.Copy(os.Stdout, resp.Body)
+}
+`)
+
+	source, err := format.Source([]byte(b.String()))
+	if err != nil {
+		// Unformatted code still runs
+		return b.String()
+	}
+
+	return string(source)
+}
+
+// joinLines joins the lines of a shell command with continuations.
+func joinLines(lines []string) string {
+	return strings.Join(lines, " \\\n  ") + "\n"
+}
+
+// goString quotes s as a Go string literal, using a raw string for text with
+// newlines or quotes, such as JSON, when it can so that the body stays
+// readable.
+func goString(s string) string {
+	if strings.ContainsAny(s, "\n\"") && !strings.ContainsAny(s, "`\r") && utf8.ValidString(s) {
+		return "`" + s + "`"
+	}
+	return strconv.Quote(s)
+}
+
+// shellQuote quotes s for a POSIX shell, leaving it alone if it is safe as
+// is.
+func shellQuote(s string) string {
+	if s != "" && strings.IndexFunc(s, unsafeInShell) == -1 {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+func unsafeInShell(r rune) bool {
+	switch {
+	case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		return false
+	case strings.ContainsRune("-_./:@%+=,", r):
+		return false
+	default:
+		return true
+	}
+}
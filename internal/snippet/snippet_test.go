@@ -0,0 +1,162 @@
+package snippet
+
+import (
+	"go/parser"
+	"go/token"
+	"net/http"
+	"os/exec"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// shellArgs runs a generated command with its program replaced by a shell
+// function that prints the arguments it was given, one per line of output.
+func shellArgs(t *testing.T, program string, command string) []string {
+	t.Helper()
+
+	sh, err := exec.LookPath("sh")
+	if err != nil {
+		t.Skip("no shell to run the command with")
+	}
+
+	script := program + "() { for arg in \"$@\"; do printf '%s\\000' \"$arg\"; done; }\n" + command
+	out, err := exec.Command(sh, "-c", script).Output()
+	if err != nil {
+		t.Fatalf("running %q: %v", command, err)
+	}
+
+	return strings.Split(strings.TrimSuffix(string(out), "\x00"), "\x00")
+}
+
+func TestCurl(t *testing.T) {
+	req := &Request{
+		Method:  "POST",
+		URL:     "http://localhost:8080/r/jar/hooks?a=1&b=it's",
+		Headers: http.Header{"Content-Type": {"application/json"}, "X-Empty": {""}},
+		Body:    []byte("{\"msg\": \"it's $HOME\"}\n"),
+	}
+
+	got := shellArgs(t, "curl", Curl(req))
+	want := []string{
+		"http://localhost:8080/r/jar/hooks?a=1&b=it's",
+		"-H", "Content-Type: application/json",
+		"-H", "X-Empty;",
+		"--data-raw", "{\"msg\": \"it's $HOME\"}\n",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected curl arguments\n got %q\nwant %q", got, want)
+	}
+}
+
+func TestCurlMethods(t *testing.T) {
+	cases := []struct {
+		req  *Request
+		want []string
+	}{
+		{&Request{Method: "GET", URL: "http://x"}, []string{"http://x"}},
+		{&Request{Method: "HEAD", URL: "http://x"}, []string{"--head", "http://x"}},
+		{&Request{Method: "DELETE", URL: "http://x"}, []string{"-X", "DELETE", "http://x"}},
+		{&Request{Method: "POST", URL: "http://x"}, []string{"-X", "POST", "http://x"}},
+		{&Request{Method: "PUT", URL: "http://x", Body: []byte("a")}, []string{"-X", "PUT", "http://x", "--data-raw", "a"}},
+	}
+
+	for _, c := range cases {
+		if got := shellArgs(t, "curl", Curl(c.req)); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%s: got %q, want %q", c.req.Method, got, c.want)
+		}
+	}
+}
+
+func TestBinaryBodiesComeFromFiles(t *testing.T) {
+	req := &Request{
+		Method:   "PUT",
+		URL:      "http://x/upload",
+		Body:     []byte{0x89, 'P', 'N', 'G', 0x00},
+		BodyFile: "01ABC.body",
+		BodyURL:  "http://localhost:8080/jars/jar/requests/01ABC/body",
+	}
+
+	curl := Curl(req)
+	if !strings.Contains(curl, "--data-binary @01ABC.body") || !strings.Contains(curl, "# Download the request body first") {
+		t.Fatalf("expected curl to read the body from a file, got\n%s", curl)
+	}
+
+	httpie := HTTPie(req)
+	if !strings.Contains(httpie, "< 01ABC.body") || strings.Contains(httpie, "--ignore-stdin") {
+		t.Fatalf("expected HTTPie to read the body from stdin, got\n%s", httpie)
+	}
+
+	program := Go(req)
+	if !strings.Contains(program, `os.Open("01ABC.body")`) {
+		t.Fatalf("expected the Go program to open the body file, got\n%s", program)
+	}
+
+	// Bodies too large to inline are also read from a file
+	large := &Request{Method: "POST", URL: "http://x", BodyFile: "big.body"}
+	if !strings.Contains(Curl(large), "--data-binary @big.body") {
+		t.Fatalf("expected a body with no inline copy to be read from a file, got\n%s", Curl(large))
+	}
+}
+
+func TestHTTPie(t *testing.T) {
+	req := &Request{
+		Method:  "PATCH",
+		URL:     "https://api.example.com/items/1",
+		Headers: http.Header{"Authorization": {"Bearer a b"}, "X-Empty": {""}},
+		Body:    []byte(`{"name":"o'brien"}`),
+	}
+
+	got := shellArgs(t, "http", HTTPie(req))
+	want := []string{
+		"--ignore-stdin", "PATCH", "https://api.example.com/items/1",
+		"Authorization:Bearer a b", "X-Empty;",
+		"--raw", `{"name":"o'brien"}`,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected HTTPie arguments\n got %q\nwant %q", got, want)
+	}
+}
+
+func TestGo(t *testing.T) {
+	requests := []*Request{
+		{Method: "GET", URL: "http://x"},
+		{
+			Method:  "POST",
+			URL:     "http://x/\"quoted\"",
+			Headers: http.Header{"X-Multi": {"a", "b"}},
+			Body:    []byte("line one\nline `two`\n"),
+		},
+		{Method: "POST", URL: "http://x", Body: []byte("multi\nline")},
+		{Method: "PUT", URL: "http://x", BodyFile: "body.bin"},
+	}
+
+	for _, req := range requests {
+		program := Go(req)
+		_, err := parser.ParseFile(token.NewFileSet(), "main.go", program, parser.AllErrors)
+		if err != nil {
+			t.Fatalf("expected a valid program, got %v\n%s", err, program)
+		}
+	}
+
+	program := Go(requests[1])
+	if !strings.Contains(program, `req.Header.Add("X-Multi", "a")`) || !strings.Contains(program, `req.Header.Add("X-Multi", "b")`) {
+		t.Fatalf("expected every header value to be added, got\n%s", program)
+	}
+	if !strings.Contains(Go(requests[2]), "strings.NewReader(`multi\nline`)") {
+		t.Fatalf("expected a multi-line body as a raw string, got\n%s", Go(requests[2]))
+	}
+}
+
+func TestRender(t *testing.T) {
+	req := &Request{Method: "GET", URL: "http://x"}
+	for _, format := range Formats {
+		if _, err := Render(format, req); err != nil {
+			t.Errorf("Render(%q): %v", format, err)
+		}
+	}
+
+	if _, err := Render("wget", req); err == nil {
+		t.Error("expected an unknown format to be rejected")
+	}
+}
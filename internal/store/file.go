@@ -0,0 +1,399 @@
+package store
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bpietroniro/requestjar-go/internal/models"
+)
+
+const logFileExt = ".jsonl"
+
+// Operations recorded in a jar's log file
+const (
+	opCreateJar         = "jar.create"
+	opUpdateJar         = "jar.update"
+	opDeleteJar         = "jar.delete"
+	opCreateJarKey      = "jar.createKey"
+	opDeleteAllRequests = "request.deleteAll"
+	opClearRequests     = "request.clear"
+	opCreateRequest     = "request.create"
+	opDeleteRequest     = "request.delete"
+	opCreateReplay      = "replay.create"
+)
+
+type logEntry struct {
+	Op        string          `json:"op"`
+	Jar       *models.Jar     `json:"jar,omitempty"`
+	Request   *models.Request `json:"request,omitempty"`
+	RequestID string          `json:"requestID,omitempty"`
+	Replay    *models.Replay  `json:"replay,omitempty"`
+}
+
+// FileLog is an append-only, per-jar JSONL log. Every mutation is appended to
+// <dir>/<jarID>.jsonl and then applied to in-memory stores; on open the logs
+// are replayed to rebuild that state. Compaction rewrites the logs of jars that
+// have seen deletions so that deleted data is actually removed from disk.
+type FileLog struct {
+	dir      string
+	jars     *jarStore
+	requests *requestStore
+	files    map[string]*os.File
+	dirty    map[string]struct{} // jars with deletions since the last compaction
+	mu       sync.Mutex
+	stop     chan struct{}
+}
+
+// OpenFileLog replays every log in dir (creating it if needed). The returned
+// log is shared by the file-backed jar and request stores.
+func OpenFileLog(dir string) (*FileLog, error) {
+	slog.Info("opening file log", slog.String("dir", dir))
+
+	err := os.MkdirAll(dir, 0o755)
+	if err != nil {
+		return nil, err
+	}
+
+	l := &FileLog{
+		dir:      dir,
+		jars:     &jarStore{jars: make(map[string]*models.Jar)},
+		requests: newRequestStore(),
+		files:    make(map[string]*os.File),
+		dirty:    make(map[string]struct{}),
+		stop:     make(chan struct{}),
+	}
+
+	paths, err := filepath.Glob(filepath.Join(dir, "*"+logFileExt))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, path := range paths {
+		jarID := strings.TrimSuffix(filepath.Base(path), logFileExt)
+
+		err = l.replay(jarID, path)
+		if err != nil {
+			return nil, fmt.Errorf("replaying %s: %w", path, err)
+		}
+	}
+
+	slog.Info("file log replayed", slog.Int("numJars", len(l.jars.jars)))
+	return l, nil
+}
+
+func (l *FileLog) replay(jarID string, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	// Entries hold whole bodies, so lines can be any length
+	r := bufio.NewReader(f)
+	var offset int64
+
+	for line := 1; ; line++ {
+		data, err := r.ReadBytes('\n')
+		if len(data) == 0 && err == io.EOF {
+			return nil
+		}
+		if err != nil && err != io.EOF {
+			return err
+		}
+
+		var entry logEntry
+
+		jsonErr := json.Unmarshal(data, &entry)
+		if jsonErr != nil {
+			// A torn final line is expected after a crash mid-write; anything
+			// else means the log is corrupt.
+			if _, peekErr := r.Peek(1); peekErr != io.EOF {
+				return fmt.Errorf("line %d: %w", line, jsonErr)
+			}
+
+			slog.Warn("ignoring truncated log entry", slog.String("jarID", jarID), slog.Int("line", line))
+			// So that the next entry starts on a line of its own
+			return os.Truncate(path, offset)
+		}
+
+		l.apply(jarID, &entry)
+		offset += int64(len(data))
+	}
+}
+
+// apply mutates the in-memory state without logging. Replay calls it directly
+// and the file stores call it once an entry is on disk, so it must not fail on
+// entries that were valid when they were written.
+func (l *FileLog) apply(jarID string, entry *logEntry) {
+	l.jars.mu.Lock()
+	defer l.jars.mu.Unlock()
+	l.requests.mu.Lock()
+	defer l.requests.mu.Unlock()
+
+	switch entry.Op {
+	case opCreateJar:
+		if entry.Jar != nil {
+			l.jars.jars[jarID] = entry.Jar
+		}
+	case opUpdateJar:
+		if _, exists := l.jars.jars[jarID]; exists && entry.Jar != nil {
+			l.jars.jars[jarID] = entry.Jar
+		}
+	case opDeleteJar:
+		delete(l.jars.jars, jarID)
+		l.dirty[jarID] = struct{}{}
+	case opCreateJarKey:
+		if _, exists := l.requests.requests[jarID]; !exists {
+			l.requests.requests[jarID] = make([]*models.Request, 0, 5)
+		}
+	case opDeleteAllRequests:
+		delete(l.requests.requests, jarID)
+		delete(l.requests.replays, jarID)
+		l.dirty[jarID] = struct{}{}
+	case opClearRequests:
+		if _, exists := l.requests.requests[jarID]; exists {
+			l.requests.requests[jarID] = make([]*models.Request, 0, 5)
+		}
+		delete(l.requests.replays, jarID)
+		l.dirty[jarID] = struct{}{}
+	case opCreateRequest:
+		if requests, exists := l.requests.requests[jarID]; exists && entry.Request != nil {
+			l.requests.requests[jarID] = insertSorted(requests, entry.Request)
+		}
+	case opDeleteRequest:
+		if requests, exists := l.requests.requests[jarID]; exists {
+			l.requests.deleteRequest(jarID, requests, entry.RequestID)
+		}
+		l.dirty[jarID] = struct{}{}
+	case opCreateReplay:
+		if entry.Replay == nil {
+			break
+		}
+		if _, err := l.requests.get(jarID, entry.Replay.RequestID); err == nil {
+			l.requests.insertReplay(jarID, entry.Replay)
+		}
+	default:
+		slog.Warn("unknown log operation", slog.String("jarID", jarID), slog.String("op", entry.Op))
+	}
+}
+
+// commit appends entry to the jar's log and, once it is on disk, applies it.
+// A change that fails to reach the log is never visible. Callers must hold
+// l.mu.
+func (l *FileLog) commit(jarID string, entry *logEntry) error {
+	err := l.append(jarID, entry)
+	if err != nil {
+		return err
+	}
+
+	l.apply(jarID, entry)
+	return nil
+}
+
+// append writes entry to the jar's log and waits for it to reach the disk, so
+// that nothing acknowledged is lost on a crash or power cut. Callers must hold
+// l.mu.
+func (l *FileLog) append(jarID string, entry *logEntry) error {
+	f, err := l.file(jarID)
+	if err != nil {
+		return err
+	}
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	_, err = f.Write(append(line, '\n'))
+	if err != nil {
+		return err
+	}
+
+	return f.Sync()
+}
+
+func (l *FileLog) file(jarID string) (*os.File, error) {
+	if f, open := l.files[jarID]; open {
+		return f, nil
+	}
+
+	f, err := os.OpenFile(l.path(jarID), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+
+	// The file itself may be new
+	err = syncDir(l.dir)
+	if err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+
+	l.files[jarID] = f
+	return f, nil
+}
+
+func (l *FileLog) path(jarID string) string {
+	return filepath.Join(l.dir, jarID+logFileExt)
+}
+
+// StartCompaction compacts the logs every interval until Close is called.
+func (l *FileLog) StartCompaction(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				err := l.Compact()
+				if err != nil {
+					slog.Error("file log compaction failed", slog.Any("error", err))
+				}
+			case <-l.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Compact rewrites the log of every jar that has seen a deletion since the last
+// compaction so that it only holds live data, and removes the logs of jars that
+// no longer exist at all.
+func (l *FileLog) Compact() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for jarID := range l.dirty {
+		err := l.compactJar(jarID)
+		if err != nil {
+			return fmt.Errorf("compacting jar %s: %w", jarID, err)
+		}
+		delete(l.dirty, jarID)
+	}
+
+	return nil
+}
+
+func (l *FileLog) compactJar(jarID string) error {
+	if f, open := l.files[jarID]; open {
+		_ = f.Close()
+		delete(l.files, jarID)
+	}
+
+	jar, jarExists := l.jars.jars[jarID]
+	requests, keyExists := l.requests.requests[jarID]
+
+	if !jarExists && !keyExists {
+		slog.Debug("removing log of deleted jar", slog.String("jarID", jarID))
+		err := os.Remove(l.path(jarID))
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	tmp, err := os.CreateTemp(l.dir, jarID+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+
+	w := bufio.NewWriter(tmp)
+	enc := json.NewEncoder(w)
+
+	if jarExists {
+		err = enc.Encode(&logEntry{Op: opCreateJar, Jar: jar})
+		if err != nil {
+			_ = tmp.Close()
+			return err
+		}
+	}
+
+	if keyExists {
+		err = enc.Encode(&logEntry{Op: opCreateJarKey})
+		if err != nil {
+			_ = tmp.Close()
+			return err
+		}
+
+		for _, req := range requests {
+			err = enc.Encode(&logEntry{Op: opCreateRequest, Request: req})
+			if err != nil {
+				_ = tmp.Close()
+				return err
+			}
+
+			for _, replay := range l.requests.replays[jarID][req.ID] {
+				err = enc.Encode(&logEntry{Op: opCreateReplay, Replay: replay})
+				if err != nil {
+					_ = tmp.Close()
+					return err
+				}
+			}
+		}
+	}
+
+	err = w.Flush()
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if err != nil {
+		_ = tmp.Close()
+		return err
+	}
+
+	err = tmp.Close()
+	if err != nil {
+		return err
+	}
+
+	err = os.Rename(tmp.Name(), l.path(jarID))
+	if err != nil {
+		return err
+	}
+
+	return syncDir(l.dir)
+}
+
+// syncDir makes the creation, removal or renaming of files in dir durable.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	return d.Sync()
+}
+
+// Close stops background compaction and closes all open log files.
+func (l *FileLog) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	select {
+	case <-l.stop:
+	default:
+		close(l.stop)
+	}
+
+	var firstErr error
+	for jarID, f := range l.files {
+		err := f.Close()
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+		delete(l.files, jarID)
+	}
+
+	return firstErr
+}
@@ -0,0 +1,71 @@
+package store
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/bpietroniro/requestjar-go/internal/models"
+	"github.com/bpietroniro/requestjar-go/internal/util"
+)
+
+type fileJarStore struct {
+	log *FileLog
+}
+
+func NewFileJarStore(l *FileLog) JarStore {
+	slog.Info("creating file jar storage dependency")
+	return &fileJarStore{log: l}
+}
+
+func (s *fileJarStore) Create(jar *models.Jar) (string, error) {
+	s.log.mu.Lock()
+	defer s.log.mu.Unlock()
+
+	jar.ID = util.GenerateID()
+	jar.CreatedAt = time.Now()
+
+	stored := *jar
+	err := s.log.commit(jar.ID, &logEntry{Op: opCreateJar, Jar: &stored})
+	if err != nil {
+		return "", err
+	}
+
+	return jar.ID, nil
+}
+
+func (s *fileJarStore) Get(id string) (*models.Jar, error) {
+	return s.log.jars.Get(id)
+}
+
+func (s *fileJarStore) List() ([]*models.Jar, error) {
+	return s.log.jars.List()
+}
+
+func (s *fileJarStore) Update(jar *models.Jar) error {
+	s.log.mu.Lock()
+	defer s.log.mu.Unlock()
+
+	existing, err := s.log.jars.Get(jar.ID)
+	if err != nil {
+		return err
+	}
+
+	updated := *jar
+	updated.CreatedAt = existing.CreatedAt
+
+	err = s.log.commit(jar.ID, &logEntry{Op: opUpdateJar, Jar: &updated})
+	if err != nil {
+		return err
+	}
+
+	// Updates make earlier jar entries dead weight, so compact them away too
+	s.log.dirty[jar.ID] = struct{}{}
+	return nil
+}
+
+func (s *fileJarStore) Delete(jarID string) error {
+	s.log.mu.Lock()
+	defer s.log.mu.Unlock()
+
+	return s.log.commit(jarID, &logEntry{Op: opDeleteJar})
+}
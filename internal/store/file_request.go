@@ -0,0 +1,118 @@
+package store
+
+import (
+	"fmt"
+	"log/slog"
+
+	"github.com/bpietroniro/requestjar-go/internal/errors"
+	"github.com/bpietroniro/requestjar-go/internal/models"
+)
+
+type fileRequestStore struct {
+	log *FileLog
+}
+
+func NewFileRequestStore(l *FileLog) RequestStore {
+	slog.Info("creating file request storage dependency")
+	return &fileRequestStore{log: l}
+}
+
+func (s *fileRequestStore) CreateRequest(jarID string, req *models.Request) error {
+	s.log.mu.Lock()
+	defer s.log.mu.Unlock()
+
+	if !s.log.requests.hasJar(jarID) {
+		return errors.NotFound("jar not found")
+	}
+
+	return s.log.commit(jarID, &logEntry{Op: opCreateRequest, Request: req})
+}
+
+func (s *fileRequestStore) CreateJarKey(jarID string) error {
+	s.log.mu.Lock()
+	defer s.log.mu.Unlock()
+
+	if s.log.requests.hasJar(jarID) {
+		slog.Warn(fmt.Sprintf("Jar %s already existed in request store", jarID))
+	}
+
+	return s.log.commit(jarID, &logEntry{Op: opCreateJarKey})
+}
+
+func (s *fileRequestStore) Get(jarID string, reqID string) (*models.Request, error) {
+	return s.log.requests.Get(jarID, reqID)
+}
+
+func (s *fileRequestStore) List(jarID string) ([]*models.Request, error) {
+	return s.log.requests.List(jarID)
+}
+
+func (s *fileRequestStore) ListAfter(jarID string, afterID string, limit int) ([]*models.Request, error) {
+	return s.log.requests.ListAfter(jarID, afterID, limit)
+}
+
+func (s *fileRequestStore) DeleteOneRequest(jarID string, reqID string) error {
+	s.log.mu.Lock()
+	defer s.log.mu.Unlock()
+
+	if !s.log.requests.hasJar(jarID) {
+		return errors.NotFound("jar not found")
+	}
+
+	_, err := s.log.requests.Get(jarID, reqID)
+	if err != nil {
+		return err
+	}
+
+	return s.log.commit(jarID, &logEntry{Op: opDeleteRequest, RequestID: reqID})
+}
+
+func (s *fileRequestStore) DeleteAllRrequests(jarID string) error {
+	s.log.mu.Lock()
+	defer s.log.mu.Unlock()
+
+	if !s.log.requests.hasJar(jarID) {
+		return errors.NotFound("no requests record found for jar")
+	}
+
+	return s.log.commit(jarID, &logEntry{Op: opDeleteAllRequests})
+}
+
+func (s *fileRequestStore) ClearRequests(jarID string) ([]string, error) {
+	s.log.mu.Lock()
+	defer s.log.mu.Unlock()
+
+	// Nothing else writes while the log is locked
+	requests, err := s.log.requests.List(jarID)
+	if err != nil {
+		return nil, errors.NotFound("no requests record found for jar")
+	}
+
+	err = s.log.commit(jarID, &logEntry{Op: opClearRequests})
+	if err != nil {
+		return nil, err
+	}
+
+	cleared := make([]string, 0, len(requests))
+	for _, req := range requests {
+		cleared = append(cleared, req.ID)
+	}
+
+	return cleared, nil
+}
+
+func (s *fileRequestStore) CreateReplay(jarID string, replay *models.Replay) error {
+	s.log.mu.Lock()
+	defer s.log.mu.Unlock()
+
+	_, err := s.log.requests.Get(jarID, replay.RequestID)
+	if err != nil {
+		return err
+	}
+
+	return s.log.commit(jarID, &logEntry{Op: opCreateReplay, Replay: replay})
+}
+
+func (s *fileRequestStore) ListReplays(jarID string, reqID string) ([]*models.Replay, error) {
+	return s.log.requests.ListReplays(jarID, reqID)
+}
@@ -0,0 +1,88 @@
+package store
+
+import (
+	"testing"
+
+	"github.com/bpietroniro/requestjar-go/internal/models"
+)
+
+// breakLog makes every later append to a jar's log fail.
+func breakLog(t *testing.T, l *FileLog, jarID string) {
+	t.Helper()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	f, err := l.file(jarID)
+	if err != nil {
+		t.Fatalf("opening log: %v", err)
+	}
+	_ = f.Close()
+}
+
+func TestFileStoresOnlyApplyLoggedChanges(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(jars JarStore, requests RequestStore, jarID string) error
+	}{
+		{"create request", func(jars JarStore, requests RequestStore, jarID string) error {
+			return requests.CreateRequest(jarID, &models.Request{ID: "r2"})
+		}},
+		{"create replay", func(jars JarStore, requests RequestStore, jarID string) error {
+			return requests.CreateReplay(jarID, &models.Replay{ID: "p1", RequestID: "r1"})
+		}},
+		{"delete request", func(jars JarStore, requests RequestStore, jarID string) error {
+			return requests.DeleteOneRequest(jarID, "r1")
+		}},
+		{"delete all requests", func(jars JarStore, requests RequestStore, jarID string) error {
+			return requests.DeleteAllRrequests(jarID)
+		}},
+		{"clear requests", func(jars JarStore, requests RequestStore, jarID string) error {
+			_, err := requests.ClearRequests(jarID)
+			return err
+		}},
+		{"update jar", func(jars JarStore, requests RequestStore, jarID string) error {
+			return jars.Update(&models.Jar{ID: jarID, Name: "renamed"})
+		}},
+		{"delete jar", func(jars JarStore, requests RequestStore, jarID string) error {
+			return jars.Delete(jarID)
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l, err := OpenFileLog(t.TempDir())
+			if err != nil {
+				t.Fatalf("OpenFileLog: %v", err)
+			}
+			t.Cleanup(func() { _ = l.Close() })
+
+			jars, requests := NewFileJarStore(l), NewFileRequestStore(l)
+			jarID, _ := jars.Create(&models.Jar{Name: "jar"})
+			_ = requests.CreateJarKey(jarID)
+			_ = requests.CreateRequest(jarID, &models.Request{ID: "r1"})
+
+			breakLog(t, l, jarID)
+
+			err = tt.change(jars, requests, jarID)
+			if err == nil {
+				t.Fatal("expected the change to fail when it can't be logged")
+			}
+
+			jar, err := jars.Get(jarID)
+			if err != nil || jar.Name != "jar" {
+				t.Fatalf("expected the jar to be unchanged, got %+v, %v", jar, err)
+			}
+
+			stored, err := requests.List(jarID)
+			if err != nil || len(stored) != 1 || stored[0].ID != "r1" {
+				t.Fatalf("expected only request r1, got %+v, %v", stored, err)
+			}
+
+			replays, err := requests.ListReplays(jarID, "r1")
+			if err != nil || len(replays) != 0 {
+				t.Fatalf("expected no replays, got %+v, %v", replays, err)
+			}
+		})
+	}
+}
@@ -2,6 +2,8 @@ package store
 
 import (
 	"log/slog"
+	"slices"
+	"strings"
 	"sync"
 	"time"
 
@@ -11,9 +13,10 @@ import (
 )
 
 type JarStore interface {
-	Create(name string) (string, error)
+	Create(jar *models.Jar) (string, error)
 	Get(id string) (*models.Jar, error)
 	List() ([]*models.Jar, error)
+	Update(jar *models.Jar) error
 	Delete(id string) error
 }
 
@@ -27,14 +30,19 @@ func NewInMemoryJarStore() JarStore {
 	return &jarStore{jars: make(map[string]*models.Jar)}
 }
 
-func (s *jarStore) Create(name string) (string, error) {
+// Create assigns the jar an ID and creation time, filling them in on jar, and
+// stores a copy of it.
+func (s *jarStore) Create(jar *models.Jar) (string, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	id := util.GenerateID()
-	s.jars[id] = &models.Jar{ID: id, Name: name, CreatedAt: time.Now()}
+	jar.ID = util.GenerateID()
+	jar.CreatedAt = time.Now()
 
-	return id, nil
+	stored := *jar
+	s.jars[jar.ID] = &stored
+
+	return jar.ID, nil
 }
 
 func (s *jarStore) Get(id string) (*models.Jar, error) {
@@ -59,9 +67,34 @@ func (s *jarStore) List() ([]*models.Jar, error) {
 		jars = append(jars, j)
 	}
 
+	slices.SortFunc(jars, func(a, b *models.Jar) int {
+		if c := a.CreatedAt.Compare(b.CreatedAt); c != 0 {
+			return c
+		}
+		return strings.Compare(a.ID, b.ID)
+	})
+
 	return jars, nil
 }
 
+// Update replaces the stored settings of the jar with jar.ID. The ID and
+// creation time can't be changed.
+func (s *jarStore) Update(jar *models.Jar) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	existing, exists := s.jars[jar.ID]
+	if !exists {
+		return errors.NotFound("jar not found")
+	}
+
+	stored := *jar
+	stored.CreatedAt = existing.CreatedAt
+	s.jars[jar.ID] = &stored
+
+	return nil
+}
+
 func (s *jarStore) Delete(jarID string) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
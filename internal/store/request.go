@@ -4,28 +4,55 @@ import (
 	"fmt"
 	"log/slog"
 	"slices"
+	"strings"
 	"sync"
 
 	"github.com/bpietroniro/requestjar-go/internal/errors"
 	"github.com/bpietroniro/requestjar-go/internal/models"
 )
 
+// RequestStore holds each jar's captured requests. Listing methods return them
+// ordered by ID, which is capture order for generated IDs.
 type RequestStore interface {
 	CreateRequest(jarID string, req *models.Request) error
 	CreateJarKey(jarID string) error
+	Get(jarID string, reqID string) (*models.Request, error)
 	List(jarID string) ([]*models.Request, error)
+	// ListAfter returns up to limit requests whose IDs sort after afterID.
+	// An empty afterID starts from the beginning and a limit <= 0 means no
+	// limit.
+	ListAfter(jarID string, afterID string, limit int) ([]*models.Request, error)
+	// DeleteOneRequest deletes a request and its replays. It returns a not
+	// found error if the jar has no such request.
 	DeleteOneRequest(jarID string, reqID string) error
 	DeleteAllRrequests(jarID string) error
+	// ClearRequests deletes every request in a jar, and their replays, in one
+	// step: captures never find the jar missing while it is being cleared. It
+	// returns the IDs of the requests it deleted.
+	ClearRequests(jarID string) ([]string, error)
+	// CreateReplay records a replay of one of the jar's requests. Replays are
+	// deleted along with their request.
+	CreateReplay(jarID string, replay *models.Replay) error
+	// ListReplays returns a request's replays ordered by ID
+	ListReplays(jarID string, reqID string) ([]*models.Replay, error)
 }
 
 type requestStore struct {
 	requests map[string][]*models.Request
+	replays  map[string]map[string][]*models.Replay // jar ID to request ID to replays
 	mu       sync.RWMutex
 }
 
 func NewInMemoryRequestStore() RequestStore {
 	slog.Info("creating request storage dependency")
-	return &requestStore{requests: make(map[string][]*models.Request)}
+	return newRequestStore()
+}
+
+func newRequestStore() *requestStore {
+	return &requestStore{
+		requests: make(map[string][]*models.Request),
+		replays:  make(map[string]map[string][]*models.Replay),
+	}
 }
 
 func (s *requestStore) CreateRequest(jarID string, req *models.Request) error {
@@ -37,12 +64,23 @@ func (s *requestStore) CreateRequest(jarID string, req *models.Request) error {
 	if !jarExists {
 		return errors.NotFound("jar not found")
 	} else {
-		s.requests[jarID] = append(requests, req)
+		s.requests[jarID] = insertSorted(requests, req)
 	}
 
 	return nil
 }
 
+// insertSorted adds req to requests, keeping them ordered by ID. Requests
+// almost always arrive in order, so this is usually an append.
+func insertSorted(requests []*models.Request, req *models.Request) []*models.Request {
+	i := len(requests)
+	for i > 0 && requests[i-1].ID > req.ID {
+		i--
+	}
+
+	return slices.Insert(requests, i, req)
+}
+
 func (s *requestStore) CreateJarKey(jarID string) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -58,6 +96,40 @@ func (s *requestStore) CreateJarKey(jarID string) error {
 	return nil
 }
 
+// hasJar reports whether the jar has a requests record.
+func (s *requestStore) hasJar(jarID string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	_, exists := s.requests[jarID]
+	return exists
+}
+
+func (s *requestStore) Get(jarID string, reqID string) (*models.Request, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.get(jarID, reqID)
+}
+
+// get finds a request. Callers must hold s.mu.
+func (s *requestStore) get(jarID string, reqID string) (*models.Request, error) {
+	requests, jarExists := s.requests[jarID]
+
+	if !jarExists {
+		return nil, errors.NotFound("jar not found")
+	}
+
+	i, found := slices.BinarySearchFunc(requests, reqID, func(r *models.Request, id string) int {
+		return strings.Compare(r.ID, id)
+	})
+	if !found {
+		return nil, errors.NotFound("request not found")
+	}
+
+	return requests[i], nil
+}
+
 func (s *requestStore) List(jarID string) ([]*models.Request, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
@@ -68,7 +140,36 @@ func (s *requestStore) List(jarID string) ([]*models.Request, error) {
 		return nil, errors.NotFound("jar not found")
 	}
 
-	return requests, nil
+	// Return a copy so callers never observe later appends or deletions
+	return slices.Clone(requests), nil
+}
+
+func (s *requestStore) ListAfter(jarID string, afterID string, limit int) ([]*models.Request, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	requests, jarExists := s.requests[jarID]
+
+	if !jarExists {
+		return nil, errors.NotFound("jar not found")
+	}
+
+	start := 0
+	if afterID != "" {
+		start, _ = slices.BinarySearchFunc(requests, afterID, func(r *models.Request, id string) int {
+			if r.ID <= id {
+				return -1
+			}
+			return 1
+		})
+	}
+
+	page := requests[start:]
+	if limit > 0 && len(page) > limit {
+		page = page[:limit]
+	}
+
+	return slices.Clone(page), nil
 }
 
 func (s *requestStore) DeleteOneRequest(jarID string, reqID string) error {
@@ -81,14 +182,23 @@ func (s *requestStore) DeleteOneRequest(jarID string, reqID string) error {
 		return errors.NotFound("jar not found")
 	}
 
-	filteredRequests := slices.DeleteFunc(requests, func(r *models.Request) bool {
-		return r.ID == reqID
-	})
+	if !slices.ContainsFunc(requests, func(r *models.Request) bool { return r.ID == reqID }) {
+		return errors.NotFound("request not found")
+	}
 
-	s.requests[jarID] = filteredRequests
+	s.deleteRequest(jarID, requests, reqID)
 	return nil
 }
 
+// deleteRequest removes a request, and its replays, from the jar's requests.
+// Callers must hold s.mu.
+func (s *requestStore) deleteRequest(jarID string, requests []*models.Request, reqID string) {
+	s.requests[jarID] = slices.DeleteFunc(requests, func(r *models.Request) bool {
+		return r.ID == reqID
+	})
+	delete(s.replays[jarID], reqID)
+}
+
 func (s *requestStore) DeleteAllRrequests(jarID string) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -100,5 +210,71 @@ func (s *requestStore) DeleteAllRrequests(jarID string) error {
 	}
 
 	delete(s.requests, jarID)
+	delete(s.replays, jarID)
+	return nil
+}
+
+func (s *requestStore) ClearRequests(jarID string) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	requests, jarExists := s.requests[jarID]
+
+	if !jarExists {
+		return nil, errors.NotFound("no requests record found for jar")
+	}
+
+	cleared := make([]string, 0, len(requests))
+	for _, req := range requests {
+		cleared = append(cleared, req.ID)
+	}
+
+	s.requests[jarID] = make([]*models.Request, 0, 5)
+	delete(s.replays, jarID)
+	return cleared, nil
+}
+
+func (s *requestStore) CreateReplay(jarID string, replay *models.Replay) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, err := s.get(jarID, replay.RequestID)
+	if err != nil {
+		return err
+	}
+
+	s.insertReplay(jarID, replay)
 	return nil
 }
+
+// insertReplay adds a replay of an existing request, keeping the request's
+// replays ordered by ID. Callers must hold s.mu.
+func (s *requestStore) insertReplay(jarID string, replay *models.Replay) {
+	if s.replays[jarID] == nil {
+		s.replays[jarID] = make(map[string][]*models.Replay)
+	}
+
+	replays := s.replays[jarID][replay.RequestID]
+	i := len(replays)
+	for i > 0 && replays[i-1].ID > replay.ID {
+		i--
+	}
+	s.replays[jarID][replay.RequestID] = slices.Insert(replays, i, replay)
+}
+
+func (s *requestStore) ListReplays(jarID string, reqID string) ([]*models.Replay, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	_, err := s.get(jarID, reqID)
+	if err != nil {
+		return nil, err
+	}
+
+	replays := slices.Clone(s.replays[jarID][reqID])
+	if replays == nil {
+		replays = []*models.Replay{}
+	}
+
+	return replays, nil
+}
@@ -0,0 +1,159 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"path/filepath"
+
+	_ "modernc.org/sqlite"
+)
+
+// migrations are applied in order and tracked with SQLite's user_version
+// pragma, so a migration must never be edited once it has shipped. Append a
+// new entry instead.
+var migrations = []string{
+	`CREATE TABLE jars (
+		id         TEXT PRIMARY KEY,
+		name       TEXT NOT NULL,
+		created_at INTEGER NOT NULL
+	);
+	CREATE INDEX idx_jars_created_at ON jars (created_at);
+
+	CREATE TABLE request_jars (
+		jar_id TEXT PRIMARY KEY
+	);
+
+	CREATE TABLE requests (
+		seq        INTEGER PRIMARY KEY AUTOINCREMENT,
+		id         TEXT NOT NULL,
+		jar_id     TEXT NOT NULL REFERENCES request_jars (jar_id) ON DELETE CASCADE,
+		created_at INTEGER NOT NULL,
+		method     TEXT NOT NULL,
+		path       TEXT NOT NULL,
+		headers    TEXT NOT NULL,
+		query      TEXT NOT NULL,
+		client_ip  TEXT NOT NULL,
+		body       BLOB
+	);
+	CREATE INDEX idx_requests_jar_id ON requests (jar_id, id);
+	CREATE INDEX idx_requests_created_at ON requests (jar_id, created_at);`,
+
+	`ALTER TABLE jars ADD COLUMN retention TEXT;`,
+
+	`ALTER TABLE jars ADD COLUMN expires_at INTEGER;
+	CREATE INDEX idx_jars_expires_at ON jars (expires_at);`,
+
+	`ALTER TABLE jars ADD COLUMN response TEXT;`,
+
+	`ALTER TABLE jars ADD COLUMN rules TEXT;`,
+
+	`ALTER TABLE jars ADD COLUMN faults TEXT;
+	ALTER TABLE requests ADD COLUMN fault TEXT;`,
+
+	// Headers and query parameters used to keep only their first value. They
+	// were stored as null when there were none.
+	`UPDATE requests SET
+		headers = CASE WHEN json_type(headers) = 'object'
+			THEN (SELECT json_group_object(key, json_array(value)) FROM json_each(requests.headers))
+			ELSE '{}' END,
+		query = CASE WHEN json_type(query) = 'object'
+			THEN (SELECT json_group_object(key, json_array(value)) FROM json_each(requests.query))
+			ELSE '{}' END;
+	ALTER TABLE requests ADD COLUMN raw_query TEXT NOT NULL DEFAULT '';`,
+
+	`ALTER TABLE requests ADD COLUMN proto TEXT NOT NULL DEFAULT '';
+	ALTER TABLE requests ADD COLUMN host TEXT NOT NULL DEFAULT '';
+	ALTER TABLE requests ADD COLUMN request_uri TEXT NOT NULL DEFAULT '';
+	ALTER TABLE requests ADD COLUMN content_length INTEGER NOT NULL DEFAULT -1;
+	ALTER TABLE requests ADD COLUMN transfer_encoding TEXT;
+	ALTER TABLE requests ADD COLUMN trailers TEXT;
+	ALTER TABLE requests ADD COLUMN tls TEXT;`,
+
+	`ALTER TABLE jars ADD COLUMN max_body_size INTEGER NOT NULL DEFAULT 0;
+	ALTER TABLE requests ADD COLUMN body_info TEXT;`,
+
+	`ALTER TABLE requests ADD COLUMN decoded TEXT;`,
+
+	`CREATE TABLE replays (
+		seq        INTEGER PRIMARY KEY AUTOINCREMENT,
+		id         TEXT NOT NULL,
+		jar_id     TEXT NOT NULL REFERENCES request_jars (jar_id) ON DELETE CASCADE,
+		request_id TEXT NOT NULL,
+		replay     TEXT NOT NULL
+	);
+	CREATE INDEX idx_replays_request_id ON replays (jar_id, request_id, id);`,
+
+	`ALTER TABLE jars ADD COLUMN forward TEXT;`,
+
+	`ALTER TABLE requests ADD COLUMN response TEXT;`,
+}
+
+// OpenSQLite opens (or creates) the database at path and brings its schema up
+// to date. The returned handle is shared by the SQLite jar and request stores.
+func OpenSQLite(path string) (*sql.DB, error) {
+	slog.Info("opening sqlite database", slog.String("path", path))
+
+	// SQLite reads the DSN as a URI, so characters such as ? and # in the
+	// path have to be escaped
+	escaped := &url.URL{Path: filepath.ToSlash(path)}
+	dsn := url.URL{
+		Scheme:   "file",
+		Opaque:   escaped.EscapedPath(),
+		RawQuery: "_pragma=foreign_keys(1)&_pragma=journal_mode(WAL)&_pragma=busy_timeout(5000)",
+	}
+	db, err := sql.Open("sqlite", dsn.String())
+	if err != nil {
+		return nil, err
+	}
+
+	// SQLite only allows one writer at a time; a single connection avoids
+	// SQLITE_BUSY errors under concurrent captures.
+	db.SetMaxOpenConns(1)
+
+	err = migrate(db)
+	if err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+
+	return db, nil
+}
+
+func migrate(db *sql.DB) error {
+	var version int
+	err := db.QueryRow("PRAGMA user_version").Scan(&version)
+	if err != nil {
+		return fmt.Errorf("reading schema version: %w", err)
+	}
+
+	for i := version; i < len(migrations); i++ {
+		slog.Info("applying sqlite migration", slog.Int("version", i+1))
+
+		tx, err := db.Begin()
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(migrations[i])
+		if err != nil {
+			_ = tx.Rollback()
+			return fmt.Errorf("applying migration %d: %w", i+1, err)
+		}
+
+		// PRAGMA statements can't take bound parameters
+		_, err = tx.Exec(fmt.Sprintf("PRAGMA user_version = %d", i+1))
+		if err != nil {
+			_ = tx.Rollback()
+			return fmt.Errorf("recording migration %d: %w", i+1, err)
+		}
+
+		err = tx.Commit()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,232 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/bpietroniro/requestjar-go/internal/errors"
+	"github.com/bpietroniro/requestjar-go/internal/models"
+	"github.com/bpietroniro/requestjar-go/internal/util"
+)
+
+// jarSettingColumns are the columns that Update may change, in the order
+// returned by jarSettingValues
+var jarSettingColumns = []string{"name", "retention", "expires_at", "response", "rules", "faults", "max_body_size", "forward"}
+
+// jarColumns lists every column read by scanJar, in order
+var jarColumns = "id, created_at, " + strings.Join(jarSettingColumns, ", ")
+
+type sqliteJarStore struct {
+	db *sql.DB
+}
+
+func NewSQLiteJarStore(db *sql.DB) JarStore {
+	slog.Info("creating sqlite jar storage dependency")
+	return &sqliteJarStore{db: db}
+}
+
+func (s *sqliteJarStore) Create(jar *models.Jar) (string, error) {
+	id := util.GenerateID()
+	createdAt := time.Now()
+
+	settings, err := jarSettingValues(jar)
+	if err != nil {
+		return "", err
+	}
+
+	_, err = s.db.Exec(
+		"INSERT INTO jars ("+jarColumns+") VALUES (?, ?"+strings.Repeat(", ?", len(settings))+")",
+		append([]any{id, createdAt.UnixNano()}, settings...)...,
+	)
+	if err != nil {
+		return "", err
+	}
+
+	jar.ID = id
+	jar.CreatedAt = createdAt
+	return id, nil
+}
+
+func (s *sqliteJarStore) Get(id string) (*models.Jar, error) {
+	row := s.db.QueryRow("SELECT "+jarColumns+" FROM jars WHERE id = ?", id)
+
+	jar, err := scanJar(row)
+	if err == sql.ErrNoRows {
+		return nil, errors.NotFound("jar not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return jar, nil
+}
+
+func (s *sqliteJarStore) List() ([]*models.Jar, error) {
+	rows, err := s.db.Query("SELECT " + jarColumns + " FROM jars ORDER BY created_at, id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	jars := []*models.Jar{}
+
+	for rows.Next() {
+		jar, err := scanJar(rows)
+		if err != nil {
+			return nil, err
+		}
+		jars = append(jars, jar)
+	}
+
+	return jars, rows.Err()
+}
+
+func (s *sqliteJarStore) Update(jar *models.Jar) error {
+	settings, err := jarSettingValues(jar)
+	if err != nil {
+		return err
+	}
+
+	res, err := s.db.Exec(
+		"UPDATE jars SET "+strings.Join(jarSettingColumns, " = ?, ")+" = ? WHERE id = ?",
+		append(settings, jar.ID)...,
+	)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return errors.NotFound("jar not found")
+	}
+
+	return nil
+}
+
+func (s *sqliteJarStore) Delete(jarID string) error {
+	_, err := s.db.Exec("DELETE FROM jars WHERE id = ?", jarID)
+	return err
+}
+
+func jarSettingValues(jar *models.Jar) ([]any, error) {
+	retention, err := marshalNullable(jar.Retention)
+	if err != nil {
+		return nil, err
+	}
+
+	var expiresAt sql.NullInt64
+	if jar.ExpiresAt != nil {
+		expiresAt = sql.NullInt64{Int64: jar.ExpiresAt.UnixNano(), Valid: true}
+	}
+
+	response, err := marshalNullable(jar.Response)
+	if err != nil {
+		return nil, err
+	}
+
+	var rules sql.NullString
+	if len(jar.Rules) > 0 {
+		data, err := json.Marshal(jar.Rules)
+		if err != nil {
+			return nil, err
+		}
+		rules = sql.NullString{String: string(data), Valid: true}
+	}
+
+	faults, err := marshalNullable(jar.Faults)
+	if err != nil {
+		return nil, err
+	}
+
+	forward, err := marshalNullable(jar.Forward)
+	if err != nil {
+		return nil, err
+	}
+
+	return []any{jar.Name, retention, expiresAt, response, rules, faults, jar.MaxBodySize, forward}, nil
+}
+
+// scanner is satisfied by both *sql.Row and *sql.Rows
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanJar(row scanner) (*models.Jar, error) {
+	var jar models.Jar
+	var createdAt int64
+	var retention sql.NullString
+	var expiresAt sql.NullInt64
+	var response, rules, faults, forward sql.NullString
+
+	err := row.Scan(&jar.ID, &createdAt, &jar.Name, &retention, &expiresAt, &response, &rules, &faults, &jar.MaxBodySize, &forward)
+	if err != nil {
+		return nil, err
+	}
+
+	jar.CreatedAt = time.Unix(0, createdAt)
+
+	err = unmarshalNullable(retention, &jar.Retention)
+	if err != nil {
+		return nil, err
+	}
+
+	if expiresAt.Valid {
+		t := time.Unix(0, expiresAt.Int64)
+		jar.ExpiresAt = &t
+	}
+
+	err = unmarshalNullable(response, &jar.Response)
+	if err != nil {
+		return nil, err
+	}
+
+	if rules.Valid {
+		err = json.Unmarshal([]byte(rules.String), &jar.Rules)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = unmarshalNullable(faults, &jar.Faults)
+	if err != nil {
+		return nil, err
+	}
+
+	err = unmarshalNullable(forward, &jar.Forward)
+	if err != nil {
+		return nil, err
+	}
+
+	return &jar, nil
+}
+
+// marshalNullable encodes v as JSON, or as NULL when v is a nil pointer.
+func marshalNullable[T any](v *T) (sql.NullString, error) {
+	if v == nil {
+		return sql.NullString{}, nil
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return sql.NullString{}, err
+	}
+
+	return sql.NullString{String: string(data), Valid: true}, nil
+}
+
+// unmarshalNullable is the inverse of marshalNullable.
+func unmarshalNullable[T any](s sql.NullString, v **T) error {
+	if !s.Valid {
+		*v = nil
+		return nil
+	}
+
+	*v = new(T)
+	return json.Unmarshal([]byte(s.String), *v)
+}
@@ -0,0 +1,472 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/bpietroniro/requestjar-go/internal/errors"
+	"github.com/bpietroniro/requestjar-go/internal/models"
+)
+
+// requestColumns lists every column written by requestValues and read by
+// scanRequest, in order
+var requestColumns = []string{
+	"id", "created_at", "method", "path", "headers", "query", "client_ip", "body", "fault", "raw_query",
+	"proto", "host", "request_uri", "content_length", "transfer_encoding", "trailers", "tls", "body_info", "decoded", "response",
+}
+
+type sqliteRequestStore struct {
+	db *sql.DB
+}
+
+func NewSQLiteRequestStore(db *sql.DB) RequestStore {
+	slog.Info("creating sqlite request storage dependency")
+	return &sqliteRequestStore{db: db}
+}
+
+func (s *sqliteRequestStore) CreateRequest(jarID string, req *models.Request) error {
+	values, err := requestValues(req)
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	exists, err := jarKeyExists(tx, jarID)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return errors.NotFound("jar not found")
+	}
+
+	_, err = tx.Exec(
+		"INSERT INTO requests (jar_id, "+strings.Join(requestColumns, ", ")+") VALUES (?"+strings.Repeat(", ?", len(values))+")",
+		append([]any{jarID}, values...)...,
+	)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *sqliteRequestStore) CreateJarKey(jarID string) error {
+	res, err := s.db.Exec("INSERT OR IGNORE INTO request_jars (jar_id) VALUES (?)", jarID)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		slog.Warn("jar already existed in request store", slog.String("jarID", jarID))
+	}
+
+	return nil
+}
+
+func (s *sqliteRequestStore) Get(jarID string, reqID string) (*models.Request, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	exists, err := jarKeyExists(tx, jarID)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, errors.NotFound("jar not found")
+	}
+
+	row := tx.QueryRow(
+		"SELECT "+strings.Join(requestColumns, ", ")+" FROM requests WHERE jar_id = ? AND id = ? ORDER BY seq LIMIT 1",
+		jarID, reqID,
+	)
+
+	req, err := scanRequest(row)
+	if err == sql.ErrNoRows {
+		return nil, errors.NotFound("request not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return req, nil
+}
+
+func (s *sqliteRequestStore) List(jarID string) ([]*models.Request, error) {
+	return s.ListAfter(jarID, "", 0)
+}
+
+func (s *sqliteRequestStore) ListAfter(jarID string, afterID string, limit int) ([]*models.Request, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	exists, err := jarKeyExists(tx, jarID)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, errors.NotFound("jar not found")
+	}
+
+	// A negative LIMIT means no limit in SQLite
+	if limit <= 0 {
+		limit = -1
+	}
+
+	rows, err := tx.Query(
+		"SELECT "+strings.Join(requestColumns, ", ")+" FROM requests WHERE jar_id = ? AND (? = '' OR id > ?) ORDER BY id, seq LIMIT ?",
+		jarID, afterID, afterID, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	requests := []*models.Request{}
+
+	for rows.Next() {
+		req, err := scanRequest(rows)
+		if err != nil {
+			return nil, err
+		}
+		requests = append(requests, req)
+	}
+
+	return requests, rows.Err()
+}
+
+func (s *sqliteRequestStore) DeleteOneRequest(jarID string, reqID string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	exists, err := jarKeyExists(tx, jarID)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return errors.NotFound("jar not found")
+	}
+
+	res, err := tx.Exec("DELETE FROM requests WHERE jar_id = ? AND id = ?", jarID, reqID)
+	if err != nil {
+		return err
+	}
+
+	deleted, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if deleted == 0 {
+		return errors.NotFound("request not found")
+	}
+
+	_, err = tx.Exec("DELETE FROM replays WHERE jar_id = ? AND request_id = ?", jarID, reqID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *sqliteRequestStore) DeleteAllRrequests(jarID string) error {
+	// Requests are removed by the ON DELETE CASCADE on requests.jar_id
+	res, err := s.db.Exec("DELETE FROM request_jars WHERE jar_id = ?", jarID)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return errors.NotFound("no requests record found for jar")
+	}
+
+	return nil
+}
+
+func (s *sqliteRequestStore) ClearRequests(jarID string) ([]string, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	exists, err := jarKeyExists(tx, jarID)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, errors.NotFound("no requests record found for jar")
+	}
+
+	rows, err := tx.Query("SELECT id FROM requests WHERE jar_id = ?", jarID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	cleared := []string{}
+	for rows.Next() {
+		var id string
+		err = rows.Scan(&id)
+		if err != nil {
+			return nil, err
+		}
+		cleared = append(cleared, id)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.Exec("DELETE FROM requests WHERE jar_id = ?", jarID)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.Exec("DELETE FROM replays WHERE jar_id = ?", jarID)
+	if err != nil {
+		return nil, err
+	}
+
+	return cleared, tx.Commit()
+}
+
+func (s *sqliteRequestStore) CreateReplay(jarID string, replay *models.Replay) error {
+	data, err := json.Marshal(replay)
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	err = requireRequest(tx, jarID, replay.RequestID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(
+		"INSERT INTO replays (id, jar_id, request_id, replay) VALUES (?, ?, ?, ?)",
+		replay.ID, jarID, replay.RequestID, string(data),
+	)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *sqliteRequestStore) ListReplays(jarID string, reqID string) ([]*models.Replay, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	err = requireRequest(tx, jarID, reqID)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := tx.Query("SELECT replay FROM replays WHERE jar_id = ? AND request_id = ? ORDER BY id, seq", jarID, reqID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	replays := []*models.Replay{}
+
+	for rows.Next() {
+		var data string
+		err = rows.Scan(&data)
+		if err != nil {
+			return nil, err
+		}
+
+		var replay models.Replay
+		err = json.Unmarshal([]byte(data), &replay)
+		if err != nil {
+			return nil, err
+		}
+		replays = append(replays, &replay)
+	}
+
+	return replays, rows.Err()
+}
+
+// requireRequest returns a not-found error unless the jar has the request.
+func requireRequest(tx *sql.Tx, jarID string, reqID string) error {
+	exists, err := jarKeyExists(tx, jarID)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return errors.NotFound("jar not found")
+	}
+
+	err = tx.QueryRow("SELECT EXISTS (SELECT 1 FROM requests WHERE jar_id = ? AND id = ?)", jarID, reqID).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return errors.NotFound("request not found")
+	}
+
+	return nil
+}
+
+func jarKeyExists(tx *sql.Tx, jarID string) (bool, error) {
+	var exists bool
+	err := tx.QueryRow("SELECT EXISTS (SELECT 1 FROM request_jars WHERE jar_id = ?)", jarID).Scan(&exists)
+	return exists, err
+}
+
+func requestValues(req *models.Request) ([]any, error) {
+	headers, err := json.Marshal(req.Headers)
+	if err != nil {
+		return nil, err
+	}
+
+	query, err := json.Marshal(req.Query)
+	if err != nil {
+		return nil, err
+	}
+
+	fault, err := marshalNullable(req.Fault)
+	if err != nil {
+		return nil, err
+	}
+
+	var transferEncoding, trailers sql.NullString
+	if len(req.TransferEncoding) > 0 {
+		transferEncoding, err = marshalNullable(&req.TransferEncoding)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(req.Trailers) > 0 {
+		trailers, err = marshalNullable(&req.Trailers)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	tls, err := marshalNullable(req.TLS)
+	if err != nil {
+		return nil, err
+	}
+
+	bodyInfo, err := marshalNullable(req.BodyInfo)
+	if err != nil {
+		return nil, err
+	}
+
+	decoded, err := marshalNullable(req.Decoded)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := marshalNullable(req.Response)
+	if err != nil {
+		return nil, err
+	}
+
+	return []any{
+		req.ID, req.CreatedAt.UnixNano(), req.Method, req.Path, string(headers), string(query), req.ClientIP, req.Body, fault, req.RawQuery,
+		req.Proto, req.Host, req.RequestURI, req.ContentLength, transferEncoding, trailers, tls, bodyInfo, decoded, response,
+	}, nil
+}
+
+func scanRequest(row scanner) (*models.Request, error) {
+	var req models.Request
+	var createdAt int64
+	var headers, query string
+	var fault, transferEncoding, trailers, tls, bodyInfo, decoded, response sql.NullString
+
+	err := row.Scan(
+		&req.ID, &createdAt, &req.Method, &req.Path, &headers, &query, &req.ClientIP, &req.Body, &fault, &req.RawQuery,
+		&req.Proto, &req.Host, &req.RequestURI, &req.ContentLength, &transferEncoding, &trailers, &tls, &bodyInfo, &decoded, &response,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	req.CreatedAt = time.Unix(0, createdAt)
+
+	err = json.Unmarshal([]byte(headers), &req.Headers)
+	if err != nil {
+		return nil, err
+	}
+
+	err = json.Unmarshal([]byte(query), &req.Query)
+	if err != nil {
+		return nil, err
+	}
+
+	err = unmarshalNullable(fault, &req.Fault)
+	if err != nil {
+		return nil, err
+	}
+
+	if transferEncoding.Valid {
+		err = json.Unmarshal([]byte(transferEncoding.String), &req.TransferEncoding)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if trailers.Valid {
+		err = json.Unmarshal([]byte(trailers.String), &req.Trailers)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = unmarshalNullable(tls, &req.TLS)
+	if err != nil {
+		return nil, err
+	}
+
+	err = unmarshalNullable(bodyInfo, &req.BodyInfo)
+	if err != nil {
+		return nil, err
+	}
+
+	err = unmarshalNullable(decoded, &req.Decoded)
+	if err != nil {
+		return nil, err
+	}
+
+	err = unmarshalNullable(response, &req.Response)
+	if err != nil {
+		return nil, err
+	}
+
+	return &req, nil
+}
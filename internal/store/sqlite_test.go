@@ -0,0 +1,56 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"testing"
+)
+
+func TestMultiValueMigration(t *testing.T) {
+	db, err := sql.Open("sqlite", "file:"+filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("sql.Open: %v", err)
+	}
+	defer db.Close()
+
+	// The schema as it was before headers and query parameters kept every
+	// value
+	const before = 6
+	for _, migration := range migrations[:before] {
+		_, err = db.Exec(migration)
+		if err != nil {
+			t.Fatalf("applying old migration: %v", err)
+		}
+	}
+	_, _ = db.Exec(fmt.Sprintf("PRAGMA user_version = %d", before))
+
+	_, err = db.Exec(`INSERT INTO request_jars (jar_id) VALUES ('j');
+		INSERT INTO requests (id, jar_id, created_at, method, path, headers, query, client_ip) VALUES
+			('r1', 'j', 0, 'GET', '', '{"Accept":"*/*"}', '{"id":"1"}', ''),
+			('r2', 'j', 0, 'GET', '', 'null', 'null', '')`)
+	if err != nil {
+		t.Fatalf("inserting old rows: %v", err)
+	}
+
+	err = migrate(db)
+	if err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+
+	tests := map[string][2]string{
+		"r1": {`{"Accept":["*/*"]}`, `{"id":["1"]}`},
+		"r2": {`{}`, `{}`},
+	}
+
+	for id, want := range tests {
+		var headers, query string
+		err = db.QueryRow("SELECT headers, query FROM requests WHERE id = ?", id).Scan(&headers, &query)
+		if err != nil {
+			t.Fatalf("reading %s: %v", id, err)
+		}
+		if headers != want[0] || query != want[1] {
+			t.Fatalf("%s: expected %s and %s, got %s and %s", id, want[0], want[1], headers, query)
+		}
+	}
+}
@@ -0,0 +1,257 @@
+package store_test
+
+import (
+	"bytes"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/bpietroniro/requestjar-go/internal/models"
+	"github.com/bpietroniro/requestjar-go/internal/store"
+	"github.com/bpietroniro/requestjar-go/internal/store/storetest"
+)
+
+func TestInMemoryStores(t *testing.T) {
+	storetest.RunJarStoreSuite(t, func(t *testing.T) store.JarStore {
+		return store.NewInMemoryJarStore()
+	})
+	storetest.RunRequestStoreSuite(t, func(t *testing.T) store.RequestStore {
+		return store.NewInMemoryRequestStore()
+	})
+}
+
+func TestSQLiteStores(t *testing.T) {
+	storetest.RunJarStoreSuite(t, func(t *testing.T) store.JarStore {
+		return store.NewSQLiteJarStore(openSQLite(t))
+	})
+	storetest.RunRequestStoreSuite(t, func(t *testing.T) store.RequestStore {
+		return store.NewSQLiteRequestStore(openSQLite(t))
+	})
+}
+
+func TestFileStores(t *testing.T) {
+	storetest.RunJarStoreSuite(t, func(t *testing.T) store.JarStore {
+		return store.NewFileJarStore(openFileLog(t, t.TempDir()))
+	})
+	storetest.RunRequestStoreSuite(t, func(t *testing.T) store.RequestStore {
+		return store.NewFileRequestStore(openFileLog(t, t.TempDir()))
+	})
+}
+
+func openSQLite(t *testing.T) *sql.DB {
+	t.Helper()
+
+	db, err := store.OpenSQLite(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("OpenSQLite: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+
+	return db
+}
+
+func openFileLog(t *testing.T, dir string) *store.FileLog {
+	t.Helper()
+
+	l, err := store.OpenFileLog(dir)
+	if err != nil {
+		t.Fatalf("OpenFileLog: %v", err)
+	}
+	t.Cleanup(func() { _ = l.Close() })
+
+	return l
+}
+
+func TestFileLogReplayAndCompaction(t *testing.T) {
+	dir := t.TempDir()
+
+	l, err := store.OpenFileLog(dir)
+	if err != nil {
+		t.Fatalf("OpenFileLog: %v", err)
+	}
+	jars := store.NewFileJarStore(l)
+	requests := store.NewFileRequestStore(l)
+
+	keep, _ := jars.Create(&models.Jar{Name: "keep"})
+	drop, _ := jars.Create(&models.Jar{Name: "drop"})
+	for _, id := range []string{keep, drop} {
+		_ = requests.CreateJarKey(id)
+		_ = requests.CreateRequest(id, &models.Request{ID: "r1"})
+		_ = requests.CreateRequest(id, &models.Request{ID: "r2"})
+	}
+	_ = requests.CreateReplay(keep, &models.Replay{ID: "p1", RequestID: "r2"})
+	_ = requests.DeleteOneRequest(keep, "r1")
+	_ = requests.DeleteAllRrequests(drop)
+	_ = jars.Delete(drop)
+
+	err = l.Compact()
+	if err != nil {
+		t.Fatalf("Compact: %v", err)
+	}
+	_ = l.Close()
+
+	_, err = os.Stat(filepath.Join(dir, drop+".jsonl"))
+	if !os.IsNotExist(err) {
+		t.Fatalf("expected the deleted jar's log to be removed, got %v", err)
+	}
+
+	l = openFileLog(t, dir)
+
+	jar, err := store.NewFileJarStore(l).Get(keep)
+	if err != nil || jar.Name != "keep" {
+		t.Fatalf("expected jar to be replayed, got %+v, %v", jar, err)
+	}
+
+	replayed, err := store.NewFileRequestStore(l).List(keep)
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(replayed) != 1 || replayed[0].ID != "r2" {
+		t.Fatalf("expected only request r2 after replay, got %+v", replayed)
+	}
+
+	replays, err := store.NewFileRequestStore(l).ListReplays(keep, "r2")
+	if err != nil || len(replays) != 1 {
+		t.Fatalf("expected r2's replay to survive compaction, got %+v, %v", replays, err)
+	}
+}
+
+func TestFileLogReplaysClears(t *testing.T) {
+	dir := t.TempDir()
+
+	l := openFileLog(t, dir)
+	requests := store.NewFileRequestStore(l)
+	_ = requests.CreateJarKey("jar")
+	_ = requests.CreateRequest("jar", &models.Request{ID: "r1"})
+	_ = requests.CreateReplay("jar", &models.Replay{ID: "p1", RequestID: "r1"})
+
+	_, err := requests.ClearRequests("jar")
+	if err != nil {
+		t.Fatalf("ClearRequests: %v", err)
+	}
+	_ = requests.CreateRequest("jar", &models.Request{ID: "r2"})
+	_ = l.Close()
+
+	replayed, err := store.NewFileRequestStore(openFileLog(t, dir)).List("jar")
+	if err != nil || len(replayed) != 1 || replayed[0].ID != "r2" {
+		t.Fatalf("expected only the request captured after the clear, got %+v, %v", replayed, err)
+	}
+}
+
+func TestFileLogReplaysLargeEntries(t *testing.T) {
+	dir := t.TempDir()
+
+	l := openFileLog(t, dir)
+	requests := store.NewFileRequestStore(l)
+	_ = requests.CreateJarKey("jar")
+
+	// Larger than the lines a bufio.Scanner reads by default, even once raised
+	body := bytes.Repeat([]byte("a"), 65<<20)
+	err := requests.CreateRequest("jar", &models.Request{ID: "r1", Body: body})
+	if err != nil {
+		t.Fatalf("CreateRequest: %v", err)
+	}
+	_ = l.Close()
+
+	l, err = store.OpenFileLog(dir)
+	if err != nil {
+		t.Fatalf("reopening: %v", err)
+	}
+	defer l.Close()
+
+	req, err := store.NewFileRequestStore(l).Get("jar", "r1")
+	if err != nil || !bytes.Equal(req.Body, body) {
+		t.Fatalf("expected the large body to be replayed, got %v", err)
+	}
+}
+
+func TestFileLogDropsTornEntries(t *testing.T) {
+	dir := t.TempDir()
+
+	l := openFileLog(t, dir)
+	requests := store.NewFileRequestStore(l)
+	_ = requests.CreateJarKey("jar")
+	_ = requests.CreateRequest("jar", &models.Request{ID: "r1"})
+	_ = l.Close()
+
+	// As left by a crash halfway through writing an entry
+	f, err := os.OpenFile(filepath.Join(dir, "jar.jsonl"), os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		t.Fatalf("opening log: %v", err)
+	}
+	_, _ = f.WriteString(`{"op":"request.create","request":{"id":"r2"`)
+	_ = f.Close()
+
+	l = openFileLog(t, dir)
+	requests = store.NewFileRequestStore(l)
+	_ = requests.CreateRequest("jar", &models.Request{ID: "r3"})
+	_ = l.Close()
+
+	// Later entries aren't appended to the torn one
+	requireFileRequestIDs(t, openFileLog(t, dir), "jar", "r1", "r3")
+}
+
+func requireFileRequestIDs(t *testing.T, l *store.FileLog, jarID string, want ...string) {
+	t.Helper()
+
+	requests, err := store.NewFileRequestStore(l).List(jarID)
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+
+	got := make([]string, 0, len(requests))
+	for _, req := range requests {
+		got = append(got, req.ID)
+	}
+	if !slices.Equal(got, want) {
+		t.Fatalf("expected requests %v, got %v", want, got)
+	}
+}
+
+func TestSQLiteReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+
+	db, err := store.OpenSQLite(path)
+	if err != nil {
+		t.Fatalf("OpenSQLite: %v", err)
+	}
+	jarID, _ := store.NewSQLiteJarStore(db).Create(&models.Jar{Name: "jar"})
+	_ = store.NewSQLiteRequestStore(db).CreateJarKey(jarID)
+	_ = store.NewSQLiteRequestStore(db).CreateRequest(jarID, &models.Request{ID: "r1"})
+	_ = db.Close()
+
+	// Reopening must not re-run migrations that were already applied
+	db, err = store.OpenSQLite(path)
+	if err != nil {
+		t.Fatalf("reopening: %v", err)
+	}
+	defer db.Close()
+
+	requests, err := store.NewSQLiteRequestStore(db).List(jarID)
+	if err != nil || len(requests) != 1 {
+		t.Fatalf("expected the request to survive reopening, got %+v, %v", requests, err)
+	}
+}
+
+func TestSQLitePathIsEscaped(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "odd?name#1 %41.db")
+
+	db, err := store.OpenSQLite(path)
+	if err != nil {
+		t.Fatalf("OpenSQLite: %v", err)
+	}
+	defer db.Close()
+
+	_, err = store.NewSQLiteJarStore(db).Create(&models.Jar{Name: "jar"})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	_, err = os.Stat(path)
+	if err != nil {
+		t.Fatalf("expected the database at the exact path given, got %v", err)
+	}
+}
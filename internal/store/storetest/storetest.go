@@ -0,0 +1,746 @@
+// Package storetest holds conformance suites that every store.JarStore and
+// store.RequestStore implementation is expected to pass, so that new backends
+// behave the same way as the in-memory one.
+package storetest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bpietroniro/requestjar-go/internal/errors"
+	"github.com/bpietroniro/requestjar-go/internal/models"
+	"github.com/bpietroniro/requestjar-go/internal/store"
+)
+
+// JarStoreFactory returns a new, empty store for each subtest. Any cleanup
+// should be registered with t.Cleanup.
+type JarStoreFactory func(t *testing.T) store.JarStore
+
+// RequestStoreFactory returns a new, empty store for each subtest. Any cleanup
+// should be registered with t.Cleanup.
+type RequestStoreFactory func(t *testing.T) store.RequestStore
+
+// RunJarStoreSuite checks a JarStore implementation against the behavior of
+// the in-memory store, running each case as a subtest on a fresh store. It
+// includes concurrent writes, so run it with -race.
+func RunJarStoreSuite(t *testing.T, newStore JarStoreFactory) {
+	t.Run("CreateThenGet", func(t *testing.T) {
+		s := newStore(t)
+
+		created := &models.Jar{Name: "my jar"}
+		id, err := s.Create(created)
+		if err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+		if id == "" || created.ID != id || created.CreatedAt.IsZero() {
+			t.Fatalf("Create did not fill in the ID and creation time: %q, %+v", id, created)
+		}
+
+		jar, err := s.Get(id)
+		if err != nil {
+			t.Fatalf("Get: %v", err)
+		}
+		if jar.ID != id || jar.Name != "my jar" {
+			t.Fatalf("unexpected jar: %+v", jar)
+		}
+		if !jar.CreatedAt.Equal(created.CreatedAt) {
+			t.Fatalf("expected createdAt %v, got %v", created.CreatedAt, jar.CreatedAt)
+		}
+		if jar.Retention != nil {
+			t.Fatalf("expected no retention policy, got %+v", jar.Retention)
+		}
+	})
+
+	t.Run("SettingsRoundTrip", func(t *testing.T) {
+		s := newStore(t)
+
+		want := &models.Jar{
+			Name:        "jar",
+			Retention:   &models.RetentionPolicy{MaxRequests: 10, MaxAge: models.Duration(time.Hour), MaxBodyBytes: 1 << 20},
+			ExpiresAt:   ptr(time.Now().Add(time.Hour)),
+			MaxBodySize: 4096,
+			Response: &models.MockResponse{
+				StatusCode:  202,
+				Headers:     map[string]string{"X-Mock": "yes"},
+				Body:        `{"ok":true}`,
+				ContentType: "application/json",
+			},
+			Faults: &models.FaultConfig{
+				Delay:     models.Duration(time.Second),
+				MaxDelay:  models.Duration(2 * time.Second),
+				ErrorRate: 12.5,
+				DropRate:  1,
+			},
+			Forward: &models.ForwardConfig{
+				Targets: []string{"http://localhost:3000", "https://staging.example.com/hooks"},
+				Timeout: models.Duration(5 * time.Second),
+				Retries: 3,
+				Respond: models.RespondWithUpstream,
+			},
+			Rules: []models.ResponseRule{
+				{
+					Name:     "orders",
+					Match:    models.RequestMatcher{Method: "POST", Path: "orders/*", JSONBody: map[string]string{"type": "created"}},
+					Response: models.MockResponse{StatusCode: 201},
+				},
+				{
+					Match:    models.RequestMatcher{Headers: map[string]string{"X-Fail": "1"}},
+					Response: models.MockResponse{StatusCode: 500, Body: "boom"},
+				},
+			},
+		}
+		id, err := s.Create(want)
+		if err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+
+		got, err := s.Get(id)
+		if err != nil {
+			t.Fatalf("Get: %v", err)
+		}
+		requireSameSettings(t, want, got)
+	})
+
+	t.Run("Update", func(t *testing.T) {
+		s := newStore(t)
+
+		created := &models.Jar{Name: "before"}
+		id, _ := s.Create(created)
+
+		want := &models.Jar{
+			ID:        id,
+			Name:      "after",
+			Retention: &models.RetentionPolicy{MaxRequests: 5},
+			ExpiresAt: ptr(time.Now().Add(time.Hour)),
+		}
+		err := s.Update(want)
+		if err != nil {
+			t.Fatalf("Update: %v", err)
+		}
+
+		got, err := s.Get(id)
+		if err != nil {
+			t.Fatalf("Get: %v", err)
+		}
+		requireSameSettings(t, want, got)
+		if !got.CreatedAt.Equal(created.CreatedAt) {
+			t.Fatalf("Update changed createdAt from %v to %v", created.CreatedAt, got.CreatedAt)
+		}
+
+		// Clearing settings must stick too
+		err = s.Update(&models.Jar{ID: id, Name: "cleared"})
+		if err != nil {
+			t.Fatalf("Update: %v", err)
+		}
+
+		got, _ = s.Get(id)
+		requireSameSettings(t, &models.Jar{Name: "cleared"}, got)
+	})
+
+	t.Run("UpdateMissing", func(t *testing.T) {
+		s := newStore(t)
+
+		requireNotFound(t, s.Update(&models.Jar{ID: "missing", Name: "jar"}))
+	})
+
+	t.Run("CreateGivesUniqueIDs", func(t *testing.T) {
+		s := newStore(t)
+
+		seen := make(map[string]struct{})
+		for i := 0; i < 100; i++ {
+			id, err := s.Create(&models.Jar{Name: "jar"})
+			if err != nil {
+				t.Fatalf("Create: %v", err)
+			}
+			if _, dup := seen[id]; dup {
+				t.Fatalf("duplicate jar ID %q", id)
+			}
+			seen[id] = struct{}{}
+		}
+	})
+
+	t.Run("ListEmpty", func(t *testing.T) {
+		s := newStore(t)
+
+		jars, err := s.List()
+		if err != nil {
+			t.Fatalf("List: %v", err)
+		}
+		if jars == nil || len(jars) != 0 {
+			t.Fatalf("expected an empty, non-nil list, got %#v", jars)
+		}
+	})
+
+	t.Run("ListOrderedByCreation", func(t *testing.T) {
+		s := newStore(t)
+
+		var ids []string
+		for i := 0; i < 5; i++ {
+			id, err := s.Create(&models.Jar{Name: fmt.Sprintf("jar %d", i)})
+			if err != nil {
+				t.Fatalf("Create: %v", err)
+			}
+			ids = append(ids, id)
+		}
+
+		jars, err := s.List()
+		if err != nil {
+			t.Fatalf("List: %v", err)
+		}
+		if len(jars) != len(ids) {
+			t.Fatalf("expected %d jars, got %d", len(ids), len(jars))
+		}
+		for i, jar := range jars {
+			if jar.ID != ids[i] {
+				t.Fatalf("jar %d: expected ID %q, got %q", i, ids[i], jar.ID)
+			}
+		}
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		s := newStore(t)
+
+		keep, _ := s.Create(&models.Jar{Name: "keep"})
+		drop, _ := s.Create(&models.Jar{Name: "drop"})
+
+		err := s.Delete(drop)
+		if err != nil {
+			t.Fatalf("Delete: %v", err)
+		}
+
+		_, err = s.Get(drop)
+		requireNotFound(t, err)
+
+		jars, err := s.List()
+		if err != nil {
+			t.Fatalf("List: %v", err)
+		}
+		if len(jars) != 1 || jars[0].ID != keep {
+			t.Fatalf("expected only jar %q to remain, got %+v", keep, jars)
+		}
+	})
+
+	t.Run("DeleteMissing", func(t *testing.T) {
+		s := newStore(t)
+
+		err := s.Delete("missing")
+		if err != nil {
+			t.Fatalf("expected deleting a missing jar to succeed, got %v", err)
+		}
+	})
+
+	t.Run("ConcurrentCreates", func(t *testing.T) {
+		s := newStore(t)
+
+		const n = 50
+		var wg sync.WaitGroup
+		for i := 0; i < n; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, err := s.Create(&models.Jar{Name: "jar"})
+				if err != nil {
+					t.Errorf("Create: %v", err)
+				}
+				_, err = s.List()
+				if err != nil {
+					t.Errorf("List: %v", err)
+				}
+			}()
+		}
+		wg.Wait()
+
+		jars, err := s.List()
+		if err != nil {
+			t.Fatalf("List: %v", err)
+		}
+		if len(jars) != n {
+			t.Fatalf("expected %d jars, got %d", n, len(jars))
+		}
+	})
+}
+
+// RunRequestStoreSuite checks a RequestStore implementation against the
+// behavior of the in-memory store, running each case as a subtest on a fresh
+// store. It includes concurrent writes, so run it with -race.
+func RunRequestStoreSuite(t *testing.T, newStore RequestStoreFactory) {
+	t.Run("CreateThenList", func(t *testing.T) {
+		s := newStore(t)
+		requireCreateJarKey(t, s, "jar")
+
+		want := newRequest("r1")
+		err := s.CreateRequest("jar", want)
+		if err != nil {
+			t.Fatalf("CreateRequest: %v", err)
+		}
+
+		requests, err := s.List("jar")
+		if err != nil {
+			t.Fatalf("List: %v", err)
+		}
+		if len(requests) != 1 {
+			t.Fatalf("expected 1 request, got %d", len(requests))
+		}
+
+		requireSameRequest(t, want, requests[0])
+	})
+
+	t.Run("OptionalFieldsRoundTrip", func(t *testing.T) {
+		s := newStore(t)
+		requireCreateJarKey(t, s, "jar")
+
+		want := newRequest("r1")
+		want.Fault = &models.InjectedFault{Delay: models.Duration(time.Second), Status: 503}
+		want.TransferEncoding = []string{"chunked"}
+		want.ContentLength = -1
+		want.Trailers = http.Header{"X-Checksum": {"abc"}}
+		want.BodyInfo = &models.BodyInfo{Size: 5, SHA256: "abc123", ContentType: "text/plain", Offloaded: true}
+		want.Decoded = &models.DecodedBody{
+			ContentEncoding: []string{"gzip"},
+			Size:            42,
+			Parts:           []models.BodyPart{{Name: "file", Filename: "a.txt", Size: 3}},
+		}
+		want.Response = &models.MockResponse{StatusCode: 201, Headers: map[string]string{"X-Mock": "1"}, Body: "created"}
+		want.TLS = &models.TLSInfo{
+			Version:           "TLS 1.3",
+			CipherSuite:       "TLS_AES_128_GCM_SHA256",
+			ServerName:        "hooks.example.com",
+			ClientCertSubject: "CN=client",
+		}
+		err := s.CreateRequest("jar", want)
+		if err != nil {
+			t.Fatalf("CreateRequest: %v", err)
+		}
+
+		requests, err := s.List("jar")
+		if err != nil {
+			t.Fatalf("List: %v", err)
+		}
+		requireSameRequest(t, want, requests[0])
+	})
+
+	t.Run("Get", func(t *testing.T) {
+		s := newStore(t)
+		requireCreateJarKey(t, s, "jar")
+
+		want := newRequest("r2")
+		for _, req := range []*models.Request{newRequest("r1"), want, newRequest("r3")} {
+			_ = s.CreateRequest("jar", req)
+		}
+
+		got, err := s.Get("jar", "r2")
+		if err != nil {
+			t.Fatalf("Get: %v", err)
+		}
+		requireSameRequest(t, want, got)
+
+		_, err = s.Get("jar", "missing")
+		requireNotFound(t, err)
+
+		_, err = s.Get("missing", "r2")
+		requireNotFound(t, err)
+	})
+
+	t.Run("ListEmptyJar", func(t *testing.T) {
+		s := newStore(t)
+		requireCreateJarKey(t, s, "jar")
+
+		requests, err := s.List("jar")
+		if err != nil {
+			t.Fatalf("List: %v", err)
+		}
+		if requests == nil || len(requests) != 0 {
+			t.Fatalf("expected an empty, non-nil list, got %#v", requests)
+		}
+	})
+
+	t.Run("ListOrderedByID", func(t *testing.T) {
+		s := newStore(t)
+		requireCreateJarKey(t, s, "jar")
+
+		for _, id := range []string{"c", "a", "b", "e", "d"} {
+			err := s.CreateRequest("jar", newRequest(id))
+			if err != nil {
+				t.Fatalf("CreateRequest: %v", err)
+			}
+		}
+
+		requireRequestIDs(t, s, "jar", "a", "b", "c", "d", "e")
+	})
+
+	t.Run("ListAfter", func(t *testing.T) {
+		s := newStore(t)
+		requireCreateJarKey(t, s, "jar")
+
+		for _, id := range []string{"01", "02", "03", "04", "05"} {
+			_ = s.CreateRequest("jar", newRequest(id))
+		}
+
+		pages := []struct {
+			after string
+			limit int
+			want  []string
+		}{
+			{"", 0, []string{"01", "02", "03", "04", "05"}},
+			{"", 2, []string{"01", "02"}},
+			{"02", 2, []string{"03", "04"}},
+			{"04", 2, []string{"05"}},
+			{"05", 2, []string{}},
+			// The cursor doesn't have to be an existing ID
+			{"025", 0, []string{"03", "04", "05"}},
+		}
+
+		for _, page := range pages {
+			requests, err := s.ListAfter("jar", page.after, page.limit)
+			if err != nil {
+				t.Fatalf("ListAfter: %v", err)
+			}
+
+			got := []string{}
+			for _, r := range requests {
+				got = append(got, r.ID)
+			}
+			if fmt.Sprint(got) != fmt.Sprint(page.want) {
+				t.Fatalf("after %q limit %d: expected %v, got %v", page.after, page.limit, page.want, got)
+			}
+		}
+
+		_, err := s.ListAfter("missing", "", 0)
+		requireNotFound(t, err)
+	})
+
+	t.Run("JarsAreIsolated", func(t *testing.T) {
+		s := newStore(t)
+		requireCreateJarKey(t, s, "one")
+		requireCreateJarKey(t, s, "two")
+
+		_ = s.CreateRequest("one", newRequest("r1"))
+		_ = s.CreateRequest("two", newRequest("r2"))
+
+		requireRequestIDs(t, s, "one", "r1")
+		requireRequestIDs(t, s, "two", "r2")
+	})
+
+	t.Run("MissingJar", func(t *testing.T) {
+		s := newStore(t)
+
+		requireNotFound(t, s.CreateRequest("missing", newRequest("r1")))
+
+		_, err := s.List("missing")
+		requireNotFound(t, err)
+
+		requireNotFound(t, s.DeleteOneRequest("missing", "r1"))
+		requireNotFound(t, s.DeleteAllRrequests("missing"))
+	})
+
+	t.Run("DeleteOneRequest", func(t *testing.T) {
+		s := newStore(t)
+		requireCreateJarKey(t, s, "jar")
+
+		for _, id := range []string{"r1", "r2", "r3"} {
+			_ = s.CreateRequest("jar", newRequest(id))
+		}
+
+		err := s.DeleteOneRequest("jar", "r2")
+		if err != nil {
+			t.Fatalf("DeleteOneRequest: %v", err)
+		}
+
+		requireRequestIDs(t, s, "jar", "r1", "r3")
+	})
+
+	t.Run("DeleteMissingRequest", func(t *testing.T) {
+		s := newStore(t)
+		requireCreateJarKey(t, s, "jar")
+		_ = s.CreateRequest("jar", newRequest("r1"))
+
+		requireNotFound(t, s.DeleteOneRequest("jar", "missing"))
+		requireRequestIDs(t, s, "jar", "r1")
+
+		// Deleting a request twice finds nothing the second time
+		err := s.DeleteOneRequest("jar", "r1")
+		if err != nil {
+			t.Fatalf("DeleteOneRequest: %v", err)
+		}
+		requireNotFound(t, s.DeleteOneRequest("jar", "r1"))
+	})
+
+	t.Run("DeleteAllRequests", func(t *testing.T) {
+		s := newStore(t)
+		requireCreateJarKey(t, s, "jar")
+		_ = s.CreateRequest("jar", newRequest("r1"))
+
+		err := s.DeleteAllRrequests("jar")
+		if err != nil {
+			t.Fatalf("DeleteAllRrequests: %v", err)
+		}
+
+		_, err = s.List("jar")
+		requireNotFound(t, err)
+		requireNotFound(t, s.CreateRequest("jar", newRequest("r2")))
+	})
+
+	t.Run("RecreateJarKeyKeepsRequests", func(t *testing.T) {
+		s := newStore(t)
+		requireCreateJarKey(t, s, "jar")
+		_ = s.CreateRequest("jar", newRequest("r1"))
+
+		requireCreateJarKey(t, s, "jar")
+
+		requireRequestIDs(t, s, "jar", "r1")
+	})
+
+	t.Run("RecreateJarKeyAfterDeleteStartsEmpty", func(t *testing.T) {
+		s := newStore(t)
+		requireCreateJarKey(t, s, "jar")
+		_ = s.CreateRequest("jar", newRequest("r1"))
+		_ = s.DeleteAllRrequests("jar")
+
+		requireCreateJarKey(t, s, "jar")
+
+		requireRequestIDs(t, s, "jar")
+	})
+
+	t.Run("ClearRequests", func(t *testing.T) {
+		s := newStore(t)
+		requireCreateJarKey(t, s, "jar")
+		_ = s.CreateRequest("jar", newRequest("r1"))
+		_ = s.CreateReplay("jar", newReplay("p1", "r1"))
+
+		cleared, err := s.ClearRequests("jar")
+		if err != nil || len(cleared) != 1 || cleared[0] != "r1" {
+			t.Fatalf("expected r1 to be cleared, got %v, %v", cleared, err)
+		}
+		requireRequestIDs(t, s, "jar")
+
+		// The jar is still there to capture into, without the old replays
+		err = s.CreateRequest("jar", newRequest("r1"))
+		if err != nil {
+			t.Fatalf("CreateRequest: %v", err)
+		}
+		replays, err := s.ListReplays("jar", "r1")
+		if err != nil || len(replays) != 0 {
+			t.Fatalf("expected the replays to be cleared, got %+v, %v", replays, err)
+		}
+
+		_, err = s.ClearRequests("missing")
+		requireNotFound(t, err)
+	})
+
+	t.Run("Replays", func(t *testing.T) {
+		s := newStore(t)
+		requireCreateJarKey(t, s, "jar")
+		_ = s.CreateRequest("jar", newRequest("r1"))
+		_ = s.CreateRequest("jar", newRequest("r2"))
+
+		want := newReplay("p2", "r1")
+		want.Response = &models.ReplayResponse{StatusCode: 200, Headers: http.Header{"X-Up": {"1"}}, Body: []byte("ok")}
+		for _, replay := range []*models.Replay{want, newReplay("p1", "r1"), newReplay("p3", "r2")} {
+			err := s.CreateReplay("jar", replay)
+			if err != nil {
+				t.Fatalf("CreateReplay: %v", err)
+			}
+		}
+
+		replays, err := s.ListReplays("jar", "r1")
+		if err != nil {
+			t.Fatalf("ListReplays: %v", err)
+		}
+		if len(replays) != 2 || replays[0].ID != "p1" || replays[1].ID != "p2" {
+			t.Fatalf("expected replays p1 and p2, got %+v", replays)
+		}
+		if fmt.Sprint(replays[1].Response) != fmt.Sprint(want.Response) || replays[1].Target != want.Target {
+			t.Fatalf("expected %+v, got %+v", want, replays[1])
+		}
+
+		requireNotFound(t, s.CreateReplay("jar", newReplay("p4", "missing")))
+		requireNotFound(t, s.CreateReplay("missing", newReplay("p4", "r1")))
+
+		_, err = s.ListReplays("jar", "missing")
+		requireNotFound(t, err)
+
+		// Replays go with their request
+		err = s.DeleteOneRequest("jar", "r1")
+		if err != nil {
+			t.Fatalf("DeleteOneRequest: %v", err)
+		}
+		_ = s.CreateRequest("jar", newRequest("r1"))
+
+		replays, err = s.ListReplays("jar", "r1")
+		if err != nil || len(replays) != 0 {
+			t.Fatalf("expected no replays after the request was deleted, got %+v, %v", replays, err)
+		}
+	})
+
+	t.Run("LargeBody", func(t *testing.T) {
+		s := newStore(t)
+		requireCreateJarKey(t, s, "jar")
+
+		// Above the 64 MiB that line-oriented backends might be tempted to
+		// cap entries at
+		req := newRequest("r1")
+		req.Body = bytes.Repeat([]byte("a"), 65<<20)
+
+		err := s.CreateRequest("jar", req)
+		if err != nil {
+			t.Fatalf("CreateRequest: %v", err)
+		}
+
+		got, err := s.Get("jar", "r1")
+		if err != nil || !bytes.Equal(got.Body, req.Body) {
+			t.Fatalf("expected the large body back, got %v", err)
+		}
+	})
+
+	t.Run("ConcurrentWrites", func(t *testing.T) {
+		s := newStore(t)
+		requireCreateJarKey(t, s, "jar")
+
+		const n = 50
+		var wg sync.WaitGroup
+		for i := 0; i < n; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				err := s.CreateRequest("jar", newRequest(fmt.Sprintf("r%d", i)))
+				if err != nil {
+					t.Errorf("CreateRequest: %v", err)
+				}
+				_, err = s.List("jar")
+				if err != nil {
+					t.Errorf("List: %v", err)
+				}
+				err = s.DeleteOneRequest("jar", "nonexistent")
+				if !errors.Is(err, errors.ErrNotFound) {
+					t.Errorf("DeleteOneRequest: expected not found, got %v", err)
+				}
+			}()
+		}
+		wg.Wait()
+
+		requests, err := s.List("jar")
+		if err != nil {
+			t.Fatalf("List: %v", err)
+		}
+		if len(requests) != n {
+			t.Fatalf("expected %d requests, got %d", n, len(requests))
+		}
+	})
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+// requireSameSettings compares everything about two jars except their ID and
+// creation time.
+func requireSameSettings(t *testing.T, want, got *models.Jar) {
+	t.Helper()
+
+	if (want.ExpiresAt == nil) != (got.ExpiresAt == nil) ||
+		(want.ExpiresAt != nil && !want.ExpiresAt.Equal(*got.ExpiresAt)) {
+		t.Fatalf("expected expiresAt %v, got %v", want.ExpiresAt, got.ExpiresAt)
+	}
+
+	// Times are compared above since their encoding depends on the location
+	w, g := *want, *got
+	w.ID, g.ID = "", ""
+	w.CreatedAt, g.CreatedAt = time.Time{}, time.Time{}
+	w.ExpiresAt, g.ExpiresAt = nil, nil
+
+	wantJSON, _ := json.Marshal(w)
+	gotJSON, _ := json.Marshal(g)
+	if string(wantJSON) != string(gotJSON) {
+		t.Fatalf("jar settings did not round-trip:\nwant %s\ngot  %s", wantJSON, gotJSON)
+	}
+}
+
+// requireSameRequest compares every field of two requests.
+func requireSameRequest(t *testing.T, want, got *models.Request) {
+	t.Helper()
+
+	if !want.CreatedAt.Equal(got.CreatedAt) {
+		t.Fatalf("expected createdAt %v, got %v", want.CreatedAt, got.CreatedAt)
+	}
+
+	w, g := *want, *got
+	w.CreatedAt, g.CreatedAt = time.Time{}, time.Time{}
+
+	wantJSON, _ := json.Marshal(w)
+	gotJSON, _ := json.Marshal(g)
+	if string(wantJSON) != string(gotJSON) {
+		t.Fatalf("request did not round-trip:\nwant %s\ngot  %s", wantJSON, gotJSON)
+	}
+}
+
+func newRequest(id string) *models.Request {
+	return &models.Request{
+		ID:            id,
+		CreatedAt:     time.Now(),
+		Method:        "POST",
+		Path:          "hooks/" + id,
+		Headers:       http.Header{"X-Test": {"yes"}, "Set-Cookie": {"a=1", "b=2"}},
+		Query:         url.Values{"q": {"1"}, "id": {"2", "1"}},
+		RawQuery:      "q=1&id=2&id=1",
+		Proto:         "HTTP/1.1",
+		Host:          "localhost:8080",
+		RequestURI:    "/r/jar/hooks/" + id + "?q=1&id=2&id=1",
+		ContentLength: 12,
+		ClientIP:      "127.0.0.1:1234",
+		Body:          []byte(`{"id":"` + id + `"}`),
+	}
+}
+
+func newReplay(id string, reqID string) *models.Replay {
+	return &models.Replay{
+		ID:        id,
+		RequestID: reqID,
+		CreatedAt: time.Unix(1700000000, 0),
+		Target:    "http://localhost:3000/hook",
+		Method:    "POST",
+		Headers:   http.Header{"Content-Type": {"application/json"}},
+		Duration:  models.Duration(time.Millisecond),
+	}
+}
+
+func requireCreateJarKey(t *testing.T, s store.RequestStore, jarID string) {
+	t.Helper()
+
+	err := s.CreateJarKey(jarID)
+	if err != nil {
+		t.Fatalf("CreateJarKey: %v", err)
+	}
+}
+
+func requireRequestIDs(t *testing.T, s store.RequestStore, jarID string, want ...string) {
+	t.Helper()
+
+	requests, err := s.List(jarID)
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+
+	got := make([]string, len(requests))
+	for i, r := range requests {
+		got[i] = r.ID
+	}
+
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Fatalf("expected request IDs %v, got %v", want, got)
+	}
+}
+
+func requireNotFound(t *testing.T, err error) {
+	t.Helper()
+
+	var httpErr errors.HTTPError
+	if !errors.As(err, &httpErr) || httpErr.HTTPCode() != 404 {
+		t.Fatalf("expected a 404 errors.HTTPError, got %#v", err)
+	}
+}
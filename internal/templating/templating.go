@@ -0,0 +1,240 @@
+// Package templating renders templated mock responses from the request they
+// answer. Templates use Go's text/template syntax, with the captured request as
+// data:
+//
+//	{{ .ID }} {{ .Method }} {{ .Path }} {{ .RawQuery }} {{ .ClientIP }} {{ .Body }}
+//	{{ .JSON.challenge }}         decoded JSON body; empty if there isn't one
+//	{{ header "X-Request-Id" }}   first header value, any case
+//	{{ query "token" }}           first query parameter value
+//	{{ index .Headers "Set-Cookie" }}, {{ index .Query "id" }}   every value
+//	{{ json "event.id" }}         dotted field from the JSON body, "" if absent
+//
+// Missing map keys, such as JSON fields the body doesn't have, render as
+// nothing; use json for nested fields whose parents may be missing too.
+// Templates are parsed once, when Validate is called as the response is saved,
+// or the first time they are rendered after a restart.
+package templating
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"text/template"
+	"text/template/parse"
+	"time"
+
+	"github.com/bpietroniro/requestjar-go/internal/matcher"
+	"github.com/bpietroniro/requestjar-go/internal/models"
+)
+
+type data struct {
+	ID        string
+	CreatedAt time.Time
+	Method    string
+	Path      string
+	ClientIP  string
+	Headers   http.Header
+	Query     url.Values
+	RawQuery  string
+	Body      string
+	JSON      any
+}
+
+// maxParsed bounds how many parsed templates are kept. When it is reached the
+// cache starts again from empty.
+const maxParsed = 1000
+
+type parsedKey struct {
+	name string
+	text string
+}
+
+// parsed holds every template parsed so far, without helpers bound to a
+// request. Rendering binds them to a clone.
+var parsed = struct {
+	sync.Mutex
+	templates map[parsedKey]*template.Template
+}{templates: make(map[parsedKey]*template.Template)}
+
+// Validate checks that every template in response parses, keeping them parsed
+// for Render.
+func Validate(response *models.MockResponse) error {
+	if !response.Template {
+		return nil
+	}
+
+	_, err := compile("body", response.Body)
+	if err != nil {
+		return err
+	}
+
+	for name, value := range response.Headers {
+		_, err = compile(name, value)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Render returns a copy of response with its templates executed against req.
+// Responses that aren't templated are returned unchanged.
+func Render(response *models.MockResponse, req *models.Request) (*models.MockResponse, error) {
+	if response == nil || !response.Template {
+		return response, nil
+	}
+
+	json, ok := matcher.RequestJSON(req)
+	if !ok {
+		json = map[string]any{}
+	}
+
+	d := &data{
+		ID:        req.ID,
+		CreatedAt: req.CreatedAt,
+		Method:    req.Method,
+		Path:      req.Path,
+		ClientIP:  req.ClientIP,
+		Headers:   req.Headers,
+		Query:     req.Query,
+		RawQuery:  req.RawQuery,
+		Body:      string(req.Body),
+		JSON:      json,
+	}
+
+	rendered := *response
+	rendered.Template = false
+
+	body, err := execute("body", response.Body, d)
+	if err != nil {
+		return nil, err
+	}
+	rendered.Body = body
+
+	rendered.Headers = make(map[string]string, len(response.Headers))
+	for name, value := range response.Headers {
+		rendered.Headers[name], err = execute(name, value, d)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &rendered, nil
+}
+
+func execute(name string, text string, d *data) (string, error) {
+	t, err := compile(name, text)
+	if err != nil {
+		return "", err
+	}
+
+	t, err = t.Clone()
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	err = t.Funcs(funcs(d)).Execute(&buf, d)
+	if err != nil {
+		return "", fmt.Errorf("executing %s template: %w", name, err)
+	}
+
+	return buf.String(), nil
+}
+
+// compile returns the parsed template, parsing it only if it hasn't been
+// already.
+func compile(name string, text string) (*template.Template, error) {
+	key := parsedKey{name: name, text: text}
+
+	parsed.Lock()
+	t, found := parsed.templates[key]
+	parsed.Unlock()
+	if found {
+		return t, nil
+	}
+
+	t, err := template.New(name).Option("missingkey=zero").Funcs(funcs(nil)).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("parsing %s template: %w", name, err)
+	}
+
+	for _, defined := range t.Templates() {
+		if defined.Tree != nil {
+			blankMissing(defined.Tree.Root)
+		}
+	}
+
+	parsed.Lock()
+	defer parsed.Unlock()
+
+	if len(parsed.templates) >= maxParsed {
+		parsed.templates = make(map[parsedKey]*template.Template)
+	}
+	parsed.templates[key] = t
+
+	return t, nil
+}
+
+// blankMissing pipes the value of every action that prints one through
+// orEmpty, as html/template does with its escapers, so that a missing map key
+// prints nothing rather than "<no value>".
+func blankMissing(node parse.Node) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, child := range n.Nodes {
+			blankMissing(child)
+		}
+	case *parse.ActionNode:
+		if len(n.Pipe.Decl) == 0 {
+			orEmpty := parse.NewIdentifier("orEmpty").SetTree(nil).SetPos(n.Position())
+			n.Pipe.Cmds = append(n.Pipe.Cmds, &parse.CommandNode{NodeType: parse.NodeCommand, Pos: n.Position(), Args: []parse.Node{orEmpty}})
+		}
+	case *parse.IfNode:
+		blankMissing(n.List)
+		blankMissing(n.ElseList)
+	case *parse.RangeNode:
+		blankMissing(n.List)
+		blankMissing(n.ElseList)
+	case *parse.WithNode:
+		blankMissing(n.List)
+		blankMissing(n.ElseList)
+	}
+}
+
+// funcs binds the helper functions to d; d may be nil when only parsing.
+func funcs(d *data) template.FuncMap {
+	return template.FuncMap{
+		"header": func(name string) string {
+			if d == nil {
+				return ""
+			}
+			return d.Headers.Get(name)
+		},
+		"query": func(name string) string {
+			if d == nil {
+				return ""
+			}
+			return d.Query.Get(name)
+		},
+		"orEmpty": func(v any) any {
+			if v == nil {
+				return ""
+			}
+			return v
+		},
+		"json": func(field string) string {
+			if d == nil || d.JSON == nil {
+				return ""
+			}
+			value, _ := matcher.LookupField(d.JSON, field)
+			return value
+		},
+	}
+}
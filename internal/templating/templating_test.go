@@ -0,0 +1,102 @@
+package templating
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/bpietroniro/requestjar-go/internal/models"
+)
+
+func TestRender(t *testing.T) {
+	req := &models.Request{
+		ID:      "req-1",
+		Method:  "POST",
+		Headers: http.Header{"X-Request-Id": {"abc"}},
+		Query:   url.Values{"token": {"t0k"}},
+		Body:    []byte(`{"type":"url_verification","challenge":"3eZbrw1aBm2rZgRNFdxV2595E9CY3gmdALWMmHkvFXO7tYXAYM8P","event":{"id":7}}`),
+	}
+
+	response := &models.MockResponse{
+		StatusCode: 200,
+		Headers:    map[string]string{"X-Request-Id": `{{ header "x-request-id" }}`, "X-Captured-As": "{{ .ID }}"},
+		Body:       `{"challenge":"{{ .JSON.challenge }}","event":{{ json "event.id" }},"token":"{{ query "token" }}","missing":"{{ json "nope" }}"}`,
+		Template:   true,
+	}
+
+	err := Validate(response)
+	if err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+
+	rendered, err := Render(response, req)
+	if err != nil {
+		t.Fatalf("Render: %v", err)
+	}
+
+	wantBody := `{"challenge":"3eZbrw1aBm2rZgRNFdxV2595E9CY3gmdALWMmHkvFXO7tYXAYM8P","event":7,"token":"t0k","missing":""}`
+	if rendered.Body != wantBody {
+		t.Fatalf("unexpected body:\nwant %s\ngot  %s", wantBody, rendered.Body)
+	}
+	if rendered.Headers["X-Request-Id"] != "abc" || rendered.Headers["X-Captured-As"] != "req-1" {
+		t.Fatalf("unexpected headers: %+v", rendered.Headers)
+	}
+	if response.Body == rendered.Body {
+		t.Fatal("Render must not modify the configured response")
+	}
+}
+
+func TestRenderLeavesPlainResponsesAlone(t *testing.T) {
+	response := &models.MockResponse{Body: "{{ not a template }}"}
+
+	rendered, err := Render(response, &models.Request{})
+	if err != nil || rendered.Body != response.Body {
+		t.Fatalf("expected the body untouched, got %q, %v", rendered.Body, err)
+	}
+}
+
+func TestValidateRejectsBadTemplates(t *testing.T) {
+	err := Validate(&models.MockResponse{Body: "{{ .ID", Template: true})
+	if err == nil {
+		t.Fatal("expected an unterminated action to be rejected")
+	}
+}
+
+func TestRenderLeavesMissingFieldsEmpty(t *testing.T) {
+	response := &models.MockResponse{
+		Body:     `[{{ .JSON.missing }}]{{ if .JSON.missing }}present{{ else }}absent{{ end }}`,
+		Template: true,
+	}
+
+	for _, body := range []string{"not json", `{"other":1}`} {
+		rendered, err := Render(response, &models.Request{Body: []byte(body)})
+		if err != nil {
+			t.Fatalf("%s: Render: %v", body, err)
+		}
+		if rendered.Body != "[]absent" {
+			t.Fatalf("%s: expected the missing field to be empty, got %q", body, rendered.Body)
+		}
+	}
+}
+
+func TestTemplatesAreParsedOnce(t *testing.T) {
+	response := &models.MockResponse{Body: "{{ .Method }} parsed once", Template: true}
+
+	err := Validate(response)
+	if err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+	validated, _ := compile("body", response.Body)
+
+	for _, method := range []string{"GET", "POST"} {
+		rendered, err := Render(response, &models.Request{Method: method})
+		if err != nil || rendered.Body != method+" parsed once" {
+			t.Fatalf("expected the body rendered for %s, got %+v, %v", method, rendered, err)
+		}
+	}
+
+	rendered, _ := compile("body", response.Body)
+	if rendered != validated {
+		t.Fatal("expected rendering to reuse the template parsed by Validate")
+	}
+}
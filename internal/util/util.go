@@ -2,19 +2,89 @@ package util
 
 import (
 	"crypto/rand"
-	"encoding/hex"
 	"encoding/json"
 	"log/slog"
 	"net/http"
+	"sync"
+	"time"
 )
 
+// crockford is the Crockford base32 alphabet used by ULIDs
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+var idGen struct {
+	mu       sync.Mutex
+	lastMs   uint64
+	lastRand [10]byte
+}
+
+// GenerateID returns a 26 character ULID: a millisecond timestamp followed by
+// 80 random bits, in Crockford base32. IDs sort lexically in the order they
+// were generated, even within the same millisecond, so they can be used as
+// pagination cursors.
 func GenerateID() string {
-	bytes := make([]byte, 8)
-	_, err := rand.Read(bytes)
-	if err != nil {
-		slog.Error("error reading byte array")
+	idGen.mu.Lock()
+	defer idGen.mu.Unlock()
+
+	ms := uint64(time.Now().UnixMilli())
+
+	if ms <= idGen.lastMs {
+		// Same (or an earlier, if the clock stepped back) millisecond: keep
+		// the order by incrementing the previous random part
+		ms = idGen.lastMs
+		if !increment(idGen.lastRand[:]) {
+			ms++
+		}
+	} else {
+		_, err := rand.Read(idGen.lastRand[:])
+		if err != nil {
+			slog.Error("error reading byte array")
+		}
+	}
+	idGen.lastMs = ms
+
+	var b [16]byte
+	for i := 0; i < 6; i++ {
+		b[i] = byte(ms >> (40 - 8*i))
 	}
-	return hex.EncodeToString(bytes)
+	copy(b[6:], idGen.lastRand[:])
+
+	return encodeULID(b)
+}
+
+// increment adds one to a big-endian number, reporting false on overflow.
+func increment(b []byte) bool {
+	for i := len(b) - 1; i >= 0; i-- {
+		b[i]++
+		if b[i] != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// encodeULID writes 128 bits as 26 base32 characters, the first of which only
+// carries the top 3 bits.
+func encodeULID(b [16]byte) string {
+	var out [26]byte
+
+	// Work through the bits from least significant, 5 at a time
+	var acc uint32
+	var bits uint
+	pos := len(out) - 1
+	for i := len(b) - 1; i >= 0; i-- {
+		acc |= uint32(b[i]) << bits
+		bits += 8
+		for bits >= 5 {
+			out[pos] = crockford[acc&31]
+			pos--
+			acc >>= 5
+			bits -= 5
+		}
+	}
+	out[0] = crockford[acc&31]
+
+	return string(out[:])
 }
 
 func WriteJSON(w http.ResponseWriter, status int, data interface{}) {
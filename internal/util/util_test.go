@@ -0,0 +1,60 @@
+package util
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGenerateID(t *testing.T) {
+	const n = 10000
+
+	ids := make([]string, n)
+	for i := range ids {
+		ids[i] = GenerateID()
+	}
+
+	for _, id := range ids {
+		if len(id) != 26 || strings.Trim(id, crockford) != "" {
+			t.Fatalf("%q is not a ULID", id)
+		}
+	}
+
+	if !slices.IsSorted(ids) {
+		t.Fatal("expected IDs to sort in the order they were generated")
+	}
+
+	if len(slices.Compact(slices.Clone(ids))) != n {
+		t.Fatal("expected every ID to be unique")
+	}
+}
+
+func TestGenerateIDTimestamp(t *testing.T) {
+	before := time.Now().UnixMilli()
+	id := GenerateID()
+
+	// The first 10 characters are the timestamp
+	var ms int64
+	for _, c := range id[:10] {
+		ms = ms<<5 | int64(strings.IndexRune(crockford, c))
+	}
+
+	if ms < before || ms > time.Now().UnixMilli()+1 {
+		t.Fatalf("expected a timestamp around %d, got %d", before, ms)
+	}
+}
+
+func TestEncodeULID(t *testing.T) {
+	var max [16]byte
+	for i := range max {
+		max[i] = 0xff
+	}
+
+	if got := encodeULID(max); got != "7ZZZZZZZZZZZZZZZZZZZZZZZZZ" {
+		t.Fatalf("unexpected encoding of the largest ULID: %s", got)
+	}
+	if got := encodeULID([16]byte{}); got != "00000000000000000000000000" {
+		t.Fatalf("unexpected encoding of zero: %s", got)
+	}
+}